@@ -0,0 +1,524 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix is prepended to every environment variable override, e.g.
+// FORUM_SERVER_ADDR overrides server.addr.
+const EnvPrefix = "FORUM"
+
+// Config holds every tunable setting of the forum.
+type Config struct {
+	Server    Server    `yaml:"server"`
+	Database  Database  `yaml:"database"`
+	Log       Log       `yaml:"log"`
+	Mail      Mail      `yaml:"mail"`
+	Metrics   Metrics   `yaml:"metrics"`
+	RateLimit RateLimit `yaml:"rate_limit"`
+	Session   Session   `yaml:"session"`
+	Account   Account   `yaml:"account"`
+	Content   Content   `yaml:"content"`
+	Uploads   Uploads   `yaml:"uploads"`
+	WebSocket WebSocket `yaml:"websocket"`
+}
+
+type Server struct {
+	Addr            string        `yaml:"addr"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+	// PublicURL is where users reach the forum; links in emails point here.
+	PublicURL string `yaml:"public_url"`
+}
+
+type Database struct {
+	URL         string `yaml:"url"`
+	AutoMigrate bool   `yaml:"auto_migrate"`
+}
+
+type Log struct {
+	Level     string `yaml:"level"`  // debug, info, warn or error
+	Format    string `yaml:"format"` // text or json
+	Output    string `yaml:"output"` // stdout, stderr or file
+	InfoPath  string `yaml:"info_path"`
+	ErrorPath string `yaml:"error_path"`
+
+	// Rotation of the log files; a zero MaxSize or MaxAge disables that trigger.
+	MaxSize    int64         `yaml:"max_size"`    // bytes
+	MaxAge     time.Duration `yaml:"max_age"`     // rotate files older than this
+	MaxBackups int           `yaml:"max_backups"` // rotated files kept per log
+	Compress   bool          `yaml:"compress"`    // gzip rotated files
+}
+
+type Mail struct {
+	Transport string        `yaml:"transport"` // smtp, file or memory
+	From      string        `yaml:"from"`
+	Dir       string        `yaml:"dir"`     // where the file transport drops messages
+	Timeout   time.Duration `yaml:"timeout"` // per message, smtp only
+	SMTP      SMTP          `yaml:"smtp"`
+}
+
+type SMTP struct {
+	Addr     string `yaml:"addr"` // host:port
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+}
+
+type Metrics struct {
+	// Addr is a separate listen address serving /metrics, so it is not
+	// reachable through the public port. Empty disables metrics.
+	Addr string `yaml:"addr"`
+}
+
+// RateLimit sets token buckets per route class and websocket message type.
+type RateLimit struct {
+	IdleTTL time.Duration `yaml:"idle_ttl"` // forget buckets unused for this long
+
+	Auth      Limit `yaml:"auth"`       // login and registration, per IP
+	Write     Limit `yaml:"write"`      // new posts and comments, per user
+	React     Limit `yaml:"react"`      // likes and dislikes, per user
+	WSMessage Limit `yaml:"ws_message"` // chat messages, per user
+	WSTyping  Limit `yaml:"ws_typing"`  // typing notifications, per user
+}
+
+// Limit allows Burst requests at once, refilled one every Every. A zero Burst
+// turns the limit off.
+type Limit struct {
+	Every time.Duration `yaml:"every"`
+	Burst int           `yaml:"burst"`
+}
+
+// Session sets how long logins last. A session expires after Lifetime
+// without use; activity past half of it renews it, but never beyond
+// MaxLifetime from login, or RememberLifetime for "remember me" logins.
+type Session struct {
+	Lifetime         time.Duration `yaml:"lifetime"`
+	MaxLifetime      time.Duration `yaml:"max_lifetime"`
+	RememberLifetime time.Duration `yaml:"remember_lifetime"`
+	CleanupInterval  time.Duration `yaml:"cleanup_interval"`
+}
+
+// Cap returns how long after login a session may last at most.
+func (s Session) Cap(remember bool) time.Duration {
+	if remember {
+		return s.RememberLifetime
+	}
+	return s.MaxLifetime
+}
+
+// Account covers password recovery, email verification, two-factor
+// authentication and login throttling.
+type Account struct {
+	ResetTokenLifetime  time.Duration `yaml:"reset_token_lifetime"`
+	VerifyTokenLifetime time.Duration `yaml:"verify_token_lifetime"`
+	// Actions an unverified account may not take: "post" (posts and
+	// comments) and "message" (private messages).
+	RequireVerified []string `yaml:"require_verified"`
+
+	TwoFactor     TwoFactor     `yaml:"two_factor"`
+	LoginThrottle LoginThrottle `yaml:"login_throttle"`
+	Registration  Registration  `yaml:"registration"`
+}
+
+// Registration holds the rules a new account must pass beyond the fixed
+// nickname, name and email formats.
+type Registration struct {
+	ReservedNicknames []string `yaml:"reserved_nicknames"` // matched ignoring case
+	PasswordMinLength int      `yaml:"password_min_length"`
+	MinAge            int      `yaml:"min_age"`
+	MaxAge            int      `yaml:"max_age"`
+	Genders           []string `yaml:"genders"`
+}
+
+type TwoFactor struct {
+	Issuer string `yaml:"issuer"` // shown next to the account in authenticator apps
+	// How long, and for how many wrong codes, a password-checked login waits
+	// for its second factor.
+	PendingLifetime time.Duration `yaml:"pending_lifetime"`
+	MaxAttempts     int           `yaml:"max_attempts"`
+}
+
+// LoginThrottle slows down password guessing. Past FreeFailures, each
+// failed login doubles the wait before the next try, from BackoffBase up to
+// BackoffMax; failures are counted per account and per IP over IPWindow.
+// LockAfter consecutive failures lock the account for LockDuration and email
+// its owner an unlock link. The log of attempts is kept for AttemptRetention,
+// which must cover IPWindow and BackoffMax.
+type LoginThrottle struct {
+	FreeFailures        int           `yaml:"free_failures"`
+	BackoffBase         time.Duration `yaml:"backoff_base"`
+	BackoffMax          time.Duration `yaml:"backoff_max"`
+	IPWindow            time.Duration `yaml:"ip_window"`
+	LockAfter           int           `yaml:"lock_after"`
+	LockDuration        time.Duration `yaml:"lock_duration"`
+	UnlockTokenLifetime time.Duration `yaml:"unlock_token_lifetime"`
+	AttemptRetention    time.Duration `yaml:"attempt_retention"`
+}
+
+// Delay returns how long to wait after the last of failures failed logins.
+func (l LoginThrottle) Delay(failures int) time.Duration {
+	n := failures - l.FreeFailures
+	if n <= 0 {
+		return 0
+	}
+
+	delay := l.BackoffBase
+	for i := 1; i < n && delay < l.BackoffMax; i++ {
+		delay *= 2
+	}
+	return min(delay, l.BackoffMax)
+}
+
+// Requires reports whether action needs a verified email address.
+func (a Account) Requires(action string) bool {
+	for _, required := range a.RequireVerified {
+		if required == action {
+			return true
+		}
+	}
+	return false
+}
+
+// maxCommentDepth keeps comment paths, 11 characters a level, short.
+const maxCommentDepth = 32
+
+// Content sets how deep replies to comments may nest, how long authors may
+// edit their comments, and how long deleted posts and comments stay
+// restorable before the purge, run every PurgeInterval, removes them for
+// good.
+type Content struct {
+	// Top-level comments are at depth 0; comments at CommentMaxDepth take
+	// no replies. 0 turns replies off.
+	CommentMaxDepth int `yaml:"comment_max_depth"`
+	// CommentEditWindow counts from when a comment was posted. 0 lets
+	// authors edit their comments at any time.
+	CommentEditWindow time.Duration `yaml:"comment_edit_window"`
+	DeletedRetention  time.Duration `yaml:"deleted_retention"`
+	PurgeInterval     time.Duration `yaml:"purge_interval"`
+}
+
+type Uploads struct {
+	MaxSize   int64 `yaml:"max_size"`
+	MaxWidth  int   `yaml:"max_width"`
+	MaxHeight int   `yaml:"max_height"`
+	Quality   int   `yaml:"quality"`
+}
+
+type WebSocket struct {
+	WriteWait      time.Duration `yaml:"write_wait"`
+	PongWait       time.Duration `yaml:"pong_wait"`
+	PingPeriod     time.Duration `yaml:"ping_period"`
+	MaxMessageSize int64         `yaml:"max_message_size"`
+	SendBuffer     int           `yaml:"send_buffer"`
+	// Origins besides the server's own host allowed to open websockets,
+	// e.g. "https://forum.example.com".
+	AllowedOrigins []string `yaml:"allowed_origins"`
+}
+
+// Default returns the settings the forum used before it was configurable.
+func Default() Config {
+	return Config{
+		Server: Server{
+			Addr:            ":8080",
+			ShutdownTimeout: 15 * time.Second,
+			PublicURL:       "http://localhost:8080",
+		},
+		Database: Database{
+			URL:         "./forum.db",
+			AutoMigrate: true,
+		},
+		Log: Log{
+			Level:      "info",
+			Format:     "text",
+			Output:     "file",
+			InfoPath:   "backend/errLog/info.log",
+			ErrorPath:  "backend/errLog/error.log",
+			MaxSize:    50 << 20,
+			MaxAge:     24 * time.Hour,
+			MaxBackups: 7,
+			Compress:   true,
+		},
+		Mail: Mail{
+			Transport: "file",
+			From:      "forum@localhost",
+			Dir:       "mail",
+			Timeout:   10 * time.Second,
+		},
+		Metrics: Metrics{
+			Addr: "127.0.0.1:9091",
+		},
+		RateLimit: RateLimit{
+			IdleTTL:   10 * time.Minute,
+			Auth:      Limit{Every: 6 * time.Second, Burst: 10},
+			Write:     Limit{Every: 10 * time.Second, Burst: 5},
+			React:     Limit{Every: time.Second, Burst: 20},
+			WSMessage: Limit{Every: 200 * time.Millisecond, Burst: 20},
+			WSTyping:  Limit{Every: 100 * time.Millisecond, Burst: 30},
+		},
+		Session: Session{
+			Lifetime:         24 * time.Hour,
+			MaxLifetime:      7 * 24 * time.Hour,
+			RememberLifetime: 30 * 24 * time.Hour,
+			CleanupInterval:  time.Hour,
+		},
+		Account: Account{
+			ResetTokenLifetime:  time.Hour,
+			VerifyTokenLifetime: 24 * time.Hour,
+			RequireVerified:     []string{"post", "message"},
+			TwoFactor: TwoFactor{
+				Issuer:          "Real-time Forum",
+				PendingLifetime: 5 * time.Minute,
+				MaxAttempts:     5,
+			},
+			LoginThrottle: LoginThrottle{
+				FreeFailures:        3,
+				BackoffBase:         time.Second,
+				BackoffMax:          5 * time.Minute,
+				IPWindow:            15 * time.Minute,
+				LockAfter:           10,
+				LockDuration:        30 * time.Minute,
+				UnlockTokenLifetime: 24 * time.Hour,
+				AttemptRetention:    90 * 24 * time.Hour,
+			},
+			Registration: Registration{
+				ReservedNicknames: []string{"admin", "administrator", "moderator", "mod", "root", "system", "support", "staff", "forum", "me"},
+				PasswordMinLength: 8,
+				MinAge:            13,
+				MaxAge:            120,
+				Genders:           []string{"male", "female"},
+			},
+		},
+		Content: Content{
+			CommentMaxDepth:   8,
+			CommentEditWindow: 15 * time.Minute,
+			DeletedRetention:  30 * 24 * time.Hour,
+			PurgeInterval:     time.Hour,
+		},
+		Uploads: Uploads{
+			MaxSize:   10 << 20,
+			MaxWidth:  800,
+			MaxHeight: 600,
+			Quality:   80,
+		},
+		WebSocket: WebSocket{
+			WriteWait:      10 * time.Second,
+			PongWait:       60 * time.Second,
+			PingPeriod:     54 * time.Second,
+			MaxMessageSize: 0,
+			SendBuffer:     256,
+		},
+	}
+}
+
+// Load builds the configuration from the defaults, the YAML file at path
+// (if any) and FORUM_* environment variables, in that order of precedence.
+func Load(path string) (Config, error) {
+	cfg := Default()
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return cfg, fmt.Errorf("failed to read config file: %v", err)
+		}
+
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		// An empty file decodes to io.EOF and simply keeps the defaults.
+		if err := decoder.Decode(&cfg); err != nil && !errors.Is(err, io.EOF) {
+			return cfg, fmt.Errorf("failed to parse config file %s: %v", path, err)
+		}
+	}
+
+	if err := applyEnv(reflect.ValueOf(&cfg).Elem(), EnvPrefix, os.LookupEnv); err != nil {
+		return cfg, err
+	}
+
+	return cfg, nil
+}
+
+func applyEnv(v reflect.Value, prefix string, lookup func(string) (string, bool)) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		key := strings.Split(field.Tag.Get("yaml"), ",")[0]
+		if key == "" || key == "-" {
+			continue
+		}
+		name := prefix + "_" + strings.ToUpper(key)
+
+		if field.Type.Kind() == reflect.Struct {
+			if err := applyEnv(v.Field(i), name, lookup); err != nil {
+				return err
+			}
+			continue
+		}
+
+		value, ok := lookup(name)
+		if !ok {
+			continue
+		}
+		if err := setValue(v.Field(i), value); err != nil {
+			return fmt.Errorf("invalid value for %s: %v", name, err)
+		}
+	}
+
+	return nil
+}
+
+// setValue parses a string into a config field of any supported kind.
+func setValue(field reflect.Value, value string) error {
+	if field.Type() == reflect.TypeOf(time.Duration(0)) {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(d))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		field.SetInt(n)
+	case reflect.Slice:
+		if field.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported list type %s", field.Type())
+		}
+		var items []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		field.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported config type %s", field.Type())
+	}
+
+	return nil
+}
+
+// Validate checks that the settings make sense together.
+func (c Config) Validate() error {
+	var problems []string
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			problems = append(problems, fmt.Sprintf(format, args...))
+		}
+	}
+
+	check(c.Server.Addr != "", "server.addr must not be empty")
+	check(c.Server.ShutdownTimeout > 0, "server.shutdown_timeout must be positive")
+	check(strings.HasPrefix(c.Server.PublicURL, "http://") || strings.HasPrefix(c.Server.PublicURL, "https://"),
+		"server.public_url must be an http or https URL, got %q", c.Server.PublicURL)
+	check(c.Database.URL != "", "database.url must not be empty")
+	check(oneOf(c.Log.Level, "debug", "info", "warn", "error"), "log.level must be debug, info, warn or error, got %q", c.Log.Level)
+	check(oneOf(c.Log.Format, "text", "json"), "log.format must be text or json, got %q", c.Log.Format)
+	check(oneOf(c.Log.Output, "stdout", "stderr", "file"), "log.output must be stdout, stderr or file, got %q", c.Log.Output)
+	if c.Log.Output == "file" {
+		check(c.Log.InfoPath != "", "log.info_path must not be empty")
+		check(c.Log.ErrorPath != "", "log.error_path must not be empty")
+		check(c.Log.MaxSize >= 0, "log.max_size must not be negative")
+		check(c.Log.MaxAge >= 0, "log.max_age must not be negative")
+		check(c.Log.MaxBackups >= 0, "log.max_backups must not be negative")
+	}
+	check(oneOf(c.Mail.Transport, "smtp", "file", "memory"), "mail.transport must be smtp, file or memory, got %q", c.Mail.Transport)
+	check(c.Mail.From != "", "mail.from must not be empty")
+	check(c.Mail.Transport != "file" || c.Mail.Dir != "", "mail.dir must not be empty")
+	check(c.Mail.Transport != "smtp" || c.Mail.SMTP.Addr != "", "mail.smtp.addr must not be empty")
+	check(c.Mail.Timeout > 0, "mail.timeout must be positive")
+	check(c.Metrics.Addr == "" || c.Metrics.Addr != c.Server.Addr, "metrics.addr must differ from server.addr")
+	limits := []struct {
+		name  string
+		limit Limit
+	}{
+		{"auth", c.RateLimit.Auth},
+		{"write", c.RateLimit.Write},
+		{"react", c.RateLimit.React},
+		{"ws_message", c.RateLimit.WSMessage},
+		{"ws_typing", c.RateLimit.WSTyping},
+	}
+	for _, l := range limits {
+		check(l.limit.Burst >= 0, "rate_limit.%s.burst must not be negative", l.name)
+		check(l.limit.Burst == 0 || l.limit.Every > 0, "rate_limit.%s.every must be positive", l.name)
+	}
+	check(c.Session.Lifetime > 0, "session.lifetime must be positive")
+	check(c.Session.MaxLifetime >= c.Session.Lifetime, "session.max_lifetime must be at least session.lifetime")
+	check(c.Session.RememberLifetime >= c.Session.MaxLifetime, "session.remember_lifetime must be at least session.max_lifetime")
+	check(c.Session.CleanupInterval > 0, "session.cleanup_interval must be positive")
+	check(c.Account.ResetTokenLifetime > 0, "account.reset_token_lifetime must be positive")
+	check(c.Account.VerifyTokenLifetime > 0, "account.verify_token_lifetime must be positive")
+	check(c.Account.TwoFactor.Issuer != "" && !strings.Contains(c.Account.TwoFactor.Issuer, ":"),
+		"account.two_factor.issuer must be set and must not contain a colon")
+	check(c.Account.TwoFactor.PendingLifetime > 0, "account.two_factor.pending_lifetime must be positive")
+	check(c.Account.TwoFactor.MaxAttempts > 0, "account.two_factor.max_attempts must be positive")
+	throttle := c.Account.LoginThrottle
+	check(throttle.FreeFailures >= 0, "account.login_throttle.free_failures must not be negative")
+	check(throttle.BackoffBase > 0, "account.login_throttle.backoff_base must be positive")
+	check(throttle.BackoffMax >= throttle.BackoffBase, "account.login_throttle.backoff_max must be at least backoff_base")
+	check(throttle.IPWindow > 0, "account.login_throttle.ip_window must be positive")
+	check(throttle.LockAfter > throttle.FreeFailures, "account.login_throttle.lock_after must be greater than free_failures")
+	check(throttle.LockDuration > 0, "account.login_throttle.lock_duration must be positive")
+	check(throttle.UnlockTokenLifetime > 0, "account.login_throttle.unlock_token_lifetime must be positive")
+	check(throttle.AttemptRetention >= max(throttle.IPWindow, throttle.BackoffMax),
+		"account.login_throttle.attempt_retention must be at least ip_window and backoff_max")
+	registration := c.Account.Registration
+	// bcrypt only looks at the first 72 bytes of a password.
+	check(registration.PasswordMinLength >= 1 && registration.PasswordMinLength <= 72,
+		"account.registration.password_min_length must be between 1 and 72")
+	check(registration.MinAge >= 0, "account.registration.min_age must not be negative")
+	check(registration.MaxAge >= registration.MinAge, "account.registration.max_age must be at least min_age")
+	check(len(registration.Genders) > 0, "account.registration.genders must not be empty")
+	for _, action := range c.Account.RequireVerified {
+		check(oneOf(action, "post", "message"), "account.require_verified may only list post and message, got %q", action)
+	}
+	check(c.Content.CommentMaxDepth >= 0 && c.Content.CommentMaxDepth <= maxCommentDepth,
+		"content.comment_max_depth must be between 0 and %d", maxCommentDepth)
+	check(c.Content.CommentEditWindow >= 0, "content.comment_edit_window must not be negative")
+	check(c.Content.DeletedRetention > 0, "content.deleted_retention must be positive")
+	check(c.Content.PurgeInterval > 0, "content.purge_interval must be positive")
+	check(c.Uploads.MaxSize > 0, "uploads.max_size must be positive")
+	check(c.Uploads.MaxWidth > 0 && c.Uploads.MaxHeight > 0, "uploads.max_width and uploads.max_height must be positive")
+	check(c.Uploads.Quality >= 1 && c.Uploads.Quality <= 100, "uploads.quality must be between 1 and 100")
+	check(c.WebSocket.WriteWait > 0, "websocket.write_wait must be positive")
+	check(c.WebSocket.PongWait > 0, "websocket.pong_wait must be positive")
+	check(c.WebSocket.PingPeriod > 0 && c.WebSocket.PingPeriod < c.WebSocket.PongWait,
+		"websocket.ping_period must be positive and shorter than websocket.pong_wait")
+	check(c.WebSocket.MaxMessageSize >= 0, "websocket.max_message_size must not be negative")
+	check(c.WebSocket.SendBuffer > 0, "websocket.send_buffer must be positive")
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid configuration: %s", strings.Join(problems, "; "))
+	}
+
+	return nil
+}
+
+func oneOf(value string, allowed ...string) bool {
+	for _, a := range allowed {
+		if value == a {
+			return true
+		}
+	}
+	return false
+}
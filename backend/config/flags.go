@@ -0,0 +1,101 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Flags holds the command-line overrides registered by BindFlags.
+type Flags struct {
+	fs          *flag.FlagSet
+	addr        string
+	databaseURL string
+	autoMigrate bool
+	sets        setFlag
+}
+
+// setFlag collects repeated -set key=value overrides.
+type setFlag []string
+
+func (s *setFlag) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *setFlag) Set(value string) error {
+	if !strings.Contains(value, "=") {
+		return fmt.Errorf("expected key=value, got %q", value)
+	}
+	*s = append(*s, value)
+	return nil
+}
+
+// BindFlags registers the command-line overrides on fs. Flags win over the
+// config file and the environment, but only when they are actually passed.
+func BindFlags(fs *flag.FlagSet) *Flags {
+	f := &Flags{fs: fs}
+
+	fs.StringVar(&f.addr, "addr", "", "HTTP network address (server.addr)")
+	fs.StringVar(&f.databaseURL, "db", "", "database URL: a SQLite path or a postgres:// URL (database.url)")
+	fs.BoolVar(&f.autoMigrate, "auto-migrate", true, "apply pending schema migrations at startup (database.auto_migrate)")
+	fs.Var(&f.sets, "set", "override any setting as section.key=value, e.g. -set session.lifetime=48h (repeatable)")
+
+	return f
+}
+
+// Apply copies the flags that were set on the command line into cfg.
+func (f *Flags) Apply(cfg *Config) error {
+	var err error
+	f.fs.Visit(func(fl *flag.Flag) {
+		switch fl.Name {
+		case "addr":
+			cfg.Server.Addr = f.addr
+		case "db":
+			cfg.Database.URL = f.databaseURL
+		case "auto-migrate":
+			cfg.Database.AutoMigrate = f.autoMigrate
+		}
+	})
+
+	for _, set := range f.sets {
+		key, value, _ := strings.Cut(set, "=")
+		if err = Set(cfg, key, value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Set assigns value to the setting at a dotted YAML path such as "server.addr".
+func Set(cfg *Config, key, value string) error {
+	v := reflect.ValueOf(cfg).Elem()
+
+	for _, part := range strings.Split(key, ".") {
+		if v.Kind() != reflect.Struct {
+			return fmt.Errorf("unknown setting %q", key)
+		}
+
+		found := false
+		for i := 0; i < v.NumField(); i++ {
+			if strings.Split(v.Type().Field(i).Tag.Get("yaml"), ",")[0] == part {
+				v = v.Field(i)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("unknown setting %q", key)
+		}
+	}
+
+	if v.Kind() == reflect.Struct {
+		return fmt.Errorf("setting %q is a section, not a value", key)
+	}
+	if err := setValue(v, value); err != nil {
+		return fmt.Errorf("invalid value for %s: %v", key, err)
+	}
+
+	return nil
+}
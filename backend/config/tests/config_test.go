@@ -0,0 +1,172 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nyagooh/Real-time-forum.git/backend/config"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "forum.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	path := writeConfig(t, `
+server:
+  addr: ":9000"
+database:
+  url: "/var/lib/forum/forum.db"
+session:
+  lifetime: 48h
+uploads:
+  quality: 70
+`)
+	t.Setenv("FORUM_DATABASE_URL", "postgres://forum@localhost/forum")
+	t.Setenv("FORUM_WEBSOCKET_PONG_WAIT", "90s")
+
+	cfg, err := config.Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	overrides := config.BindFlags(fs)
+	if err := fs.Parse([]string{"-addr", ":9100", "-set", "uploads.max_width=1024"}); err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if err := overrides.Apply(&cfg); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+
+	if cfg.Server.Addr != ":9100" {
+		t.Errorf("Server.Addr = %q, want flag value :9100", cfg.Server.Addr)
+	}
+	if cfg.Database.URL != "postgres://forum@localhost/forum" {
+		t.Errorf("Database.URL = %q, want env value", cfg.Database.URL)
+	}
+	if cfg.Session.Lifetime != 48*time.Hour {
+		t.Errorf("Session.Lifetime = %v, want file value 48h", cfg.Session.Lifetime)
+	}
+	if cfg.WebSocket.PongWait != 90*time.Second {
+		t.Errorf("WebSocket.PongWait = %v, want env value 90s", cfg.WebSocket.PongWait)
+	}
+	if cfg.Uploads.Quality != 70 || cfg.Uploads.MaxWidth != 1024 {
+		t.Errorf("Uploads = %+v, want quality 70 and max width 1024", cfg.Uploads)
+	}
+	if cfg.Uploads.MaxHeight != config.Default().Uploads.MaxHeight {
+		t.Errorf("Uploads.MaxHeight = %d, want default", cfg.Uploads.MaxHeight)
+	}
+	if !cfg.Database.AutoMigrate {
+		t.Errorf("Database.AutoMigrate should keep its default when the flag is not passed")
+	}
+}
+
+func TestLoadRejectsUnknownKeys(t *testing.T) {
+	path := writeConfig(t, "server:\n  adress: \":9000\"\n")
+
+	if _, err := config.Load(path); err == nil {
+		t.Fatalf("expected an error for a misspelled key")
+	}
+}
+
+func TestLoadEmptyFile(t *testing.T) {
+	cfg, err := config.Load(writeConfig(t, ""))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("defaults should validate, got %v", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(*config.Config)
+		wantErr string
+	}{
+		{
+			name:   "Defaults",
+			modify: func(*config.Config) {},
+		},
+		{
+			name:    "Empty address",
+			modify:  func(c *config.Config) { c.Server.Addr = "" },
+			wantErr: "server.addr",
+		},
+		{
+			name:    "Quality out of range",
+			modify:  func(c *config.Config) { c.Uploads.Quality = 101 },
+			wantErr: "uploads.quality",
+		},
+		{
+			name:    "Ping slower than pong",
+			modify:  func(c *config.Config) { c.WebSocket.PingPeriod = 2 * c.WebSocket.PongWait },
+			wantErr: "websocket.ping_period",
+		},
+		{
+			name:    "Unknown log format",
+			modify:  func(c *config.Config) { c.Log.Format = "xml" },
+			wantErr: "log.format",
+		},
+		{
+			name: "Stdout output needs no paths",
+			modify: func(c *config.Config) {
+				c.Log.Output = "stdout"
+				c.Log.InfoPath, c.Log.ErrorPath = "", ""
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Default()
+			tt.modify(&cfg)
+
+			err := cfg.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Validate() error = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate() error = %v, want mention of %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSetUnknownKey(t *testing.T) {
+	cfg := config.Default()
+	if err := config.Set(&cfg, "server.port", "80"); err == nil {
+		t.Errorf("expected error for unknown key")
+	}
+	if err := config.Set(&cfg, "session.lifetime", "soon"); err == nil {
+		t.Errorf("expected error for invalid duration")
+	}
+}
+
+func TestLoginThrottleDelay(t *testing.T) {
+	throttle := config.LoginThrottle{FreeFailures: 2, BackoffBase: time.Second, BackoffMax: 10 * time.Second}
+
+	want := []time.Duration{0, 0, 0, time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second, 10 * time.Second}
+	for failures, w := range want {
+		if got := throttle.Delay(failures); got != w {
+			t.Errorf("Delay(%d) = %v, want %v", failures, got, w)
+		}
+	}
+	if got := throttle.Delay(1000); got != 10*time.Second {
+		t.Errorf("Delay(1000) = %v, want the 10s cap", got)
+	}
+}
@@ -1,42 +1,270 @@
 package database
 
 import (
+	"database/sql"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/nyagooh/Real-time-forum.git/backend/models"
 )
 
-func AddComment(postID, userID int, post *models.Post) error {
+// commentPath is the path of comment id under a parent with parentPath, or
+// at the top level when parentPath is empty. IDs are zero-padded so paths
+// sort like the thread reads.
+func commentPath(parentPath string, id int64) string {
+	if parentPath == "" {
+		return fmt.Sprintf("%010d", id)
+	}
+	return fmt.Sprintf("%s/%010d", parentPath, id)
+}
+
+// AddComment adds post.Content as a comment on the post, or as a reply to
+// parentID when it is not 0. It returns sql.ErrNoRows if the post does not
+// exist or was deleted, or the parent is not a live comment on it.
+func (s *Store) AddComment(postID, userID, parentID int, post *models.Post) error {
 	var username string
-	err := DB.QueryRow("SELECT nickname FROM users WHERE id = ?", userID).Scan(&username)
+	err := s.queryRow("SELECT nickname FROM users WHERE id = ?", userID).Scan(&username)
 	if err != nil {
 		return err
 	}
 
-	query := `INSERT INTO comments (post_id, user_id, username, content, created_at) 
-              VALUES (?, ?, ?, ?, ?)`
+	comment := models.Comment{
+		PostID:    postID,
+		ParentID:  parentID,
+		UserID:    userID,
+		Username:  username,
+		Content:   post.Content,
+		CreatedAt: time.Now().Format(time.RFC3339),
+	}
+
+	err = s.withTx(func(t tx) error {
+		var exists int
+		if err := t.queryRow("SELECT 1 FROM posts WHERE id = ? AND deleted_at IS NULL", postID).Scan(&exists); err != nil {
+			return err
+		}
+
+		var parentPath string
+		var parent sql.NullInt64
+		if parentID != 0 {
+			err := t.queryRow(`SELECT path, depth FROM comments WHERE id = ? AND post_id = ? AND deleted_at IS NULL`,
+				parentID, postID).Scan(&parentPath, &comment.Depth)
+			if err != nil {
+				return err
+			}
+			comment.Depth++
+			parent = sql.NullInt64{Int64: int64(parentID), Valid: true}
+		}
 
-	createdAt := time.Now().Format(time.RFC3339)
-	_, err = DB.Exec(query, postID, userID, username, post.Content, createdAt)
+		query := `INSERT INTO comments (post_id, parent_id, depth, user_id, username, content, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`
+		id, err := t.insert(query, postID, parent, comment.Depth, userID, username, comment.Content, comment.CreatedAt)
+		if err != nil {
+			return err
+		}
+
+		comment.ID, comment.Path = int(id), commentPath(parentPath, id)
+		_, err = t.exec(`UPDATE comments SET path = ? WHERE id = ?`, comment.Path, id)
+		return err
+	})
 	if err != nil {
 		return err
 	}
 
-	// Get the last inserted ID
-	var commentID int
-	err = DB.QueryRow("SELECT last_insert_rowid()").Scan(&commentID)
+	// Set the comment in the post object
+	post.Comments = append(post.Comments, comment)
+	return nil
+}
+
+// GetComment returns a comment that has not been deleted, or sql.ErrNoRows.
+func (s *Store) GetComment(commentID int) (*models.Comment, error) {
+	query := `
+	SELECT id, post_id, COALESCE(parent_id, 0), depth, path, user_id, username, content, created_at, updated_at
+	FROM comments
+	WHERE id = ? AND deleted_at IS NULL`
+
+	var c models.Comment
+	var updatedAt sql.NullString
+	err := s.queryRow(query, commentID).Scan(&c.ID, &c.PostID, &c.ParentID, &c.Depth, &c.Path,
+		&c.UserID, &c.Username, &c.Content, &c.CreatedAt, &updatedAt)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	c.Edited, c.UpdatedAt = updatedAt.Valid, updatedAt.String
+	return &c, nil
+}
 
-	// Set the comment ID in the post object
-	post.Comments = append(post.Comments, models.Comment{
-		ID:        commentID,
-		UserID:    userID,
-		Username:  username,
-		Content:   post.Content,
-		CreatedAt: createdAt,
-	})
+// UpdateComment replaces the content of a comment with comment.Content and
+// marks it edited. It returns sql.ErrNoRows for an unknown or deleted
+// comment.
+func (s *Store) UpdateComment(comment *models.Comment) error {
+	now := time.Now().Format(time.RFC3339)
 
+	result, err := s.exec(`UPDATE comments SET content = ?, updated_at = ? WHERE id = ? AND deleted_at IS NULL`,
+		comment.Content, now, comment.ID)
+	if err != nil {
+		return fmt.Errorf("database error: %v", err)
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+
+	comment.Edited, comment.UpdatedAt = true, now
 	return nil
 }
+
+// ListComments returns a post's comments in thread order: all of them, or
+// the replies under parentID when it is not 0. When levels is positive
+// only that many levels below the top-level comments or the parent are
+// included; Replies tells which comments have more to load. It returns
+// sql.ErrNoRows for an unknown or deleted post, or a parent not on it.
+func (s *Store) ListComments(postID, parentID, levels int) ([]models.Comment, error) {
+	where := []string{"c.post_id = ?"}
+	args := []any{postID}
+	base := -1
+
+	// As for AddComment, a deleted post's thread goes with it.
+	var exists int
+	if err := s.queryRow(`SELECT 1 FROM posts WHERE id = ? AND deleted_at IS NULL`, postID).Scan(&exists); err != nil {
+		return nil, err
+	}
+
+	if parentID != 0 {
+		var path string
+		err := s.queryRow(`SELECT path, depth FROM comments WHERE id = ? AND post_id = ?`, parentID, postID).Scan(&path, &base)
+		if err != nil {
+			return nil, err
+		}
+		where = append(where, "c.path LIKE ?")
+		args = append(args, path+"/%")
+	}
+
+	if levels > 0 {
+		where = append(where, "c.depth <= ?")
+		args = append(args, base+levels)
+	}
+
+	comments, err := s.queryComments(strings.Join(where, " AND "), args...)
+	if err != nil {
+		return nil, fmt.Errorf("database error: %v", err)
+	}
+	return comments, nil
+}
+
+// queryComments returns the comments matching where, which may refer to the
+// comments table as c, in thread order. Deleted comments come back as
+// tombstones.
+func (s *Store) queryComments(where string, args ...any) ([]models.Comment, error) {
+	rows, err := s.query(fmt.Sprintf(`
+	SELECT
+		c.id,
+		c.post_id,
+		COALESCE(c.parent_id, 0),
+		c.depth,
+		c.path,
+		COALESCE(replies.count, 0) AS replies,
+		c.content,
+		c.user_id,
+		u.nickname,
+		c.created_at,
+		c.updated_at,
+		c.deleted_at,
+		COALESCE(c.deleted_by, 0),
+		COALESCE(likes.count, 0) AS likes,
+		COALESCE(dislikes.count, 0) AS dislikes,
+		COALESCE(liked_by.usernames, '') AS liked_by,
+		COALESCE(disliked_by.usernames, '') AS disliked_by
+	FROM comments c
+	JOIN users u ON c.user_id = u.id
+	LEFT JOIN (
+		SELECT parent_id, COUNT(*) AS count
+		FROM comments
+		WHERE parent_id IS NOT NULL
+		GROUP BY parent_id
+	) AS replies ON c.id = replies.parent_id
+	LEFT JOIN (
+		SELECT comment_id, COUNT(*) AS count
+		FROM comment_reactions
+		WHERE reaction = 'like'
+		GROUP BY comment_id
+	) AS likes ON c.id = likes.comment_id
+	LEFT JOIN (
+		SELECT comment_id, COUNT(*) AS count
+		FROM comment_reactions
+		WHERE reaction = 'dislike'
+		GROUP BY comment_id
+	) AS dislikes ON c.id = dislikes.comment_id
+	LEFT JOIN (
+		SELECT cr.comment_id, %[1]s AS usernames
+		FROM comment_reactions cr
+		JOIN users u ON cr.user_id = u.id
+		WHERE cr.reaction = 'like'
+		GROUP BY cr.comment_id
+	) AS liked_by ON c.id = liked_by.comment_id
+	LEFT JOIN (
+		SELECT cr.comment_id, %[1]s AS usernames
+		FROM comment_reactions cr
+		JOIN users u ON cr.user_id = u.id
+		WHERE cr.reaction = 'dislike'
+		GROUP BY cr.comment_id
+	) AS disliked_by ON c.id = disliked_by.comment_id
+	WHERE %[2]s
+	ORDER BY c.path
+	`, s.dialect.groupConcat("u.nickname"), where), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var comments []models.Comment
+	for rows.Next() {
+		var comment models.Comment
+		var likedByStr, dislikedByStr string
+		var updatedAt, deletedAt sql.NullString
+		var deletedBy int
+		err := rows.Scan(
+			&comment.ID,
+			&comment.PostID,
+			&comment.ParentID,
+			&comment.Depth,
+			&comment.Path,
+			&comment.Replies,
+			&comment.Content,
+			&comment.UserID,
+			&comment.Username,
+			&comment.CreatedAt,
+			&updatedAt,
+			&deletedAt,
+			&deletedBy,
+			&comment.Likes,
+			&comment.Dislikes,
+			&likedByStr,
+			&dislikedByStr,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		// Parse comment likedBy and dislikedBy strings into arrays
+		if likedByStr != "" {
+			comment.LikedBy = strings.Split(likedByStr, ",")
+		} else {
+			comment.LikedBy = []string{}
+		}
+		if dislikedByStr != "" {
+			comment.DislikedBy = strings.Split(dislikedByStr, ",")
+		} else {
+			comment.DislikedBy = []string{}
+		}
+		comment.Edited, comment.UpdatedAt = updatedAt.Valid, updatedAt.String
+		if deletedAt.Valid {
+			tombstoneComment(&comment, deletedAt.String, deletedBy)
+		}
+
+		comments = append(comments, comment)
+	}
+
+	return comments, rows.Err()
+}
@@ -0,0 +1,163 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/nyagooh/Real-time-forum.git/backend/models"
+)
+
+// deletedBy says who deleted content written by authorID.
+func deletedBy(deleterID, authorID int) string {
+	if deleterID == authorID {
+		return models.DeletedByAuthor
+	}
+	return models.DeletedByModerator
+}
+
+// tombstonePost blanks a deleted post so only its place in the list, its
+// reactions and its comments remain.
+func tombstonePost(post *models.Post, deletedAt string, deleterID, authorID int) {
+	post.Deleted, post.DeletedAt, post.DeletedBy = true, deletedAt, deletedBy(deleterID, authorID)
+	post.Title, post.Content, post.ImageURL, post.Username = "", "", "", ""
+	post.Category = []string{}
+}
+
+// tombstoneComment blanks a deleted comment, keeping its place in the
+// thread.
+func tombstoneComment(comment *models.Comment, deletedAt string, deleterID int) {
+	comment.Deleted, comment.DeletedAt, comment.DeletedBy = true, deletedAt, deletedBy(deleterID, comment.UserID)
+	comment.Content, comment.Username, comment.UserID = "", "", 0
+}
+
+// softDelete marks the row in table as deleted by userID, returning
+// sql.ErrNoRows if there is no such row or it is already deleted.
+func softDelete(t tx, table string, id, userID int) error {
+	result, err := t.exec(`UPDATE `+table+` SET deleted_at = ?, deleted_by = ? WHERE id = ? AND deleted_at IS NULL`,
+		time.Now(), userID, id)
+	if err != nil {
+		return fmt.Errorf("database error: %v", err)
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// restore undoes softDelete, returning sql.ErrNoRows if there is no such
+// deleted row.
+func (s *Store) restore(table string, id int) error {
+	result, err := s.exec(`UPDATE `+table+` SET deleted_at = NULL, deleted_by = NULL WHERE id = ? AND deleted_at IS NOT NULL`, id)
+	if err != nil {
+		return fmt.Errorf("database error: %v", err)
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// DeletePost turns the post into a tombstone, deleted by userID.
+func (s *Store) DeletePost(postID, userID int) error {
+	return s.withTx(func(t tx) error {
+		return softDelete(t, "posts", postID, userID)
+	})
+}
+
+// RestorePost brings back a deleted post that has not been purged.
+func (s *Store) RestorePost(postID int) error {
+	return s.restore("posts", postID)
+}
+
+// DeleteComment turns the comment into a tombstone, deleted by userID, and
+// drops its reactions; a restored comment comes back without them.
+func (s *Store) DeleteComment(commentID, userID int) error {
+	return s.withTx(func(t tx) error {
+		if err := softDelete(t, "comments", commentID, userID); err != nil {
+			return err
+		}
+		if _, err := t.exec(`DELETE FROM comment_reactions WHERE comment_id = ?`, commentID); err != nil {
+			return fmt.Errorf("database error: %v", err)
+		}
+		return nil
+	})
+}
+
+// RestoreComment brings back a deleted comment that has not been purged.
+func (s *Store) RestoreComment(commentID int) error {
+	return s.restore("comments", commentID)
+}
+
+// PurgeDeleted removes for good the posts and comments deleted before
+// cutoff, with everything that hangs off them: reactions, revisions and the
+// comments on purged posts. A deleted comment with replies stays as a
+// tombstone holding the thread together until they are gone. It returns how
+// many posts and comments went.
+func (s *Store) PurgeDeleted(cutoff time.Time) (posts, comments int, err error) {
+	const purgedPosts = `SELECT id FROM posts WHERE deleted_at < ?`
+	const purgedCondition = `(deleted_at < ? AND id NOT IN (SELECT parent_id FROM comments WHERE parent_id IS NOT NULL))
+		OR post_id IN (` + purgedPosts + `)`
+	const purgedComments = `SELECT id FROM comments WHERE ` + purgedCondition
+
+	err = s.withTx(func(t tx) error {
+		if _, err := t.exec(`DELETE FROM comment_reactions WHERE comment_id IN (`+purgedComments+`)`, cutoff, cutoff); err != nil {
+			return err
+		}
+		result, err := t.exec(`DELETE FROM comments WHERE `+purgedCondition, cutoff, cutoff)
+		if err != nil {
+			return err
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		comments = int(n)
+
+		for _, table := range []string{"post_reactions", "post_revisions"} {
+			if _, err := t.exec(`DELETE FROM `+table+` WHERE post_id IN (`+purgedPosts+`)`, cutoff); err != nil {
+				return err
+			}
+		}
+		result, err = t.exec(`DELETE FROM posts WHERE deleted_at < ?`, cutoff)
+		if err != nil {
+			return err
+		}
+		n, err = result.RowsAffected()
+		posts = int(n)
+		return err
+	})
+	if err != nil {
+		return 0, 0, fmt.Errorf("database error: %v", err)
+	}
+	return posts, comments, nil
+}
+
+// StartContentPurge purges posts and comments deleted more than retention
+// ago every interval until ctx is done.
+func StartContentPurge(ctx context.Context, posts PostStore, retention, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			purgedPosts, purgedComments, err := posts.PurgeDeleted(time.Now().Add(-retention))
+			if err != nil {
+				slog.Error("Content purge failed", "err", err)
+				continue
+			}
+			if purgedPosts > 0 || purgedComments > 0 {
+				slog.Info("Purged deleted content", "posts", purgedPosts, "comments", purgedComments)
+			}
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/lib/pq"
+	"github.com/mattn/go-sqlite3"
+)
+
+// Dialect identifies the SQL database behind a connection and hides the
+// differences between them from the stores.
+type Dialect string
+
+const (
+	SQLite   Dialect = "sqlite"
+	Postgres Dialect = "postgres"
+)
+
+// ParseDatabaseURL picks the dialect from a database URL and returns the
+// data source name for its driver. Plain paths are treated as SQLite files.
+func ParseDatabaseURL(url string) (Dialect, string, error) {
+	switch {
+	case strings.HasPrefix(url, "postgres://"), strings.HasPrefix(url, "postgresql://"):
+		return Postgres, url, nil
+	case strings.HasPrefix(url, "sqlite://"):
+		return SQLite, strings.TrimPrefix(url, "sqlite://"), nil
+	case strings.HasPrefix(url, "sqlite3://"):
+		return SQLite, strings.TrimPrefix(url, "sqlite3://"), nil
+	case strings.Contains(url, "://"):
+		return "", "", fmt.Errorf("unsupported database url: %s", url)
+	default:
+		return SQLite, url, nil
+	}
+}
+
+func (d Dialect) driverName() string {
+	if d == Postgres {
+		return "postgres"
+	}
+	return "sqlite3"
+}
+
+// Rebind rewrites ? placeholders into the dialect's bind syntax.
+func (d Dialect) Rebind(query string) string {
+	if d != Postgres {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	inString := false
+	for _, r := range query {
+		switch {
+		case r == '\'':
+			inString = !inString
+		case r == '?' && !inString:
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
+
+// tableExists is a query counting the tables named by its one argument in
+// the current schema, reading only the catalog.
+func (d Dialect) tableExists() string {
+	if d == Postgres {
+		return `SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = $1`
+	}
+	return `SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?`
+}
+
+// groupConcat joins the values of expr with commas.
+func (d Dialect) groupConcat(expr string) string {
+	if d == Postgres {
+		return "STRING_AGG(" + expr + ", ',')"
+	}
+	return "GROUP_CONCAT(" + expr + ")"
+}
+
+// isoTimestamp formats a timestamp expression as YYYY-MM-DDTHH:MM:SSZ.
+func (d Dialect) isoTimestamp(expr string) string {
+	if d == Postgres {
+		return `to_char(` + expr + ` AT TIME ZONE 'UTC', 'YYYY-MM-DD"T"HH24:MI:SS"Z"')`
+	}
+	return "strftime('%Y-%m-%dT%H:%M:%SZ', " + expr + ")"
+}
+
+// Postgres names the offending columns only in the detail message, wrapped
+// as in "lower(nickname::text)" for an expression index.
+var (
+	rxUniqueDetail = regexp.MustCompile(`^Key \((.+?)\)=\(`)
+	rxKeyColumn    = regexp.MustCompile(`^(?:lower\()?(\w+)`)
+)
+
+// uniqueViolation reports whether err broke a unique constraint, and on
+// which column. A constraint over several columns gives the last one.
+func uniqueViolation(err error) (column string, ok bool) {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
+		// "UNIQUE constraint failed: users.nickname"
+		msg := sqliteErr.Error()
+		column = msg[strings.LastIndex(msg, ".")+1:]
+		return column, true
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		if m := rxUniqueDetail.FindStringSubmatch(pqErr.Detail); m != nil {
+			columns := strings.Split(m[1], ", ")
+			column = columns[len(columns)-1]
+			if c := rxKeyColumn.FindStringSubmatch(column); c != nil {
+				column = c[1]
+			}
+		}
+		return column, true
+	}
+
+	return "", false
+}
+
+func (s *Store) exec(query string, args ...any) (sql.Result, error) {
+	return s.db.Exec(s.dialect.Rebind(query), args...)
+}
+
+func (s *Store) query(query string, args ...any) (*sql.Rows, error) {
+	return s.db.Query(s.dialect.Rebind(query), args...)
+}
+
+func (s *Store) queryRow(query string, args ...any) *sql.Row {
+	return s.db.QueryRow(s.dialect.Rebind(query), args...)
+}
+
+// insert runs an INSERT and returns the id of the new row.
+func (s *Store) insert(query string, args ...any) (int64, error) {
+	if s.dialect == Postgres {
+		var id int64
+		err := s.queryRow(query+" RETURNING id", args...).Scan(&id)
+		return id, err
+	}
+
+	result, err := s.exec(query, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.LastInsertId()
+}
+
+// tx is a transaction whose helpers rebind placeholders like the Store's.
+type tx struct {
+	*sql.Tx
+	dialect Dialect
+}
+
+func (t tx) exec(query string, args ...any) (sql.Result, error) {
+	return t.Exec(t.dialect.Rebind(query), args...)
+}
+
+func (t tx) query(query string, args ...any) (*sql.Rows, error) {
+	return t.Query(t.dialect.Rebind(query), args...)
+}
+
+func (t tx) queryRow(query string, args ...any) *sql.Row {
+	return t.QueryRow(t.dialect.Rebind(query), args...)
+}
+
+func (t tx) insert(query string, args ...any) (int64, error) {
+	if t.dialect == Postgres {
+		var id int64
+		err := t.queryRow(query+" RETURNING id", args...).Scan(&id)
+		return id, err
+	}
+
+	result, err := t.exec(query, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.LastInsertId()
+}
+
+// withTx runs fn in a transaction, committing when it returns nil and rolling
+// back otherwise.
+func (s *Store) withTx(fn func(t tx) error) error {
+	sqlTx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	if err := fn(tx{Tx: sqlTx, dialect: s.dialect}); err != nil {
+		sqlTx.Rollback()
+		return err
+	}
+
+	return sqlTx.Commit()
+}
@@ -0,0 +1,68 @@
+package database
+
+import "time"
+
+// CreateEmailVerification stores the hash of a new verification token for the
+// user and drops the user's tokens that can no longer be used.
+func (s *Store) CreateEmailVerification(userID int, tokenHash string, expiresAt time.Time) error {
+	query := `
+	DELETE FROM email_verifications
+	WHERE user_id = ? AND (used_at IS NOT NULL OR expires_at < ?)`
+
+	if _, err := s.exec(query, userID, time.Now()); err != nil {
+		return err
+	}
+
+	query = `
+	INSERT INTO email_verifications (user_id, token_hash, expires_at)
+	VALUES (?, ?, ?)`
+
+	_, err := s.exec(query, userID, tokenHash, expiresAt)
+
+	return err
+}
+
+// VerifyEmail spends the verification token and marks the user's address as
+// verified. It returns ErrInvalidToken when the token cannot be used.
+func (s *Store) VerifyEmail(tokenHash string) (int, error) {
+	var userID int
+
+	err := s.withTx(func(t tx) error {
+		now := time.Now()
+
+		result, err := t.exec(`
+		UPDATE email_verifications
+		SET used_at = ?
+		WHERE token_hash = ? AND used_at IS NULL AND expires_at > ?`,
+			now, tokenHash, now)
+		if err != nil {
+			return err
+		}
+		if n, err := result.RowsAffected(); err != nil {
+			return err
+		} else if n == 0 {
+			return ErrInvalidToken
+		}
+
+		err = t.queryRow(`SELECT user_id FROM email_verifications WHERE token_hash = ?`, tokenHash).Scan(&userID)
+		if err != nil {
+			return err
+		}
+
+		if _, err := t.exec(`UPDATE users SET verified_at = ? WHERE id = ? AND verified_at IS NULL`, now, userID); err != nil {
+			return err
+		}
+
+		_, err = t.exec(`
+		UPDATE email_verifications
+		SET used_at = ?
+		WHERE user_id = ? AND used_at IS NULL`,
+			now, userID)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return userID, nil
+}
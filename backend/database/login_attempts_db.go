@@ -0,0 +1,221 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/nyagooh/Real-time-forum.git/backend/models"
+)
+
+// RecordLoginAttempt logs a sign-in try from ip. userID is 0 when the
+// identity matched no account. For an account it returns how many logins in
+// a row have now failed; a success resets the count.
+func (s *Store) RecordLoginAttempt(userID int, ip, userAgent string, success bool) (int, error) {
+	var failures int
+
+	err := s.withTx(func(t tx) error {
+		var user any
+		if userID != 0 {
+			user = userID
+		}
+
+		_, err := t.exec(`
+		INSERT INTO login_attempts (user_id, ip, user_agent, success, created_at)
+		VALUES (?, ?, ?, ?, ?)`,
+			user, ip, userAgent, success, time.Now())
+		if err != nil || userID == 0 {
+			return err
+		}
+
+		query := `UPDATE users SET failed_logins = failed_logins + 1 WHERE id = ?`
+		if success {
+			query = `UPDATE users SET failed_logins = 0 WHERE id = ?`
+		}
+		if _, err := t.exec(query, userID); err != nil {
+			return err
+		}
+
+		return t.queryRow(`SELECT failed_logins FROM users WHERE id = ?`, userID).Scan(&failures)
+	})
+
+	return failures, err
+}
+
+// AccountFailures returns the account's run of failed logins and its lock.
+func (s *Store) AccountFailures(userID int) (models.LoginFailures, error) {
+	var failures models.LoginFailures
+	var lockedUntil sql.NullTime
+
+	err := s.queryRow(`SELECT failed_logins, locked_until FROM users WHERE id = ?`, userID).Scan(&failures.Count, &lockedUntil)
+	if err != nil {
+		return failures, fmt.Errorf("database error: %v", err)
+	}
+	failures.LockedUntil = lockedUntil.Time
+
+	query := `
+	SELECT created_at
+	FROM login_attempts
+	WHERE user_id = ? AND success = ?
+	ORDER BY created_at DESC
+	LIMIT 1`
+
+	err = s.queryRow(query, userID, false).Scan(&failures.Last)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return failures, fmt.Errorf("database error: %v", err)
+	}
+
+	return failures, nil
+}
+
+// IPFailures returns the failed logins from ip since the given time.
+func (s *Store) IPFailures(ip string, since time.Time) (models.LoginFailures, error) {
+	var failures models.LoginFailures
+
+	query := `
+	SELECT created_at
+	FROM login_attempts
+	WHERE ip = ? AND success = ? AND created_at > ?
+	ORDER BY created_at DESC`
+
+	rows, err := s.query(query, ip, false, since)
+	if err != nil {
+		return failures, fmt.Errorf("database error: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var at time.Time
+		if err := rows.Scan(&at); err != nil {
+			return failures, fmt.Errorf("database error: %v", err)
+		}
+		if failures.Count == 0 {
+			failures.Last = at
+		}
+		failures.Count++
+	}
+
+	return failures, rows.Err()
+}
+
+// LockAccount locks the account until the given time and stores the hash of
+// a token that unlocks it early, replacing earlier unlock tokens.
+func (s *Store) LockAccount(userID int, until time.Time, tokenHash string, tokenExpiresAt time.Time) error {
+	return s.withTx(func(t tx) error {
+		if _, err := t.exec(`UPDATE users SET locked_until = ? WHERE id = ?`, until, userID); err != nil {
+			return err
+		}
+		if _, err := t.exec(`DELETE FROM account_unlocks WHERE user_id = ?`, userID); err != nil {
+			return err
+		}
+		_, err := t.exec(`
+		INSERT INTO account_unlocks (user_id, token_hash, expires_at)
+		VALUES (?, ?, ?)`,
+			userID, tokenHash, tokenExpiresAt)
+		return err
+	})
+}
+
+// UnlockAccount spends an unlock token, lifting the lock and clearing the
+// run of failed logins. It returns ErrInvalidToken when the token cannot be
+// used.
+func (s *Store) UnlockAccount(tokenHash string) (int, error) {
+	var userID int
+
+	err := s.withTx(func(t tx) error {
+		now := time.Now()
+
+		result, err := t.exec(`
+		UPDATE account_unlocks
+		SET used_at = ?
+		WHERE token_hash = ? AND used_at IS NULL AND expires_at > ?`,
+			now, tokenHash, now)
+		if err != nil {
+			return err
+		}
+		if n, err := result.RowsAffected(); err != nil {
+			return err
+		} else if n == 0 {
+			return ErrInvalidToken
+		}
+
+		err = t.queryRow(`SELECT user_id FROM account_unlocks WHERE token_hash = ?`, tokenHash).Scan(&userID)
+		if err != nil {
+			return err
+		}
+
+		_, err = t.exec(`UPDATE users SET locked_until = NULL, failed_logins = 0 WHERE id = ?`, userID)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return userID, nil
+}
+
+// ListLoginAttempts returns the account's most recent sign-in tries, newest
+// first.
+func (s *Store) ListLoginAttempts(userID, limit int) ([]models.LoginAttempt, error) {
+	query := `
+	SELECT success, ip, user_agent, created_at
+	FROM login_attempts
+	WHERE user_id = ?
+	ORDER BY created_at DESC, id DESC
+	LIMIT ?`
+
+	rows, err := s.query(query, userID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("database error: %v", err)
+	}
+	defer rows.Close()
+
+	attempts := []models.LoginAttempt{}
+	for rows.Next() {
+		var attempt models.LoginAttempt
+		if err := rows.Scan(&attempt.Success, &attempt.IP, &attempt.UserAgent, &attempt.CreatedAt); err != nil {
+			return nil, fmt.Errorf("database error: %v", err)
+		}
+		attempts = append(attempts, attempt)
+	}
+
+	return attempts, rows.Err()
+}
+
+// PruneLoginAttempts deletes the sign-in log entries made before cutoff and
+// returns how many went. Lockouts and runs of failures live on the account,
+// so they are not affected.
+func (s *Store) PruneLoginAttempts(cutoff time.Time) (int, error) {
+	result, err := s.exec(`DELETE FROM login_attempts WHERE created_at < ?`, cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("database error: %v", err)
+	}
+	n, err := result.RowsAffected()
+	return int(n), err
+}
+
+// StartLoginAttemptCleanup prunes login attempts older than retention every
+// interval until ctx is done.
+func StartLoginAttemptCleanup(ctx context.Context, attempts LoginAttemptStore, retention, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			pruned, err := attempts.PruneLoginAttempts(time.Now().Add(-retention))
+			if err != nil {
+				slog.Error("Login attempt cleanup failed", "err", err)
+				continue
+			}
+			if pruned > 0 {
+				slog.Info("Pruned login attempts", "count", pruned)
+			}
+		}
+	}
+}
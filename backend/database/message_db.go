@@ -1,36 +1,37 @@
 package database
 
 import (
-	"database/sql"
 	"fmt"
+	"log/slog"
 
-	"github.com/nyagooh/Real-time-forum.git/backend/errLog"
 	m "github.com/nyagooh/Real-time-forum.git/backend/models"
 )
 
-func SaveMessage(msg *m.Message) error {
+// SaveMessage stores msg and sets its ID.
+func (s *Store) SaveMessage(msg *m.Message) error {
 	query := `
 	INSERT INTO messages (sender_id, sender, receiver_id, receiver, content)
 	VALUES (?, ?, ?, ?, ?)`
-	_, err := DB.Exec(query, msg.SenderID, msg.Sender, msg.ReceiverID, msg.Receiver, msg.Content)
+	id, err := s.insert(query, msg.SenderID, msg.Sender, msg.ReceiverID, msg.Receiver, msg.Content)
 	if err != nil {
 		return fmt.Errorf("error storing message: %v", err)
 	}
+	msg.ID = int(id)
 	return nil
 }
 
-func GetMessages(db *sql.DB, sender, receiver string, offset, limit int) ([]m.Message, error) {
+func (s *Store) GetMessages(sender, receiver string, offset, limit int) ([]m.Message, error) {
 	query := `
-	SELECT sender_id, sender, receiver_id, receiver, content, timestamp
+	SELECT id, sender_id, sender, receiver_id, receiver, content, timestamp
 	FROM messages
 	WHERE (sender = ? AND receiver = ?)
 	OR (sender = ? AND receiver = ?)
 	ORDER BY timestamp DESC
 	LIMIT ? OFFSET ?`
 
-	rows, err := db.Query(query, sender, receiver, receiver, sender, limit, offset)
+	rows, err := s.query(query, sender, receiver, receiver, sender, limit, offset)
 	if err != nil {
-		errLog.Error.Println(err.Error())
+		slog.Error("Failed to query messages", "sender", sender, "receiver", receiver, "err", err)
 		return nil, err
 	}
 	defer rows.Close()
@@ -38,8 +39,8 @@ func GetMessages(db *sql.DB, sender, receiver string, offset, limit int) ([]m.Me
 	var messages []m.Message
 	for rows.Next() {
 		var message m.Message
-		if err := rows.Scan(&message.SenderID, &message.Sender, &message.ReceiverID, &message.Receiver, &message.Content, &message.Timestamp); err != nil {
-			errLog.Error.Println(err.Error())
+		if err := rows.Scan(&message.ID, &message.SenderID, &message.Sender, &message.ReceiverID, &message.Receiver, &message.Content, &message.Timestamp); err != nil {
+			slog.Error("Failed to scan message", "err", err)
 			return nil, err
 		}
 		messages = append(messages, message)
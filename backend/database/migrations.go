@@ -1,143 +1,297 @@
 package database
 
 import (
+	"context"
 	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//go:embed migrations
+var migrationFiles embed.FS
 
-	"github.com/nyagooh/Real-time-forum.git/backend/errLog"
+var (
+	// ErrSchemaBehind means the database is missing migrations this binary knows about.
+	ErrSchemaBehind = errors.New("database schema is behind the binary")
+	// ErrSchemaAhead means the database has migrations this binary does not know about.
+	ErrSchemaAhead = errors.New("database schema is ahead of the binary")
 )
 
-var DB *sql.DB
+// Migration is one numbered schema change with its up and down SQL.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// InitDB opens the database the URL points at and returns its dialect.
+func InitDB(url string) (*sql.DB, Dialect, error) {
+	dialect, dsn, err := ParseDatabaseURL(url)
+	if err != nil {
+		return nil, "", err
+	}
+
+	db, err := sql.Open(dialect.driverName(), dsn)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, "", err
+	}
+
+	return db, dialect, nil
+}
+
+// CloseDB closes the pool, giving up once ctx expires so a stuck query
+// cannot hang shutdown.
+func CloseDB(ctx context.Context, db *sql.DB) error {
+	done := make(chan error, 1)
+	go func() { done <- db.Close() }()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("closing database: %w", ctx.Err())
+	}
+}
 
-func InitDB() (*sql.DB, error) {
-	var err error
-	DB, err = sql.Open("sqlite3", "./forum.db")
+// Migrations returns the embedded migrations for a dialect ordered by version.
+func Migrations(dialect Dialect) ([]Migration, error) {
+	dir := path.Join("migrations", string(dialect))
+	entries, err := fs.ReadDir(migrationFiles, dir)
 	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %v", err)
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		name := entry.Name()
+		var direction string
+		switch {
+		case strings.HasSuffix(name, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(name, ".down.sql"):
+			direction = "down"
+		default:
+			continue
+		}
+
+		prefix, rest, ok := strings.Cut(name, "_")
+		if !ok {
+			return nil, fmt.Errorf("invalid migration file name: %s", name)
+		}
+		version, err := strconv.Atoi(prefix)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version in %s: %v", name, err)
+		}
+
+		content, err := fs.ReadFile(migrationFiles, path.Join(dir, name))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %v", name, err)
+		}
+
+		m, exists := byVersion[version]
+		if !exists {
+			m = &Migration{Version: version, Name: strings.TrimSuffix(rest, "."+direction+".sql")}
+			byVersion[version] = m
+		}
+		if direction == "up" {
+			m.Up = string(content)
+		} else {
+			m.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %04d_%s needs both up and down files", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+func ensureMigrationsTable(db *sql.DB) error {
+	query := `
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`
+	_, err := db.Exec(query)
+	return err
+}
+
+// AppliedVersions returns the versions recorded in schema_migrations. It
+// only reads, so health checks can call it against a read-only database; a
+// database without the table has nothing applied.
+func AppliedVersions(db *sql.DB, dialect Dialect) ([]int, error) {
+	var tables int
+	if err := db.QueryRow(dialect.tableExists(), "schema_migrations").Scan(&tables); err != nil {
 		return nil, err
 	}
+	if tables == 0 {
+		return nil, nil
+	}
+
+	rows, err := db.Query(`SELECT version FROM schema_migrations ORDER BY version`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
 
-	CreateTables()
-	return DB, nil
+	var versions []int
+	for rows.Next() {
+		var version int
+		if err := rows.Scan(&version); err != nil {
+			return nil, err
+		}
+		versions = append(versions, version)
+	}
+
+	return versions, rows.Err()
 }
 
-func CreateTables() {
-	usersTable := `
-	CREATE TABLE IF NOT EXISTS users (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		nickname VARCHAR(255) UNIQUE NOT NULL,
-		age INTEGER NOT NULL,
-		gender TEXT,
-		firstname TEXT NOT NULL,
-		lastname TEXT NOT NULL,
-		email VARCHAR(255) UNIQUE NOT NULL,
-		password CHAR(60) DEFAULT NULL,
-		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
-	);
-	
-	CREATE INDEX IF NOT EXISTS idx_users_email ON users(email)`
-	_, err := DB.Exec(usersTable)
+// Migrate applies every pending migration in order, each in its own transaction.
+func Migrate(db *sql.DB, dialect Dialect) error {
+	if err := checkNotAhead(db, dialect); err != nil {
+		return err
+	}
+
+	migrations, err := Migrations(dialect)
 	if err != nil {
-		errLog.Error.Printf("Failed to create users table: %v\n", err)
-		return
-	}
-
-	postTable := `
-	CREATE TABLE IF NOT EXISTS posts (
-        id INTEGER PRIMARY KEY AUTOINCREMENT,
-        user_id INTEGER NOT NULL,
-        title TEXT NOT NULL,
-        content TEXT NOT NULL,
-        categories TEXT NOT NULL,
-		image_url TEXT,
-        created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-		FOREIGN KEY (user_id) REFERENCES users (id)
-    );`
-	_, err = DB.Exec(postTable)
+		return err
+	}
+	if err := ensureMigrationsTable(db); err != nil {
+		return err
+	}
+	applied, err := AppliedVersions(db, dialect)
 	if err != nil {
-		errLog.Error.Printf("Failed to create posts table: %v\n", err)
-		return
-	}
-
-	reactionTable := `
-	CREATE TABLE IF NOT EXISTS post_reactions (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		user_id INTEGER NOT NULL,
-		post_id INTEGER NOT NULL,
-		reaction TEXT CHECK(reaction IN ('like', 'dislike')) NOT NULL,
-		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-		FOREIGN KEY (user_id) REFERENCES users (id),
-		FOREIGN KEY (post_id) REFERENCES posts (id),
-		UNIQUE(user_id, post_id)
-	);`
-	_, err = DB.Exec(reactionTable)
+		return err
+	}
+
+	done := make(map[int]bool, len(applied))
+	for _, version := range applied {
+		done[version] = true
+	}
+
+	for _, m := range migrations {
+		if done[m.Version] {
+			continue
+		}
+		if err := runMigration(db, dialect, m, m.Up, true); err != nil {
+			return err
+		}
+		slog.Info("Applied migration", "version", m.Version, "name", m.Name)
+	}
+
+	return nil
+}
+
+// Rollback reverts the most recent applied migrations, newest first.
+func Rollback(db *sql.DB, dialect Dialect, steps int) error {
+	if err := checkNotAhead(db, dialect); err != nil {
+		return err
+	}
+
+	migrations, err := Migrations(dialect)
 	if err != nil {
-		errLog.Error.Printf("Failed to create reaction table: %v\n", err)
-		return
+		return err
+	}
+	applied, err := AppliedVersions(db, dialect)
+	if err != nil {
+		return err
 	}
 
-	sessionTable := `
-    CREATE TABLE IF NOT EXISTS sessions (
-        id INTEGER PRIMARY KEY AUTOINCREMENT,
-        user_id INTEGER NOT NULL,
-        session_token TEXT UNIQUE NOT NULL,
-        expires_at DATETIME NOT NULL,
-		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-        FOREIGN KEY (user_id) REFERENCES users (id)
-    );`
+	byVersion := make(map[int]Migration, len(migrations))
+	for _, m := range migrations {
+		byVersion[m.Version] = m
+	}
+
+	for i := len(applied) - 1; i >= 0 && steps > 0; i-- {
+		m := byVersion[applied[i]]
+		if err := runMigration(db, dialect, m, m.Down, false); err != nil {
+			return err
+		}
+		slog.Info("Rolled back migration", "version", m.Version, "name", m.Name)
+		steps--
+	}
+
+	return nil
+}
 
-	_, err = DB.Exec(sessionTable)
+func runMigration(db *sql.DB, dialect Dialect, m Migration, script string, up bool) error {
+	tx, err := db.Begin()
 	if err != nil {
-		errLog.Error.Printf("Failed to create sessions table: %v\n", err)
-		return
-	}
-
-	commentTable := `
-    CREATE TABLE IF NOT EXISTS comments (
-        id INTEGER PRIMARY KEY AUTOINCREMENT,
-        post_id INTEGER NOT NULL,
-        user_id INTEGER NOT NULL,
-        username TEXT NOT NULL,
-        content TEXT NOT NULL,
-        created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-        FOREIGN KEY (post_id) REFERENCES posts (id),
-        FOREIGN KEY (user_id) REFERENCES users (id)
-    );`
-	_, err = DB.Exec(commentTable)
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(script); err != nil {
+		return fmt.Errorf("migration %04d_%s failed: %v", m.Version, m.Name, err)
+	}
+
+	if up {
+		_, err = tx.Exec(dialect.Rebind(`INSERT INTO schema_migrations (version, name) VALUES (?, ?)`), m.Version, m.Name)
+	} else {
+		_, err = tx.Exec(dialect.Rebind(`DELETE FROM schema_migrations WHERE version = ?`), m.Version)
+	}
 	if err != nil {
-		errLog.Error.Printf("Failed to create comments table: %v\n", err)
-	}
-
-	messageTable := `
-	CREATE TABLE IF NOT EXISTS messages (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		sender_id TEXT NOT NULL,
-		receiver_id TEXT NOT NULL,
-		sender INTEGER NOT NULL,
-		receiver INTEGER NOT NULL,
-		content TEXT NOT NULL,
-		timestamp DATETIME DEFAULT CURRENT_TIMESTAMP,
-		FOREIGN KEY (sender) REFERENCES users(id) ON DELETE CASCADE,
-		FOREIGN KEY (receiver) REFERENCES users(id) ON DELETE CASCADE
-	);
-	
-	CREATE INDEX IF NOT EXISTS idx_messages_timestamp ON messages(timestamp);`
-	_, err = DB.Exec(messageTable)
+		return fmt.Errorf("failed to record migration %04d_%s: %v", m.Version, m.Name, err)
+	}
+
+	return tx.Commit()
+}
+
+func checkNotAhead(db *sql.DB, dialect Dialect) error {
+	err := CheckSchema(db, dialect)
+	if errors.Is(err, ErrSchemaAhead) {
+		return err
+	}
+	return nil
+}
+
+// CheckSchema reports whether the database schema matches the migrations
+// compiled into this binary.
+func CheckSchema(db *sql.DB, dialect Dialect) error {
+	migrations, err := Migrations(dialect)
 	if err != nil {
-		errLog.Error.Printf("Failed to create message table: %v\n", err)
-		return
-	}
-
-	commentReactionTable := `
-    CREATE TABLE IF NOT EXISTS comment_reactions (
-        user_id INTEGER,
-        comment_id INTEGER,
-        reaction TEXT CHECK(reaction IN ('like', 'dislike')),
-        PRIMARY KEY (user_id, comment_id),
-        FOREIGN KEY (user_id) REFERENCES users(id),
-        FOREIGN KEY (comment_id) REFERENCES comments(id)
-    );`
-	_, err = DB.Exec(commentReactionTable)
+		return err
+	}
+	applied, err := AppliedVersions(db, dialect)
 	if err != nil {
-		errLog.Error.Printf("Failed to create comment_reactions table: %v\n", err)
-		return
+		return err
+	}
+
+	known := make(map[int]bool, len(migrations))
+	for _, m := range migrations {
+		known[m.Version] = true
 	}
+	for _, version := range applied {
+		if !known[version] {
+			return fmt.Errorf("%w: unknown migration %d", ErrSchemaAhead, version)
+		}
+	}
+
+	if len(applied) < len(migrations) {
+		return fmt.Errorf("%w: %d of %d migrations applied", ErrSchemaBehind, len(applied), len(migrations))
+	}
+
+	return nil
 }
@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS comment_reactions;
+DROP TABLE IF EXISTS messages;
+DROP TABLE IF EXISTS comments;
+DROP TABLE IF EXISTS sessions;
+DROP TABLE IF EXISTS post_reactions;
+DROP TABLE IF EXISTS posts;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,68 @@
+CREATE TABLE IF NOT EXISTS users (
+	id SERIAL PRIMARY KEY,
+	nickname VARCHAR(255) UNIQUE NOT NULL,
+	age INTEGER NOT NULL,
+	gender TEXT,
+	firstname TEXT NOT NULL,
+	lastname TEXT NOT NULL,
+	email VARCHAR(255) UNIQUE NOT NULL,
+	password CHAR(60) DEFAULT NULL,
+	created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_users_email ON users(email);
+
+CREATE TABLE IF NOT EXISTS posts (
+	id SERIAL PRIMARY KEY,
+	user_id INTEGER NOT NULL REFERENCES users (id),
+	title TEXT NOT NULL,
+	content TEXT NOT NULL,
+	categories TEXT NOT NULL,
+	image_url TEXT,
+	created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS post_reactions (
+	id SERIAL PRIMARY KEY,
+	user_id INTEGER NOT NULL REFERENCES users (id),
+	post_id INTEGER NOT NULL REFERENCES posts (id),
+	reaction TEXT CHECK(reaction IN ('like', 'dislike')) NOT NULL,
+	created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+	UNIQUE(user_id, post_id)
+);
+
+CREATE TABLE IF NOT EXISTS sessions (
+	id SERIAL PRIMARY KEY,
+	user_id INTEGER NOT NULL REFERENCES users (id),
+	session_token TEXT UNIQUE NOT NULL,
+	expires_at TIMESTAMPTZ NOT NULL,
+	created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS comments (
+	id SERIAL PRIMARY KEY,
+	post_id INTEGER NOT NULL REFERENCES posts (id),
+	user_id INTEGER NOT NULL REFERENCES users (id),
+	username TEXT NOT NULL,
+	content TEXT NOT NULL,
+	created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS messages (
+	id SERIAL PRIMARY KEY,
+	sender_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+	receiver_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+	sender TEXT NOT NULL,
+	receiver TEXT NOT NULL,
+	content TEXT NOT NULL,
+	timestamp TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_messages_timestamp ON messages(timestamp);
+
+CREATE TABLE IF NOT EXISTS comment_reactions (
+	user_id INTEGER REFERENCES users (id),
+	comment_id INTEGER REFERENCES comments (id),
+	reaction TEXT CHECK(reaction IN ('like', 'dislike')),
+	PRIMARY KEY (user_id, comment_id)
+);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_email_verifications_user;
+DROP TABLE IF EXISTS email_verifications;
+ALTER TABLE users DROP COLUMN verified_at;
@@ -0,0 +1,26 @@
+-- One row per user who started enrolling; enabled_at is set once the first
+-- code is confirmed. last_step is the newest time step accepted, so a code
+-- cannot be replayed.
+CREATE TABLE IF NOT EXISTS two_factor (
+	user_id INTEGER PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+	secret TEXT NOT NULL,
+	enabled_at TIMESTAMPTZ DEFAULT NULL,
+	last_step BIGINT NOT NULL DEFAULT 0,
+	created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS recovery_codes (
+	id SERIAL PRIMARY KEY,
+	user_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+	code_hash CHAR(64) NOT NULL,
+	used_at TIMESTAMPTZ DEFAULT NULL,
+	UNIQUE(user_id, code_hash)
+);
+
+-- Logins that passed the password check and wait for a second factor.
+CREATE TABLE IF NOT EXISTS pending_logins (
+	token_hash CHAR(64) PRIMARY KEY,
+	user_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+	expires_at TIMESTAMPTZ NOT NULL,
+	attempts INTEGER NOT NULL DEFAULT 0
+);
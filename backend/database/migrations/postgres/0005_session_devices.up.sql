@@ -0,0 +1,7 @@
+ALTER TABLE sessions ADD COLUMN user_agent TEXT NOT NULL DEFAULT '';
+ALTER TABLE sessions ADD COLUMN ip TEXT NOT NULL DEFAULT '';
+ALTER TABLE sessions ADD COLUMN last_seen_at TIMESTAMPTZ DEFAULT NULL;
+
+UPDATE sessions SET last_seen_at = created_at;
+
+CREATE INDEX IF NOT EXISTS idx_sessions_user ON sessions(user_id);
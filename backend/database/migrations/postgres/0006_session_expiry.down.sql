@@ -0,0 +1 @@
+ALTER TABLE sessions DROP COLUMN max_expires_at;
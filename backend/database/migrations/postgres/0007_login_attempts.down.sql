@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_account_unlocks_user;
+DROP TABLE IF EXISTS account_unlocks;
+DROP INDEX IF EXISTS idx_login_attempts_ip;
+DROP INDEX IF EXISTS idx_login_attempts_user;
+DROP TABLE IF EXISTS login_attempts;
+ALTER TABLE users DROP COLUMN locked_until;
+ALTER TABLE users DROP COLUMN failed_logins;
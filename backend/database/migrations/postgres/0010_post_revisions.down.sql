@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_post_revisions_post;
+DROP TABLE IF EXISTS post_revisions;
+ALTER TABLE posts DROP COLUMN updated_by;
+ALTER TABLE posts DROP COLUMN updated_at;
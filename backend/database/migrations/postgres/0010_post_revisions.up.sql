@@ -0,0 +1,19 @@
+-- When and by whom a post was last edited; NULL until its first edit.
+ALTER TABLE posts ADD COLUMN updated_at TIMESTAMPTZ DEFAULT NULL;
+ALTER TABLE posts ADD COLUMN updated_by INTEGER DEFAULT NULL REFERENCES users (id) ON DELETE SET NULL;
+
+-- Every version of a post that an edit replaced, numbered from 1 for the
+-- original, with who wrote that version and when.
+CREATE TABLE IF NOT EXISTS post_revisions (
+	id SERIAL PRIMARY KEY,
+	post_id INTEGER NOT NULL REFERENCES posts (id) ON DELETE CASCADE,
+	revision INTEGER NOT NULL,
+	title TEXT NOT NULL,
+	content TEXT NOT NULL,
+	categories TEXT NOT NULL,
+	image_url TEXT,
+	editor_id INTEGER NOT NULL REFERENCES users (id),
+	edited_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_post_revisions_post ON post_revisions(post_id, revision);
@@ -0,0 +1,13 @@
+-- Replies: parent_id is the comment replied to, NULL for top-level
+-- comments, and depth counts the ancestors. path lists the zero-padded IDs
+-- from the top-level comment down to this one, separated by "/", so
+-- ordering by it lays a thread out depth first and a prefix match finds a
+-- subtree.
+ALTER TABLE comments ADD COLUMN parent_id INTEGER DEFAULT NULL REFERENCES comments (id);
+ALTER TABLE comments ADD COLUMN depth INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE comments ADD COLUMN path TEXT NOT NULL DEFAULT '';
+
+UPDATE comments SET path = lpad(id::text, 10, '0');
+
+CREATE INDEX IF NOT EXISTS idx_comments_post_path ON comments(post_id, path);
+CREATE INDEX IF NOT EXISTS idx_comments_parent ON comments(parent_id);
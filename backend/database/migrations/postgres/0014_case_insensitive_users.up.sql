@@ -0,0 +1,6 @@
+-- Nicknames and emails are unique regardless of case. Emails are stored in
+-- lower case from now on; this fails if two accounts differ only by case,
+-- which must then be resolved by hand.
+UPDATE users SET email = lower(email);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_nickname_lower ON users(lower(nickname));
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email_lower ON users(lower(email));
@@ -0,0 +1,9 @@
+DROP TABLE IF EXISTS comment_reactions;
+DROP INDEX IF EXISTS idx_messages_timestamp;
+DROP TABLE IF EXISTS messages;
+DROP TABLE IF EXISTS comments;
+DROP TABLE IF EXISTS sessions;
+DROP TABLE IF EXISTS post_reactions;
+DROP TABLE IF EXISTS posts;
+DROP INDEX IF EXISTS idx_users_email;
+DROP TABLE IF EXISTS users;
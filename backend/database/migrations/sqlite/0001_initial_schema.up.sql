@@ -0,0 +1,78 @@
+CREATE TABLE IF NOT EXISTS users (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	nickname VARCHAR(255) UNIQUE NOT NULL,
+	age INTEGER NOT NULL,
+	gender TEXT,
+	firstname TEXT NOT NULL,
+	lastname TEXT NOT NULL,
+	email VARCHAR(255) UNIQUE NOT NULL,
+	password CHAR(60) DEFAULT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_users_email ON users(email);
+
+CREATE TABLE IF NOT EXISTS posts (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER NOT NULL,
+	title TEXT NOT NULL,
+	content TEXT NOT NULL,
+	categories TEXT NOT NULL,
+	image_url TEXT,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (user_id) REFERENCES users (id)
+);
+
+CREATE TABLE IF NOT EXISTS post_reactions (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER NOT NULL,
+	post_id INTEGER NOT NULL,
+	reaction TEXT CHECK(reaction IN ('like', 'dislike')) NOT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (user_id) REFERENCES users (id),
+	FOREIGN KEY (post_id) REFERENCES posts (id),
+	UNIQUE(user_id, post_id)
+);
+
+CREATE TABLE IF NOT EXISTS sessions (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER NOT NULL,
+	session_token TEXT UNIQUE NOT NULL,
+	expires_at DATETIME NOT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (user_id) REFERENCES users (id)
+);
+
+CREATE TABLE IF NOT EXISTS comments (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	post_id INTEGER NOT NULL,
+	user_id INTEGER NOT NULL,
+	username TEXT NOT NULL,
+	content TEXT NOT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (post_id) REFERENCES posts (id),
+	FOREIGN KEY (user_id) REFERENCES users (id)
+);
+
+CREATE TABLE IF NOT EXISTS messages (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	sender_id TEXT NOT NULL,
+	receiver_id TEXT NOT NULL,
+	sender INTEGER NOT NULL,
+	receiver INTEGER NOT NULL,
+	content TEXT NOT NULL,
+	timestamp DATETIME DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (sender) REFERENCES users(id) ON DELETE CASCADE,
+	FOREIGN KEY (receiver) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_messages_timestamp ON messages(timestamp);
+
+CREATE TABLE IF NOT EXISTS comment_reactions (
+	user_id INTEGER,
+	comment_id INTEGER,
+	reaction TEXT CHECK(reaction IN ('like', 'dislike')),
+	PRIMARY KEY (user_id, comment_id),
+	FOREIGN KEY (user_id) REFERENCES users(id),
+	FOREIGN KEY (comment_id) REFERENCES comments(id)
+);
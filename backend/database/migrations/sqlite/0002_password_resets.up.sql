@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS password_resets (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER NOT NULL,
+	token_hash CHAR(64) UNIQUE NOT NULL,
+	expires_at DATETIME NOT NULL,
+	used_at DATETIME DEFAULT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_password_resets_user ON password_resets(user_id);
@@ -0,0 +1,16 @@
+ALTER TABLE users ADD COLUMN verified_at DATETIME DEFAULT NULL;
+
+-- Accounts created before verification existed keep working as they did.
+UPDATE users SET verified_at = CURRENT_TIMESTAMP;
+
+CREATE TABLE IF NOT EXISTS email_verifications (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER NOT NULL,
+	token_hash CHAR(64) UNIQUE NOT NULL,
+	expires_at DATETIME NOT NULL,
+	used_at DATETIME DEFAULT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_email_verifications_user ON email_verifications(user_id);
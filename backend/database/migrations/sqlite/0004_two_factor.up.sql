@@ -0,0 +1,29 @@
+-- One row per user who started enrolling; enabled_at is set once the first
+-- code is confirmed. last_step is the newest time step accepted, so a code
+-- cannot be replayed.
+CREATE TABLE IF NOT EXISTS two_factor (
+	user_id INTEGER PRIMARY KEY,
+	secret TEXT NOT NULL,
+	enabled_at DATETIME DEFAULT NULL,
+	last_step INTEGER NOT NULL DEFAULT 0,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS recovery_codes (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER NOT NULL,
+	code_hash CHAR(64) NOT NULL,
+	used_at DATETIME DEFAULT NULL,
+	FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+	UNIQUE(user_id, code_hash)
+);
+
+-- Logins that passed the password check and wait for a second factor.
+CREATE TABLE IF NOT EXISTS pending_logins (
+	token_hash CHAR(64) PRIMARY KEY,
+	user_id INTEGER NOT NULL,
+	expires_at DATETIME NOT NULL,
+	attempts INTEGER NOT NULL DEFAULT 0,
+	FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
@@ -0,0 +1,4 @@
+-- The latest a session may be renewed to; expires_at slides up to it.
+ALTER TABLE sessions ADD COLUMN max_expires_at DATETIME DEFAULT NULL;
+
+UPDATE sessions SET max_expires_at = expires_at;
@@ -0,0 +1,30 @@
+-- Consecutive failed logins since the last success or unlock, and the
+-- temporary lock they lead to.
+ALTER TABLE users ADD COLUMN failed_logins INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE users ADD COLUMN locked_until DATETIME DEFAULT NULL;
+
+-- user_id is NULL when the identity matched no account.
+CREATE TABLE IF NOT EXISTS login_attempts (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER DEFAULT NULL,
+	ip TEXT NOT NULL,
+	user_agent TEXT NOT NULL DEFAULT '',
+	success BOOLEAN NOT NULL,
+	created_at DATETIME NOT NULL,
+	FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_login_attempts_user ON login_attempts(user_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_login_attempts_ip ON login_attempts(ip, created_at);
+
+CREATE TABLE IF NOT EXISTS account_unlocks (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER NOT NULL,
+	token_hash CHAR(64) UNIQUE NOT NULL,
+	expires_at DATETIME NOT NULL,
+	used_at DATETIME DEFAULT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_account_unlocks_user ON account_unlocks(user_id);
@@ -0,0 +1,5 @@
+-- What the account may do beyond using the forum: "user", "moderator" or
+-- "admin".
+ALTER TABLE users ADD COLUMN role VARCHAR(20) NOT NULL DEFAULT 'user';
+
+CREATE INDEX IF NOT EXISTS idx_users_role ON users(role);
@@ -0,0 +1,27 @@
+-- A user's complaint about a post, comment or private message. The
+-- reported account is kept so reports survive edits to the content. A
+-- moderator claims an open report and resolves it as actioned or dismissed.
+CREATE TABLE IF NOT EXISTS reports (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	reporter_id INTEGER NOT NULL,
+	target_type VARCHAR(10) NOT NULL CHECK(target_type IN ('post', 'comment', 'message')),
+	target_id INTEGER NOT NULL,
+	reported_user_id INTEGER NOT NULL,
+	reason VARCHAR(20) NOT NULL,
+	details TEXT NOT NULL DEFAULT '',
+	status VARCHAR(10) NOT NULL DEFAULT 'open' CHECK(status IN ('open', 'actioned', 'dismissed')),
+	claimed_by INTEGER DEFAULT NULL,
+	claimed_at DATETIME DEFAULT NULL,
+	resolved_by INTEGER DEFAULT NULL,
+	resolved_at DATETIME DEFAULT NULL,
+	resolution TEXT NOT NULL DEFAULT '',
+	created_at DATETIME NOT NULL,
+	FOREIGN KEY (reporter_id) REFERENCES users (id) ON DELETE CASCADE,
+	FOREIGN KEY (reported_user_id) REFERENCES users (id) ON DELETE CASCADE,
+	FOREIGN KEY (claimed_by) REFERENCES users (id) ON DELETE SET NULL,
+	FOREIGN KEY (resolved_by) REFERENCES users (id) ON DELETE SET NULL
+);
+
+-- One report per user and piece of content.
+CREATE UNIQUE INDEX IF NOT EXISTS idx_reports_reporter_target ON reports(reporter_id, target_type, target_id);
+CREATE INDEX IF NOT EXISTS idx_reports_status ON reports(status, created_at);
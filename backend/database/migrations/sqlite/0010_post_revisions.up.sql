@@ -0,0 +1,23 @@
+-- When and by whom a post was last edited; NULL until its first edit.
+-- SQLite cannot drop a column that is part of a foreign key, so updated_by
+-- goes without one here.
+ALTER TABLE posts ADD COLUMN updated_at DATETIME DEFAULT NULL;
+ALTER TABLE posts ADD COLUMN updated_by INTEGER DEFAULT NULL;
+
+-- Every version of a post that an edit replaced, numbered from 1 for the
+-- original, with who wrote that version and when.
+CREATE TABLE IF NOT EXISTS post_revisions (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	post_id INTEGER NOT NULL,
+	revision INTEGER NOT NULL,
+	title TEXT NOT NULL,
+	content TEXT NOT NULL,
+	categories TEXT NOT NULL,
+	image_url TEXT,
+	editor_id INTEGER NOT NULL,
+	edited_at DATETIME NOT NULL,
+	FOREIGN KEY (post_id) REFERENCES posts (id) ON DELETE CASCADE,
+	FOREIGN KEY (editor_id) REFERENCES users (id)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_post_revisions_post ON post_revisions(post_id, revision);
@@ -0,0 +1,11 @@
+-- Deleted posts and comments stay as tombstones, restorable by a moderator,
+-- until the purge removes them. deleted_by is whoever deleted them, the
+-- author or a moderator. SQLite cannot drop a column that is part of a
+-- foreign key, so deleted_by goes without one here.
+ALTER TABLE posts ADD COLUMN deleted_at DATETIME DEFAULT NULL;
+ALTER TABLE posts ADD COLUMN deleted_by INTEGER DEFAULT NULL;
+ALTER TABLE comments ADD COLUMN deleted_at DATETIME DEFAULT NULL;
+ALTER TABLE comments ADD COLUMN deleted_by INTEGER DEFAULT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_posts_deleted_at ON posts(deleted_at);
+CREATE INDEX IF NOT EXISTS idx_comments_deleted_at ON comments(deleted_at);
@@ -0,0 +1,14 @@
+-- Replies: parent_id is the comment replied to, NULL for top-level
+-- comments, and depth counts the ancestors. path lists the zero-padded IDs
+-- from the top-level comment down to this one, separated by "/", so
+-- ordering by it lays a thread out depth first and a prefix match finds a
+-- subtree. SQLite cannot drop a column that is part of a foreign key, so
+-- parent_id goes without one here.
+ALTER TABLE comments ADD COLUMN parent_id INTEGER DEFAULT NULL;
+ALTER TABLE comments ADD COLUMN depth INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE comments ADD COLUMN path TEXT NOT NULL DEFAULT '';
+
+UPDATE comments SET path = printf('%010d', id);
+
+CREATE INDEX IF NOT EXISTS idx_comments_post_path ON comments(post_id, path);
+CREATE INDEX IF NOT EXISTS idx_comments_parent ON comments(parent_id);
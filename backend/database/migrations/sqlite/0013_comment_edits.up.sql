@@ -0,0 +1,2 @@
+-- When a comment was last edited by its author; NULL until its first edit.
+ALTER TABLE comments ADD COLUMN updated_at DATETIME DEFAULT NULL;
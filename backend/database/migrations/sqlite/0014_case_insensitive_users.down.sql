@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_users_email_nocase;
+DROP INDEX IF EXISTS idx_users_nickname_nocase;
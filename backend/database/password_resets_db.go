@@ -0,0 +1,101 @@
+package database
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrInvalidToken means a one-time token is unknown, already used or expired.
+var ErrInvalidToken = errors.New("invalid or expired token")
+
+// CreatePasswordReset stores the hash of a new reset token for the user and
+// drops the user's tokens that can no longer be used.
+func (s *Store) CreatePasswordReset(userID int, tokenHash string, expiresAt time.Time) error {
+	query := `
+	DELETE FROM password_resets
+	WHERE user_id = ? AND (used_at IS NOT NULL OR expires_at < ?)`
+
+	if _, err := s.exec(query, userID, time.Now()); err != nil {
+		return err
+	}
+
+	query = `
+	INSERT INTO password_resets (user_id, token_hash, expires_at)
+	VALUES (?, ?, ?)`
+
+	_, err := s.exec(query, userID, tokenHash, expiresAt)
+
+	return err
+}
+
+// ResetPassword spends the reset token, sets the new password hash and ends
+// every session of the user, returning the user and the ended session
+// tokens so their open connections can be closed. It returns
+// ErrInvalidToken when the token cannot be used.
+func (s *Store) ResetPassword(tokenHash, passwordHash string) (int, []string, error) {
+	var userID int
+	var tokens []string
+
+	err := s.withTx(func(t tx) error {
+		now := time.Now()
+
+		// Claiming the token with a conditional UPDATE keeps it single-use
+		// even when two resets race.
+		result, err := t.exec(`
+		UPDATE password_resets
+		SET used_at = ?
+		WHERE token_hash = ? AND used_at IS NULL AND expires_at > ?`,
+			now, tokenHash, now)
+		if err != nil {
+			return err
+		}
+		if n, err := result.RowsAffected(); err != nil {
+			return err
+		} else if n == 0 {
+			return ErrInvalidToken
+		}
+
+		err = t.queryRow(`SELECT user_id FROM password_resets WHERE token_hash = ?`, tokenHash).Scan(&userID)
+		if err != nil {
+			return err
+		}
+
+		if _, err := t.exec(`UPDATE users SET password = ? WHERE id = ?`, passwordHash, userID); err != nil {
+			return err
+		}
+
+		// Any other link still in the user's inbox stops working too.
+		if _, err := t.exec(`
+		UPDATE password_resets
+		SET used_at = ?
+		WHERE user_id = ? AND used_at IS NULL`,
+			now, userID); err != nil {
+			return err
+		}
+
+		rows, err := t.query(`SELECT session_token FROM sessions WHERE user_id = ?`, userID)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var token string
+			if err := rows.Scan(&token); err != nil {
+				return err
+			}
+			tokens = append(tokens, token)
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		_, err = t.exec(`DELETE FROM sessions WHERE user_id = ?`, userID)
+		return err
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return userID, tokens, nil
+}
@@ -2,43 +2,143 @@ package database
 
 import (
 	"database/sql"
+	"fmt"
 	"strings"
+	"time"
 
 	"github.com/nyagooh/Real-time-forum.git/backend/models"
-
-	_ "github.com/mattn/go-sqlite3"
 )
 
-func InsertPost(userID int, post *models.Post) (int64, error) {
+func (s *Store) InsertPost(userID int, post *models.Post) (int64, error) {
 	query := `INSERT INTO posts (user_id, title, content, categories, image_url, created_at)
 	VALUES (?, ?, ?, ?, ?, ?)`
 
-	result, err := DB.Exec(query, userID, post.Title, post.Content, strings.Join(post.Category, ","), post.ImageURL, post.CreatedAt)
+	return s.insert(query, userID, post.Title, post.Content, strings.Join(post.Category, ","), post.ImageURL, post.CreatedAt)
+}
+
+// GetPost returns one post without its reactions and comments, and the ID
+// of its author, or sql.ErrNoRows if it does not exist or was deleted.
+func (s *Store) GetPost(postID int) (*models.Post, int, error) {
+	query := `
+	SELECT p.id, p.title, p.content, p.categories, COALESCE(p.image_url, ''), u.nickname, p.created_at, p.updated_at, p.user_id
+	FROM posts p
+	JOIN users u ON p.user_id = u.id
+	WHERE p.id = ? AND p.deleted_at IS NULL`
+
+	post := &models.Post{}
+	var categories string
+	var updatedAt sql.NullString
+	var authorID int
+	err := s.queryRow(query, postID).Scan(&post.ID, &post.Title, &post.Content, &categories,
+		&post.ImageURL, &post.Username, &post.CreatedAt, &updatedAt, &authorID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	post.Category = strings.Split(categories, ",")
+	post.Edited, post.UpdatedAt = updatedAt.Valid, updatedAt.String
+	return post, authorID, nil
+}
+
+// UpdatePost replaces the post's title, content, categories and image with
+// those of post, keeping the version it replaces as a revision. It returns
+// sql.ErrNoRows for an unknown or deleted post.
+func (s *Store) UpdatePost(postID, editorID int, post *models.Post) error {
+	now := time.Now().Format(time.RFC3339)
+
+	return s.withTx(func(t tx) error {
+		// The replaced version was written by whoever last edited the post,
+		// or its author, at the time of that edit.
+		result, err := t.exec(`
+		INSERT INTO post_revisions (post_id, revision, title, content, categories, image_url, editor_id, edited_at)
+		SELECT p.id, (SELECT COUNT(*) FROM post_revisions WHERE post_id = p.id) + 1,
+			p.title, p.content, p.categories, p.image_url,
+			COALESCE(p.updated_by, p.user_id), COALESCE(p.updated_at, p.created_at)
+		FROM posts p
+		WHERE p.id = ? AND p.deleted_at IS NULL`, postID)
+		if err != nil {
+			return fmt.Errorf("database error: %v", err)
+		}
+		if n, err := result.RowsAffected(); err != nil {
+			return err
+		} else if n == 0 {
+			return sql.ErrNoRows
+		}
+
+		_, err = t.exec(`
+		UPDATE posts
+		SET title = ?, content = ?, categories = ?, image_url = ?, updated_at = ?, updated_by = ?
+		WHERE id = ?`,
+			post.Title, post.Content, strings.Join(post.Category, ","), post.ImageURL, now, editorID, postID)
+		if err != nil {
+			return fmt.Errorf("database error: %v", err)
+		}
+
+		post.Edited, post.UpdatedAt = true, now
+		return nil
+	})
+}
+
+// ListPostRevisions returns every version of the post, oldest first and
+// ending with the current one, or sql.ErrNoRows for an unknown or deleted
+// post.
+func (s *Store) ListPostRevisions(postID int) ([]models.PostRevision, error) {
+	rows, err := s.query(`
+	SELECT r.revision, r.title, r.content, r.categories, COALESCE(r.image_url, ''), u.nickname, r.edited_at
+	FROM post_revisions r
+	JOIN users u ON r.editor_id = u.id
+	WHERE r.post_id = ?
+	ORDER BY r.revision`, postID)
 	if err != nil {
-		return 0, err
+		return nil, fmt.Errorf("database error: %v", err)
 	}
+	defer rows.Close()
 
-	// Retrieve the last inserted ID
-	postID, err := result.LastInsertId()
+	var revisions []models.PostRevision
+	for rows.Next() {
+		var rev models.PostRevision
+		var categories string
+		if err := rows.Scan(&rev.Revision, &rev.Title, &rev.Content, &categories, &rev.ImageURL, &rev.Editor, &rev.EditedAt); err != nil {
+			return nil, fmt.Errorf("database error: %v", err)
+		}
+		rev.Category = strings.Split(categories, ",")
+		revisions = append(revisions, rev)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	current := models.PostRevision{Revision: len(revisions) + 1}
+	var categories string
+	err = s.queryRow(`
+	SELECT p.title, p.content, p.categories, COALESCE(p.image_url, ''), u.nickname, COALESCE(p.updated_at, p.created_at)
+	FROM posts p
+	JOIN users u ON COALESCE(p.updated_by, p.user_id) = u.id
+	WHERE p.id = ? AND p.deleted_at IS NULL`, postID).Scan(&current.Title, &current.Content, &categories, &current.ImageURL, &current.Editor, &current.EditedAt)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
+	current.Category = strings.Split(categories, ",")
 
-	return postID, nil
+	return append(revisions, current), nil
 }
 
-func GetAllPosts(category string) ([]*models.Post, error) {
+func (s *Store) GetAllPosts(category string) ([]*models.Post, error) {
 	var rows *sql.Rows
 	var err error
-	query := `
+	query := fmt.Sprintf(`
 	SELECT p.title, p.content, p.categories, p.image_url, u.nickname,
-	IFNULL(likes.count, 0) AS likes,
-	IFNULL(dislikes.count, 0) AS dislikes,
+	COALESCE(likes.count, 0) AS likes,
+	COALESCE(dislikes.count, 0) AS dislikes,
 	p.created_at,
+	p.updated_at,
+	p.deleted_at,
+	COALESCE(p.deleted_by, 0),
+	p.user_id,
 	p.id,
-	IFNULL(comments.count, 0) AS comments_count,
-	IFNULL(liked_by.usernames, '') AS liked_by,
-	IFNULL(disliked_by.usernames, '') AS disliked_by
+	COALESCE(comments.count, 0) AS comments_count,
+	COALESCE(liked_by.usernames, '') AS liked_by,
+	COALESCE(disliked_by.usernames, '') AS disliked_by
 	FROM posts p
 	JOIN users u ON p.user_id = u.id
 	LEFT JOIN (
@@ -59,27 +159,27 @@ func GetAllPosts(category string) ([]*models.Post, error) {
 		GROUP BY post_id
 	) AS comments ON p.id = comments.post_id
 	LEFT JOIN (
-		SELECT pr.post_id, GROUP_CONCAT(u.nickname) AS usernames
+		SELECT pr.post_id, %[1]s AS usernames
 		FROM post_reactions pr
 		JOIN users u ON pr.user_id = u.id
 		WHERE pr.reaction = 'like'
 		GROUP BY pr.post_id
 	) AS liked_by ON p.id = liked_by.post_id
 	LEFT JOIN (
-		SELECT pr.post_id, GROUP_CONCAT(u.nickname) AS usernames
+		SELECT pr.post_id, %[1]s AS usernames
 		FROM post_reactions pr
 		JOIN users u ON pr.user_id = u.id
 		WHERE pr.reaction = 'dislike'
 		GROUP BY pr.post_id
-	) AS disliked_by ON p.id = disliked_by.post_id`
+	) AS disliked_by ON p.id = disliked_by.post_id`, s.dialect.groupConcat("u.nickname"))
 
 	if category != "" {
 		query += ` WHERE p.categories LIKE '%' || ? || '%'`
 		query += ` ORDER BY p.created_at DESC`
-		rows, err = DB.Query(query, category)
+		rows, err = s.query(query, category)
 	} else {
 		query += ` ORDER BY p.created_at DESC`
-		rows, err = DB.Query(query)
+		rows, err = s.query(query)
 	}
 
 	if err != nil {
@@ -93,6 +193,8 @@ func GetAllPosts(category string) ([]*models.Post, error) {
 		var categories string
 		var commentsCount int
 		var likedByStr, dislikedByStr string
+		var updatedAt, deletedAt sql.NullString
+		var deletedBy, authorID int
 		err := rows.Scan(
 			&post.Title,
 			&post.Content,
@@ -102,6 +204,10 @@ func GetAllPosts(category string) ([]*models.Post, error) {
 			&post.Likes,
 			&post.Dislikes,
 			&post.CreatedAt,
+			&updatedAt,
+			&deletedAt,
+			&deletedBy,
+			&authorID,
 			&post.ID,
 			&commentsCount,
 			&likedByStr,
@@ -112,6 +218,7 @@ func GetAllPosts(category string) ([]*models.Post, error) {
 		}
 
 		post.Category = strings.Split(categories, ",")
+		post.Edited, post.UpdatedAt = updatedAt.Valid, updatedAt.String
 		// Parse likedBy and dislikedBy strings into arrays
 		if likedByStr != "" {
 			post.LikedBy = strings.Split(likedByStr, ",")
@@ -123,89 +230,15 @@ func GetAllPosts(category string) ([]*models.Post, error) {
 		} else {
 			post.DislikedBy = []string{}
 		}
+		if deletedAt.Valid {
+			tombstonePost(post, deletedAt.String, deletedBy, authorID)
+		}
 
-		// Now fetch the comments for this post
-		commentRows, err := DB.Query(`
-		SELECT 
-			c.id, 
-			c.content, 
-			c.user_id, 
-			u.nickname, 
-			c.created_at,
-			IFNULL(likes.count, 0) AS likes,
-			IFNULL(dislikes.count, 0) AS dislikes,
-			IFNULL(liked_by.usernames, '') AS liked_by,
-			IFNULL(disliked_by.usernames, '') AS disliked_by
-		FROM comments c
-		JOIN users u ON c.user_id = u.id
-		LEFT JOIN (
-			SELECT comment_id, COUNT(*) AS count
-			FROM comment_reactions
-			WHERE reaction = 'like'
-			GROUP BY comment_id
-		) AS likes ON c.id = likes.comment_id
-		LEFT JOIN (
-			SELECT comment_id, COUNT(*) AS count
-			FROM comment_reactions
-			WHERE reaction = 'dislike'
-			GROUP BY comment_id
-		) AS dislikes ON c.id = dislikes.comment_id
-		LEFT JOIN (
-			SELECT cr.comment_id, GROUP_CONCAT(u.nickname) AS usernames
-			FROM comment_reactions cr
-			JOIN users u ON cr.user_id = u.id
-			WHERE cr.reaction = 'like'
-			GROUP BY cr.comment_id
-		) AS liked_by ON c.id = liked_by.comment_id
-		LEFT JOIN (
-			SELECT cr.comment_id, GROUP_CONCAT(u.nickname) AS usernames
-			FROM comment_reactions cr
-			JOIN users u ON cr.user_id = u.id
-			WHERE cr.reaction = 'dislike'
-			GROUP BY cr.comment_id
-		) AS disliked_by ON c.id = disliked_by.comment_id
-		WHERE c.post_id = ?
-		ORDER BY c.created_at
-		`, post.ID)
+		// Now fetch the comments for this post, as a flat thread
+		comments, err := s.queryComments(`c.post_id = ?`, post.ID)
 		if err != nil {
 			return nil, err
 		}
-
-		var comments []models.Comment
-		for commentRows.Next() {
-			var comment models.Comment
-			var commentLikedByStr, commentDislikedByStr string
-			err := commentRows.Scan(
-				&comment.ID,
-				&comment.Content,
-				&comment.UserID,
-				&comment.Username,
-				&comment.CreatedAt,
-				&comment.Likes,
-				&comment.Dislikes,
-				&commentLikedByStr,
-				&commentDislikedByStr,
-			)
-			if err != nil {
-				commentRows.Close()
-				return nil, err
-			}
-
-			// Parse comment likedBy and dislikedBy strings into arrays
-			if commentLikedByStr != "" {
-				comment.LikedBy = strings.Split(commentLikedByStr, ",")
-			} else {
-				comment.LikedBy = []string{}
-			}
-			if commentDislikedByStr != "" {
-				comment.DislikedBy = strings.Split(commentDislikedByStr, ",")
-			} else {
-				comment.DislikedBy = []string{}
-			}
-
-			comments = append(comments, comment)
-		}
-		commentRows.Close()
 		post.Comments = comments
 		posts = append(posts, post)
 	}
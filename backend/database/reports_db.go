@@ -0,0 +1,201 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+	"unicode/utf8"
+
+	"github.com/nyagooh/Real-time-forum.git/backend/models"
+)
+
+var (
+	// ErrReportClosed means the report has already been resolved.
+	ErrReportClosed = errors.New("report is already resolved")
+	// ErrReportClaimed means another moderator is handling the report.
+	ErrReportClaimed = errors.New("report is claimed by another moderator")
+)
+
+// reportExcerptLength caps, in characters, how much of the reported content
+// moderators see in the queue.
+const reportExcerptLength = 200
+
+// ReportedUser returns the author of the content a report is about. Private
+// messages can only be reported by their receiver, and deleted posts and
+// comments not at all. It returns sql.ErrNoRows when there is no such
+// content for the reporter to report.
+func (s *Store) ReportedUser(targetType string, targetID, reporterID int) (int, error) {
+	var query string
+	args := []any{targetID}
+
+	switch targetType {
+	case models.ReportPost:
+		query = `SELECT user_id FROM posts WHERE id = ? AND deleted_at IS NULL`
+	case models.ReportComment:
+		query = `SELECT user_id FROM comments WHERE id = ? AND deleted_at IS NULL`
+	case models.ReportMessage:
+		query = `SELECT sender_id FROM messages WHERE id = ? AND receiver_id = ?`
+		args = append(args, reporterID)
+	default:
+		return 0, fmt.Errorf("unknown report target %q", targetType)
+	}
+
+	var userID int
+	if err := s.queryRow(query, args...).Scan(&userID); err != nil {
+		return 0, err
+	}
+	return userID, nil
+}
+
+// CreateReport files an open report and returns its ID. Reporting the same
+// content twice gives a *DuplicateError.
+func (s *Store) CreateReport(reporterID int, targetType string, targetID, reportedUserID int, reason, details string) (int, error) {
+	query := `
+	INSERT INTO reports (reporter_id, target_type, target_id, reported_user_id, reason, details, status, created_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+
+	id, err := s.insert(query, reporterID, targetType, targetID, reportedUserID, reason, details, models.ReportOpen, time.Now())
+	if column, ok := uniqueViolation(err); ok {
+		return 0, &DuplicateError{Field: column}
+	}
+	if err != nil {
+		return 0, fmt.Errorf("database error: %v", err)
+	}
+
+	return int(id), nil
+}
+
+const reportColumns = `
+	SELECT r.id, r.target_type, r.target_id, COALESCE(p.content, c.content, m.content, ''),
+		reporter.nickname, reported.nickname, r.reason, r.details, r.status,
+		COALESCE(claimer.nickname, ''), r.claimed_at, COALESCE(resolver.nickname, ''), r.resolved_at,
+		r.resolution, r.created_at
+	FROM reports r
+	JOIN users reporter ON reporter.id = r.reporter_id
+	JOIN users reported ON reported.id = r.reported_user_id
+	LEFT JOIN users claimer ON claimer.id = r.claimed_by
+	LEFT JOIN users resolver ON resolver.id = r.resolved_by
+	LEFT JOIN posts p ON r.target_type = 'post' AND p.id = r.target_id
+	LEFT JOIN comments c ON r.target_type = 'comment' AND c.id = r.target_id
+	LEFT JOIN messages m ON r.target_type = 'message' AND m.id = r.target_id`
+
+func scanReport(row interface{ Scan(...any) error }) (models.Report, error) {
+	var report models.Report
+	var claimedAt, resolvedAt sql.NullTime
+
+	err := row.Scan(&report.ID, &report.TargetType, &report.TargetID, &report.Excerpt,
+		&report.Reporter, &report.ReportedUser, &report.Reason, &report.Details, &report.Status,
+		&report.ClaimedBy, &claimedAt, &report.ResolvedBy, &resolvedAt,
+		&report.Resolution, &report.CreatedAt)
+	if err != nil {
+		return report, err
+	}
+
+	if claimedAt.Valid {
+		report.ClaimedAt = &claimedAt.Time
+	}
+	if resolvedAt.Valid {
+		report.ResolvedAt = &resolvedAt.Time
+	}
+	if utf8.RuneCountInString(report.Excerpt) > reportExcerptLength {
+		report.Excerpt = string([]rune(report.Excerpt)[:reportExcerptLength]) + "…"
+	}
+	return report, nil
+}
+
+// GetReport returns one report, or sql.ErrNoRows.
+func (s *Store) GetReport(id int) (*models.Report, error) {
+	report, err := scanReport(s.queryRow(reportColumns+` WHERE r.id = ?`, id))
+	if err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// ListReports returns the reports with the given status, oldest first so
+// the queue is worked in order.
+func (s *Store) ListReports(status string, limit, offset int) ([]models.Report, error) {
+	rows, err := s.query(reportColumns+`
+	WHERE r.status = ?
+	ORDER BY r.created_at, r.id
+	LIMIT ? OFFSET ?`, status, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("database error: %v", err)
+	}
+	defer rows.Close()
+
+	reports := []models.Report{}
+	for rows.Next() {
+		report, err := scanReport(rows)
+		if err != nil {
+			return nil, fmt.Errorf("database error: %v", err)
+		}
+		reports = append(reports, report)
+	}
+
+	return reports, rows.Err()
+}
+
+// ClaimReport marks an open report as handled by the moderator. Claiming a
+// report the moderator already holds is a no-op.
+func (s *Store) ClaimReport(id, moderatorID int) error {
+	query := `
+	UPDATE reports
+	SET claimed_by = ?, claimed_at = ?
+	WHERE id = ? AND status = ? AND claimed_by IS NULL`
+
+	result, err := s.exec(query, moderatorID, time.Now(), id, models.ReportOpen)
+	if err != nil {
+		return fmt.Errorf("database error: %v", err)
+	}
+	if n, err := result.RowsAffected(); err != nil || n > 0 {
+		return err
+	}
+
+	return s.reportConflict(id, moderatorID)
+}
+
+// ResolveReport closes an open report as actioned or dismissed with the
+// moderator's note, claiming it first if nobody has.
+func (s *Store) ResolveReport(id, moderatorID int, status, resolution string) error {
+	now := time.Now()
+	query := `
+	UPDATE reports
+	SET status = ?, resolution = ?, resolved_by = ?, resolved_at = ?,
+		claimed_by = COALESCE(claimed_by, ?), claimed_at = COALESCE(claimed_at, ?)
+	WHERE id = ? AND status = ? AND (claimed_by IS NULL OR claimed_by = ?)`
+
+	result, err := s.exec(query, status, resolution, moderatorID, now, moderatorID, now, id, models.ReportOpen, moderatorID)
+	if err != nil {
+		return fmt.Errorf("database error: %v", err)
+	}
+	if n, err := result.RowsAffected(); err != nil || n > 0 {
+		return err
+	}
+
+	if err := s.reportConflict(id, moderatorID); err != nil {
+		return err
+	}
+	// Only reachable if the report changed between the two queries.
+	return ErrReportClaimed
+}
+
+// reportConflict explains why a claim or resolution touched no row: the
+// report is unknown (sql.ErrNoRows), closed, or claimed by someone else. It
+// returns nil when the moderator holds the open report.
+func (s *Store) reportConflict(id, moderatorID int) error {
+	var status string
+	var claimedBy sql.NullInt64
+
+	err := s.queryRow(`SELECT status, claimed_by FROM reports WHERE id = ?`, id).Scan(&status, &claimedBy)
+	switch {
+	case err != nil:
+		return err
+	case status != models.ReportOpen:
+		return ErrReportClosed
+	case claimedBy.Valid && int(claimedBy.Int64) != moderatorID:
+		return ErrReportClaimed
+	}
+	return nil
+}
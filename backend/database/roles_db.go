@@ -0,0 +1,108 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/nyagooh/Real-time-forum.git/backend/models"
+)
+
+var (
+	// ErrAdminExists means the first admin has already been appointed.
+	ErrAdminExists = errors.New("an admin already exists")
+	// ErrLastAdmin means a role change would leave the forum without admins.
+	ErrLastAdmin = errors.New("the forum needs at least one admin")
+)
+
+// GetUserRole returns the account's role.
+func (s *Store) GetUserRole(userID int) (models.Role, error) {
+	var role models.Role
+	err := s.queryRow(`SELECT role FROM users WHERE id = ?`, userID).Scan(&role)
+	if err != nil {
+		return "", fmt.Errorf("database error: %v", err)
+	}
+	return role, nil
+}
+
+// SetUserRole gives the account a new role. It returns sql.ErrNoRows for
+// unknown accounts and ErrLastAdmin rather than demote the only admin.
+func (s *Store) SetUserRole(userID int, role models.Role) error {
+	return s.withTx(func(t tx) error {
+		var current models.Role
+		if err := t.queryRow(`SELECT role FROM users WHERE id = ?`, userID).Scan(&current); err != nil {
+			return err
+		}
+
+		if current == models.RoleAdmin && role != models.RoleAdmin {
+			var admins int
+			if err := t.queryRow(`SELECT COUNT(*) FROM users WHERE role = ?`, models.RoleAdmin).Scan(&admins); err != nil {
+				return err
+			}
+			if admins <= 1 {
+				return ErrLastAdmin
+			}
+		}
+
+		_, err := t.exec(`UPDATE users SET role = ? WHERE id = ?`, role, userID)
+		return err
+	})
+}
+
+// BootstrapAdmin makes the named account the forum's first admin and
+// returns its ID. It returns ErrAdminExists once there is an admin; later
+// admins are appointed by existing ones.
+func (s *Store) BootstrapAdmin(nickname string) (int, error) {
+	var userID int
+
+	err := s.withTx(func(t tx) error {
+		var admins int
+		if err := t.queryRow(`SELECT COUNT(*) FROM users WHERE role = ?`, models.RoleAdmin).Scan(&admins); err != nil {
+			return err
+		}
+		if admins > 0 {
+			return ErrAdminExists
+		}
+
+		if err := t.queryRow(`SELECT id FROM users WHERE nickname = ?`, nickname).Scan(&userID); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return fmt.Errorf("no user with nickname %q", nickname)
+			}
+			return err
+		}
+
+		_, err := t.exec(`UPDATE users SET role = ? WHERE id = ?`, models.RoleAdmin, userID)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return userID, nil
+}
+
+// ListStaff returns the moderators and admins, admins first.
+func (s *Store) ListStaff() ([]models.StaffMember, error) {
+	query := `
+	SELECT id, nickname, role
+	FROM users
+	WHERE role <> ?
+	ORDER BY CASE WHEN role = ? THEN 0 ELSE 1 END, nickname`
+
+	rows, err := s.query(query, models.RoleUser, models.RoleAdmin)
+	if err != nil {
+		return nil, fmt.Errorf("database error: %v", err)
+	}
+	defer rows.Close()
+
+	staff := []models.StaffMember{}
+	for rows.Next() {
+		var member models.StaffMember
+		if err := rows.Scan(&member.ID, &member.Nickname, &member.Role); err != nil {
+			return nil, fmt.Errorf("database error: %v", err)
+		}
+		staff = append(staff, member)
+	}
+
+	return staff, rows.Err()
+}
@@ -1,69 +1,168 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
-	"net/http"
+	"log/slog"
 	"time"
 
-	"github.com/nyagooh/Real-time-forum.git/backend/errLog"
 	"github.com/nyagooh/Real-time-forum.git/backend/models"
 )
 
-func InsertSession(id int, session string, expiresAt time.Time) error {
+// sessionTouchInterval is how stale last_seen_at may get before TouchSession
+// writes it again, so busy sessions do not write on every request.
+const sessionTouchInterval = time.Minute
+
+// InsertSession stores a new session for the device described by userAgent
+// and ip. A user may have any number of sessions. The session expires at
+// expiresAt unless renewed, and can be renewed up to maxExpiresAt.
+func (s *Store) InsertSession(id int, session string, expiresAt, maxExpiresAt time.Time, userAgent, ip string) error {
+	now := time.Now()
 	query := `
-	INSERT INTO sessions (user_id, session_token, expires_at)
-	VALUES (?, ?, ?)`
+	INSERT INTO sessions (user_id, session_token, expires_at, max_expires_at, user_agent, ip, created_at, last_seen_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
 
-	_, err := DB.Exec(query, id, session, expiresAt)
+	_, err := s.exec(query, id, session, expiresAt, maxExpiresAt, userAgent, ip, now, now)
 
 	return err
 }
 
-func GetSessionToken(id int) (string, error) {
-	var sessionToken string
+// TouchSession records that the session was just used. Once less than half
+// of lifetime is left it also pushes the expiry to lifetime from now, capped
+// at the session's max_expires_at, and returns the new expiry; otherwise it
+// returns the zero time.
+func (s *Store) TouchSession(session string, lifetime time.Duration) (time.Time, error) {
+	now := time.Now()
+
+	var expiresAt, maxExpiresAt time.Time
+	err := s.queryRow(`SELECT expires_at, max_expires_at FROM sessions WHERE session_token = ?`, session).Scan(&expiresAt, &maxExpiresAt)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	renewed := now.Add(lifetime)
+	if renewed.After(maxExpiresAt) {
+		renewed = maxExpiresAt
+	}
+	if expiresAt.Sub(now) < lifetime/2 && renewed.After(expiresAt) {
+		query := `
+		UPDATE sessions
+		SET expires_at = ?, last_seen_at = ?
+		WHERE session_token = ?`
+
+		if _, err := s.exec(query, renewed, now, session); err != nil {
+			return time.Time{}, err
+		}
+		return renewed, nil
+	}
 
 	query := `
-	SELECT session_token
+	UPDATE sessions
+	SET last_seen_at = ?
+	WHERE session_token = ? AND (last_seen_at IS NULL OR last_seen_at < ?)`
+
+	_, err = s.exec(query, now, session, now.Add(-sessionTouchInterval))
+
+	return time.Time{}, err
+}
+
+// ListSessions returns the user's live sessions, most recently used first,
+// marking the one whose token is current.
+func (s *Store) ListSessions(userID int, current string) ([]models.Session, error) {
+	query := `
+	SELECT id, user_agent, ip, created_at, last_seen_at, expires_at, session_token = ?
 	FROM sessions
-	WHERE user_id = ? AND expires_at > ?`
+	WHERE user_id = ? AND expires_at > ?
+	ORDER BY last_seen_at DESC, id DESC`
 
-	err := DB.QueryRow(query, id, time.Now()).Scan(&sessionToken)
+	rows, err := s.query(query, current, userID, time.Now())
 	if err != nil {
-		return "", err
+		return nil, fmt.Errorf("database error: %v", err)
 	}
+	defer rows.Close()
+
+	sessions := []models.Session{}
+	for rows.Next() {
+		var session models.Session
+		err := rows.Scan(&session.ID, &session.UserAgent, &session.IP, &session.CreatedAt,
+			&session.LastSeenAt, &session.ExpiresAt, &session.Current)
+		if err != nil {
+			return nil, fmt.Errorf("database error: %v", err)
+		}
+		sessions = append(sessions, session)
+	}
+
+	return sessions, rows.Err()
+}
+
+// DeleteUserSession revokes one of the user's sessions by ID and returns its
+// token. It returns sql.ErrNoRows if the user has no such session.
+func (s *Store) DeleteUserSession(userID, sessionID int) (string, error) {
+	var token string
+	err := s.withTx(func(t tx) error {
+		err := t.queryRow(`SELECT session_token FROM sessions WHERE id = ? AND user_id = ?`, sessionID, userID).Scan(&token)
+		if err != nil {
+			return err
+		}
+		_, err = t.exec(`DELETE FROM sessions WHERE id = ?`, sessionID)
+		return err
+	})
+
+	return token, err
+}
+
+// DeleteOtherSessions revokes every session of the user except the one with
+// token keep, and returns the revoked tokens.
+func (s *Store) DeleteOtherSessions(userID int, keep string) ([]string, error) {
+	var tokens []string
+	err := s.withTx(func(t tx) error {
+		rows, err := t.query(`SELECT session_token FROM sessions WHERE user_id = ? AND session_token <> ?`, userID, keep)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var token string
+			if err := rows.Scan(&token); err != nil {
+				return err
+			}
+			tokens = append(tokens, token)
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		_, err = t.exec(`DELETE FROM sessions WHERE user_id = ? AND session_token <> ?`, userID, keep)
+		return err
+	})
 
-	return sessionToken, nil
+	return tokens, err
 }
 
-func DeleteSession(id int) error {
+func (s *Store) DeleteSession(id int) error {
 	query := `
 	DELETE FROM sessions
 	WHERE user_id = ?`
 
-	_, err := DB.Exec(query, id)
+	_, err := s.exec(query, id)
 
 	return err
 }
 
-func DeleteSessionByToken(token string) error {
+func (s *Store) DeleteSessionByToken(token string) error {
 	query := `
 	DELETE FROM sessions
 	WHERE session_token = ?`
 
-	_, err := DB.Exec(query, token)
+	_, err := s.exec(query, token)
 
 	return err
 }
 
-func GetUserIDFromSession(db *sql.DB, r *http.Request) (int, error) {
-	sessionCookie, err := r.Cookie("session_token")
-	if err != nil {
-		return 0, fmt.Errorf("session token not found: %v", err)
-	}
-	sessionToken := sessionCookie.Value
-
+func (s *Store) GetUserIDFromSession(sessionToken string) (int, error) {
 	var userID int
 	var expiresAt time.Time
 
@@ -72,7 +171,7 @@ func GetUserIDFromSession(db *sql.DB, r *http.Request) (int, error) {
 	FROM sessions
 	WHERE session_token = ? AND expires_at > ?`
 
-	err = db.QueryRow(query, sessionToken, time.Now()).Scan(&userID, &expiresAt)
+	err := s.queryRow(query, sessionToken, time.Now()).Scan(&userID, &expiresAt)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return 0, fmt.Errorf("sql.ErrNoRows")
@@ -83,20 +182,14 @@ func GetUserIDFromSession(db *sql.DB, r *http.Request) (int, error) {
 	return userID, nil
 }
 
-func GetUserFromSession(db *sql.DB, r *http.Request) (*models.UserIdentity, error) {
-	sessionCookie, err := r.Cookie("session_token")
-	if err != nil {
-		return nil, fmt.Errorf("session token not found: %v", err)
-	}
-	sessionToken := sessionCookie.Value
-
+func (s *Store) GetUserFromSession(sessionToken string) (*models.UserIdentity, error) {
 	query := `
 	SELECT user_id
 	FROM sessions
 	WHERE session_token = ? AND expires_at > ?`
 
 	var userID int
-	err = db.QueryRow(query, sessionToken, time.Now()).Scan(&userID)
+	err := s.queryRow(query, sessionToken, time.Now()).Scan(&userID)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, fmt.Errorf("no user found for the session token")
@@ -106,11 +199,11 @@ func GetUserFromSession(db *sql.DB, r *http.Request) (*models.UserIdentity, erro
 
 	var user models.UserIdentity
 	query = `
-	SELECT id, nickname, email
+	SELECT id, nickname, email, verified_at IS NOT NULL, role
 	FROM users
 	WHERE id = ?`
 
-	err = db.QueryRow(query, userID).Scan(&user.ID, &user.Nickname, &user.Email)
+	err = s.queryRow(query, userID).Scan(&user.ID, &user.Nickname, &user.Email, &user.Verified, &user.Role)
 	if err != nil {
 		return nil, fmt.Errorf("database error: %v", err)
 	}
@@ -118,23 +211,32 @@ func GetUserFromSession(db *sql.DB, r *http.Request) (*models.UserIdentity, erro
 	return &user, nil
 }
 
-func StartSessionCleanup(interval time.Duration) {
+// StartSessionCleanup deletes expired sessions every interval until ctx is done.
+func StartSessionCleanup(ctx context.Context, sessions SessionStore, interval time.Duration) {
 	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
-	for range ticker.C {
-		cleanupExpiredSessions(DB)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := sessions.CleanupExpiredSessions(); err != nil {
+				slog.Error("Session cleanup failed", "err", err)
+			}
+		}
 	}
 }
 
-func cleanupExpiredSessions(db *sql.DB) {
+// CleanupExpiredSessions deletes sessions that went unused too long or
+// reached their cap.
+func (s *Store) CleanupExpiredSessions() error {
+	now := time.Now()
 	query := `
 	DELETE FROM sessions
-	WHERE expires_at < ?`
+	WHERE expires_at < ? OR max_expires_at < ?`
 
-	_, err := db.Exec(query, time.Now())
-	if err != nil {
-		errLog.Error.Println(err.Error())
-		return
-	}
+	_, err := s.exec(query, now, now)
+
+	return err
 }
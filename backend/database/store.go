@@ -0,0 +1,169 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/nyagooh/Real-time-forum.git/backend/models"
+)
+
+// UserStore reads and writes user accounts.
+type UserStore interface {
+	InsertUser(user models.User) error
+	GetUser(credential models.Credentials) (models.UserIdentity, string, error)
+	GetUsers() ([]string, error)
+	GetUserID(identity string) (int, error)
+	GetUserByID(id int) (string, error)
+	GetUserEmail(id int) (string, error)
+	IsVerified(id int) (bool, error)
+	GetUsersByInteraction(userID int) ([]models.ChatUser, error)
+}
+
+// PostStore reads and writes posts and their comments.
+type PostStore interface {
+	InsertPost(userID int, post *models.Post) (int64, error)
+	GetAllPosts(category string) ([]*models.Post, error)
+	GetPost(postID int) (*models.Post, int, error)
+	UpdatePost(postID, editorID int, post *models.Post) error
+	ListPostRevisions(postID int) ([]models.PostRevision, error)
+	GetComment(commentID int) (*models.Comment, error)
+	UpdateComment(comment *models.Comment) error
+	DeletePost(postID, userID int) error
+	RestorePost(postID int) error
+	DeleteComment(commentID, userID int) error
+	RestoreComment(commentID int) error
+	PurgeDeleted(cutoff time.Time) (posts, comments int, err error)
+	AddComment(postID, userID, parentID int, post *models.Post) error
+	ListComments(postID, parentID, levels int) ([]models.Comment, error)
+}
+
+// SessionStore manages login sessions.
+type SessionStore interface {
+	InsertSession(id int, session string, expiresAt, maxExpiresAt time.Time, userAgent, ip string) error
+	TouchSession(token string, lifetime time.Duration) (time.Time, error)
+	ListSessions(userID int, current string) ([]models.Session, error)
+	DeleteUserSession(userID, sessionID int) (string, error)
+	DeleteOtherSessions(userID int, keep string) ([]string, error)
+	DeleteSession(id int) error
+	DeleteSessionByToken(token string) error
+	GetUserIDFromSession(token string) (int, error)
+	GetUserFromSession(token string) (*models.UserIdentity, error)
+	CleanupExpiredSessions() error
+}
+
+// PasswordResetStore keeps one-time password reset tokens.
+type PasswordResetStore interface {
+	CreatePasswordReset(userID int, tokenHash string, expiresAt time.Time) error
+	ResetPassword(tokenHash, passwordHash string) (int, []string, error)
+}
+
+// EmailVerificationStore keeps one-time email verification tokens.
+type EmailVerificationStore interface {
+	CreateEmailVerification(userID int, tokenHash string, expiresAt time.Time) error
+	VerifyEmail(tokenHash string) (int, error)
+}
+
+// TwoFactorStore keeps TOTP secrets, recovery codes and logins waiting for
+// their second factor.
+type TwoFactorStore interface {
+	GetTwoFactor(userID int) (*models.TwoFactor, error)
+	StartTwoFactor(userID int, secret string) error
+	EnableTwoFactor(userID int, step int64, recoveryHashes []string) error
+	DisableTwoFactor(userID int) error
+	UseTOTPStep(userID int, step int64) (bool, error)
+	UseRecoveryCode(userID int, codeHash string) (bool, error)
+	CountRecoveryCodes(userID int) (int, error)
+	CreatePendingLogin(userID int, tokenHash string, expiresAt time.Time) error
+	CheckPendingLogin(tokenHash string, maxAttempts int) (int, error)
+	DeletePendingLogin(tokenHash string) error
+}
+
+// LoginAttemptStore keeps the sign-in log and the lockouts it leads to.
+type LoginAttemptStore interface {
+	RecordLoginAttempt(userID int, ip, userAgent string, success bool) (int, error)
+	AccountFailures(userID int) (models.LoginFailures, error)
+	IPFailures(ip string, since time.Time) (models.LoginFailures, error)
+	LockAccount(userID int, until time.Time, tokenHash string, tokenExpiresAt time.Time) error
+	UnlockAccount(tokenHash string) (int, error)
+	ListLoginAttempts(userID, limit int) ([]models.LoginAttempt, error)
+	PruneLoginAttempts(cutoff time.Time) (int, error)
+}
+
+// RoleStore keeps what each account may do. See models.Role.
+type RoleStore interface {
+	GetUserRole(userID int) (models.Role, error)
+	SetUserRole(userID int, role models.Role) error
+	BootstrapAdmin(nickname string) (int, error)
+	ListStaff() ([]models.StaffMember, error)
+}
+
+// ReportStore keeps user reports about content and the moderation queue
+// they form.
+type ReportStore interface {
+	ReportedUser(targetType string, targetID, reporterID int) (int, error)
+	CreateReport(reporterID int, targetType string, targetID, reportedUserID int, reason, details string) (int, error)
+	GetReport(id int) (*models.Report, error)
+	ListReports(status string, limit, offset int) ([]models.Report, error)
+	ClaimReport(id, moderatorID int) error
+	ResolveReport(id, moderatorID int, status, resolution string) error
+}
+
+// MessageStore persists private messages.
+type MessageStore interface {
+	SaveMessage(msg *models.Message) error
+	GetMessages(sender, receiver string, offset, limit int) ([]models.Message, error)
+}
+
+// ReactionStore manages likes and dislikes on posts and comments.
+type ReactionStore interface {
+	ToggleReaction(userID, postID int, reaction string) error
+	GetReactionCounts(postID int) (int, int, error)
+	ToggleCommentReaction(userID, commentID int, reaction string) error
+	GetCommentReactionCounts(commentID int) (int, int, error)
+}
+
+// Store implements every store interface on top of database/sql. The
+// dialect decides how queries are written for SQLite or PostgreSQL.
+type Store struct {
+	db      *sql.DB
+	dialect Dialect
+}
+
+var (
+	_ UserStore              = (*Store)(nil)
+	_ PostStore              = (*Store)(nil)
+	_ SessionStore           = (*Store)(nil)
+	_ PasswordResetStore     = (*Store)(nil)
+	_ EmailVerificationStore = (*Store)(nil)
+	_ TwoFactorStore         = (*Store)(nil)
+	_ LoginAttemptStore      = (*Store)(nil)
+	_ RoleStore              = (*Store)(nil)
+	_ ReportStore            = (*Store)(nil)
+	_ MessageStore           = (*Store)(nil)
+	_ ReactionStore          = (*Store)(nil)
+)
+
+func NewStore(db *sql.DB, dialect Dialect) *Store {
+	return &Store{db: db, dialect: dialect}
+}
+
+// DB returns the underlying connection pool.
+func (s *Store) DB() *sql.DB {
+	return s.db
+}
+
+// Dialect returns the SQL dialect the store speaks.
+func (s *Store) Dialect() Dialect {
+	return s.dialect
+}
+
+// Ping checks that the database answers.
+func (s *Store) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+// CheckSchema reports whether the schema matches the binary's migrations.
+func (s *Store) CheckSchema(context.Context) error {
+	return CheckSchema(s.db, s.dialect)
+}
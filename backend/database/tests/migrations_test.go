@@ -0,0 +1,76 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/nyagooh/Real-time-forum.git/backend/database"
+)
+
+func TestMigrateAndRollback(t *testing.T) {
+	forEachDialect(t, testMigrateAndRollback)
+}
+
+func testMigrateAndRollback(t *testing.T, db *sql.DB, dialect database.Dialect) {
+	if err := database.CheckSchema(db, dialect); !errors.Is(err, database.ErrSchemaBehind) {
+		t.Fatalf("expected ErrSchemaBehind on empty database, got %v", err)
+	}
+	// Checking is read-only; only Migrate creates the bookkeeping table.
+	if _, err := db.Exec(`SELECT 1 FROM schema_migrations`); err == nil {
+		t.Fatalf("CheckSchema() created schema_migrations")
+	}
+
+	if err := database.Migrate(db, dialect); err != nil {
+		t.Fatalf("Migrate() error = %v", err)
+	}
+	if err := database.CheckSchema(db, dialect); err != nil {
+		t.Fatalf("CheckSchema() after Migrate error = %v", err)
+	}
+
+	// Running again must be a no-op.
+	if err := database.Migrate(db, dialect); err != nil {
+		t.Fatalf("second Migrate() error = %v", err)
+	}
+
+	migrations, err := database.Migrations(dialect)
+	if err != nil {
+		t.Fatalf("Migrations() error = %v", err)
+	}
+
+	if err := database.Rollback(db, dialect, len(migrations)); err != nil {
+		t.Fatalf("Rollback() error = %v", err)
+	}
+	applied, err := database.AppliedVersions(db, dialect)
+	if err != nil {
+		t.Fatalf("AppliedVersions() error = %v", err)
+	}
+	if len(applied) != 0 {
+		t.Errorf("expected no applied migrations after full rollback, got %v", applied)
+	}
+
+	var users int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM users`).Scan(&users); err == nil {
+		t.Errorf("expected users table to be dropped by rollback")
+	}
+}
+
+func TestCheckSchemaAhead(t *testing.T) {
+	forEachDialect(t, testCheckSchemaAhead)
+}
+
+func testCheckSchemaAhead(t *testing.T, db *sql.DB, dialect database.Dialect) {
+	if err := database.Migrate(db, dialect); err != nil {
+		t.Fatalf("Migrate() error = %v", err)
+	}
+	if _, err := db.Exec(`INSERT INTO schema_migrations (version, name) VALUES (9999, 'from_the_future')`); err != nil {
+		t.Fatalf("failed to insert fake migration: %v", err)
+	}
+
+	if err := database.CheckSchema(db, dialect); !errors.Is(err, database.ErrSchemaAhead) {
+		t.Fatalf("expected ErrSchemaAhead, got %v", err)
+	}
+	if err := database.Migrate(db, dialect); !errors.Is(err, database.ErrSchemaAhead) {
+		t.Fatalf("expected Migrate to refuse an ahead schema, got %v", err)
+	}
+}
@@ -0,0 +1,943 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nyagooh/Real-time-forum.git/backend/database"
+	"github.com/nyagooh/Real-time-forum.git/backend/models"
+)
+
+func newTestStore(t *testing.T, db *sql.DB, dialect database.Dialect) *database.Store {
+	t.Helper()
+
+	if err := database.Migrate(db, dialect); err != nil {
+		t.Fatalf("Migrate() error = %v", err)
+	}
+
+	return database.NewStore(db, dialect)
+}
+
+func TestStoreUsersAndSessions(t *testing.T) {
+	forEachDialect(t, testStoreUsersAndSessions)
+}
+
+func testStoreUsersAndSessions(t *testing.T, db *sql.DB, dialect database.Dialect) {
+	store := newTestStore(t, db, dialect)
+
+	user := models.User{
+		Nickname:  "alice",
+		Age:       "30",
+		Gender:    "female",
+		Firstname: "Alice",
+		Lastname:  "Doe",
+		Email:     "alice@example.com",
+		Password:  "hash",
+	}
+	if err := store.InsertUser(user); err != nil {
+		t.Fatalf("InsertUser() error = %v", err)
+	}
+	if err := store.InsertUser(user); err == nil {
+		t.Errorf("expected duplicate InsertUser() to fail")
+	}
+
+	id, err := store.GetUserID("alice@example.com")
+	if err != nil {
+		t.Fatalf("GetUserID() error = %v", err)
+	}
+
+	if err := store.InsertSession(id, "token-1", time.Now().Add(time.Hour), time.Now().Add(time.Hour), "test", "127.0.0.1"); err != nil {
+		t.Fatalf("InsertSession() error = %v", err)
+	}
+	got, err := store.GetUserIDFromSession("token-1")
+	if err != nil || got != id {
+		t.Fatalf("GetUserIDFromSession() = %d, %v, want %d", got, err, id)
+	}
+
+	if err := store.InsertSession(id, "token-expired", time.Now().Add(-time.Hour), time.Now().Add(-time.Hour), "test", "127.0.0.1"); err != nil {
+		t.Fatalf("InsertSession() error = %v", err)
+	}
+	if _, err := store.GetUserIDFromSession("token-expired"); err == nil {
+		t.Errorf("expected expired session to be rejected")
+	}
+
+	if err := store.DeleteSessionByToken("token-1"); err != nil {
+		t.Fatalf("DeleteSessionByToken() error = %v", err)
+	}
+	if _, err := store.GetUserIDFromSession("token-1"); err == nil {
+		t.Errorf("expected deleted session to be rejected")
+	}
+}
+
+func TestStoreSessionDevices(t *testing.T) {
+	forEachDialect(t, testStoreSessionDevices)
+}
+
+func testStoreSessionDevices(t *testing.T, db *sql.DB, dialect database.Dialect) {
+	store := newTestStore(t, db, dialect)
+
+	store.InsertUser(models.User{Nickname: "hana", Age: "33", Firstname: "Hana", Lastname: "Ito", Email: "hana@example.com"})
+	store.InsertUser(models.User{Nickname: "ivan", Age: "35", Firstname: "Ivan", Lastname: "Oz", Email: "ivan@example.com"})
+	hana, _ := store.GetUserID("hana")
+	ivan, _ := store.GetUserID("ivan")
+
+	expires := time.Now().Add(time.Hour)
+	store.InsertSession(hana, "laptop", expires, expires, "Firefox", "10.0.0.1")
+	store.InsertSession(hana, "phone", expires, expires, "Safari", "10.0.0.2")
+	store.InsertSession(hana, "tablet", expires, expires, "Chrome", "10.0.0.3")
+	store.InsertSession(ivan, "ivan", expires, expires, "Edge", "10.0.0.4")
+
+	// Logging in again keeps the earlier sessions.
+	for _, token := range []string{"laptop", "phone", "tablet"} {
+		if got, err := store.GetUserIDFromSession(token); err != nil || got != hana {
+			t.Fatalf("GetUserIDFromSession(%q) = %d, %v, want %d", token, got, err, hana)
+		}
+	}
+	if _, err := store.TouchSession("laptop", time.Hour); err != nil {
+		t.Fatalf("TouchSession() error = %v", err)
+	}
+
+	list, err := store.ListSessions(hana, "laptop")
+	if err != nil || len(list) != 3 {
+		t.Fatalf("ListSessions() = %+v, %v, want 3 sessions", list, err)
+	}
+	var current *models.Session
+	for i := range list {
+		if list[i].Current {
+			current = &list[i]
+		}
+	}
+	if current == nil || current.UserAgent != "Firefox" || current.IP != "10.0.0.1" || current.LastSeenAt.IsZero() {
+		t.Fatalf("current session = %+v", current)
+	}
+
+	if _, err := store.DeleteUserSession(ivan, current.ID); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("DeleteUserSession() of another user's session error = %v, want sql.ErrNoRows", err)
+	}
+	phone := list[0].ID
+	if list[0].Current {
+		phone = list[1].ID
+	}
+	if token, err := store.DeleteUserSession(hana, phone); err != nil || token == "laptop" {
+		t.Fatalf("DeleteUserSession() = %q, %v", token, err)
+	}
+
+	revoked, err := store.DeleteOtherSessions(hana, "laptop")
+	if err != nil || len(revoked) != 1 {
+		t.Fatalf("DeleteOtherSessions() = %v, %v, want one token", revoked, err)
+	}
+	if list, _ := store.ListSessions(hana, "laptop"); len(list) != 1 || !list[0].Current {
+		t.Errorf("ListSessions() after revoking others = %+v", list)
+	}
+	if _, err := store.GetUserIDFromSession("ivan"); err != nil {
+		t.Errorf("another user's session was revoked: %v", err)
+	}
+}
+
+func TestStoreSessionRenewal(t *testing.T) {
+	forEachDialect(t, testStoreSessionRenewal)
+}
+
+func testStoreSessionRenewal(t *testing.T, db *sql.DB, dialect database.Dialect) {
+	store := newTestStore(t, db, dialect)
+
+	store.InsertUser(models.User{Nickname: "jo", Age: "28", Firstname: "Jo", Lastname: "Ng", Email: "jo@example.com"})
+	id, _ := store.GetUserID("jo")
+
+	now := time.Now()
+	lifetime := 2 * time.Hour
+	store.InsertSession(id, "fresh", now.Add(90*time.Minute), now.Add(24*time.Hour), "", "")
+	store.InsertSession(id, "stale", now.Add(30*time.Minute), now.Add(24*time.Hour), "", "")
+	store.InsertSession(id, "capped", now.Add(30*time.Minute), now.Add(45*time.Minute), "", "")
+	store.InsertSession(id, "over-cap", now.Add(time.Hour), now.Add(-time.Minute), "", "")
+
+	if got, err := store.TouchSession("fresh", lifetime); err != nil || !got.IsZero() {
+		t.Errorf("TouchSession() before half-life = %v, %v, want no renewal", got, err)
+	}
+
+	got, err := store.TouchSession("stale", lifetime)
+	if err != nil || got.Before(now.Add(lifetime-time.Minute)) {
+		t.Errorf("TouchSession() past half-life = %v, %v, want about %v", got, err, now.Add(lifetime))
+	}
+
+	got, err = store.TouchSession("capped", lifetime)
+	if err != nil || got.Sub(now.Add(45*time.Minute)).Abs() > time.Second {
+		t.Errorf("TouchSession() near the cap = %v, %v, want the cap", got, err)
+	}
+
+	if err := store.CleanupExpiredSessions(); err != nil {
+		t.Fatalf("CleanupExpiredSessions() error = %v", err)
+	}
+	if list, _ := store.ListSessions(id, ""); len(list) != 3 {
+		t.Errorf("sessions after cleanup = %d, want 3 (the one over its cap removed)", len(list))
+	}
+}
+
+func TestStorePostsAndReactions(t *testing.T) {
+	forEachDialect(t, testStorePostsAndReactions)
+}
+
+func testStorePostsAndReactions(t *testing.T, db *sql.DB, dialect database.Dialect) {
+	store := newTestStore(t, db, dialect)
+
+	store.InsertUser(models.User{Nickname: "bob", Age: "25", Firstname: "Bob", Lastname: "Roe", Email: "bob@example.com"})
+	userID, err := store.GetUserID("bob")
+	if err != nil {
+		t.Fatalf("GetUserID() error = %v", err)
+	}
+
+	postID, err := store.InsertPost(userID, &models.Post{
+		Title:     "Hello",
+		Content:   "First post",
+		Category:  []string{"general", "news"},
+		CreatedAt: time.Now().Format(time.RFC3339),
+	})
+	if err != nil {
+		t.Fatalf("InsertPost() error = %v", err)
+	}
+
+	if err := store.ToggleReaction(userID, int(postID), "like"); err != nil {
+		t.Fatalf("ToggleReaction() error = %v", err)
+	}
+	if err := store.AddComment(int(postID), userID, 0, &models.Post{Content: "Nice"}); err != nil {
+		t.Fatalf("AddComment() error = %v", err)
+	}
+
+	posts, err := store.GetAllPosts("news")
+	if err != nil {
+		t.Fatalf("GetAllPosts() error = %v", err)
+	}
+	if len(posts) != 1 {
+		t.Fatalf("GetAllPosts() returned %d posts, want 1", len(posts))
+	}
+	post := posts[0]
+	if post.Likes != 1 || len(post.LikedBy) != 1 || post.LikedBy[0] != "bob" {
+		t.Errorf("unexpected reactions: likes=%d likedBy=%v", post.Likes, post.LikedBy)
+	}
+	if len(post.Comments) != 1 || post.Comments[0].Content != "Nice" {
+		t.Errorf("unexpected comments: %+v", post.Comments)
+	}
+
+	// Toggling the same reaction removes it.
+	if err := store.ToggleReaction(userID, int(postID), "like"); err != nil {
+		t.Fatalf("ToggleReaction() error = %v", err)
+	}
+	likes, dislikes, err := store.GetReactionCounts(int(postID))
+	if err != nil || likes != 0 || dislikes != 0 {
+		t.Errorf("GetReactionCounts() = %d, %d, %v, want 0, 0", likes, dislikes, err)
+	}
+}
+
+func TestStorePasswordResets(t *testing.T) {
+	forEachDialect(t, testStorePasswordResets)
+}
+
+func testStorePasswordResets(t *testing.T, db *sql.DB, dialect database.Dialect) {
+	store := newTestStore(t, db, dialect)
+
+	store.InsertUser(models.User{Nickname: "erin", Age: "40", Firstname: "Erin", Lastname: "Poe", Email: "erin@example.com", Password: "old"})
+	userID, err := store.GetUserID("erin")
+	if err != nil {
+		t.Fatalf("GetUserID() error = %v", err)
+	}
+	if email, err := store.GetUserEmail(userID); err != nil || email != "erin@example.com" {
+		t.Fatalf("GetUserEmail() = %q, %v", email, err)
+	}
+	store.InsertSession(userID, "erin-session", time.Now().Add(time.Hour), time.Now().Add(time.Hour), "test", "127.0.0.1")
+
+	if err := store.CreatePasswordReset(userID, "expired", time.Now().Add(-time.Minute)); err != nil {
+		t.Fatalf("CreatePasswordReset() error = %v", err)
+	}
+	if _, _, err := store.ResetPassword("expired", "new"); !errors.Is(err, database.ErrInvalidToken) {
+		t.Errorf("ResetPassword(expired) error = %v, want ErrInvalidToken", err)
+	}
+
+	store.CreatePasswordReset(userID, "first", time.Now().Add(time.Hour))
+	store.CreatePasswordReset(userID, "second", time.Now().Add(time.Hour))
+
+	got, sessions, err := store.ResetPassword("second", "new")
+	if err != nil || got != userID {
+		t.Fatalf("ResetPassword() = %d, %v, want %d", got, err, userID)
+	}
+	if len(sessions) != 1 || sessions[0] != "erin-session" {
+		t.Errorf("ResetPassword() ended sessions = %v, want [erin-session]", sessions)
+	}
+	if _, err := store.GetUserIDFromSession("erin-session"); err == nil {
+		t.Errorf("expected sessions to end after a reset")
+	}
+	if _, hash, err := store.GetUser(models.Credentials{Identity: "erin"}); err != nil || strings.TrimSpace(hash) != "new" {
+		t.Errorf("password hash = %q, %v, want %q", hash, err, "new")
+	}
+
+	for _, token := range []string{"second", "first"} {
+		if _, _, err := store.ResetPassword(token, "again"); !errors.Is(err, database.ErrInvalidToken) {
+			t.Errorf("ResetPassword(%s) after reset error = %v, want ErrInvalidToken", token, err)
+		}
+	}
+}
+
+func TestStoreEmailVerification(t *testing.T) {
+	forEachDialect(t, testStoreEmailVerification)
+}
+
+func testStoreEmailVerification(t *testing.T, db *sql.DB, dialect database.Dialect) {
+	store := newTestStore(t, db, dialect)
+
+	store.InsertUser(models.User{Nickname: "finn", Age: "22", Firstname: "Finn", Lastname: "Moe", Email: "finn@example.com"})
+	userID, err := store.GetUserID("finn")
+	if err != nil {
+		t.Fatalf("GetUserID() error = %v", err)
+	}
+
+	if verified, err := store.IsVerified(userID); err != nil || verified {
+		t.Fatalf("IsVerified() for a new account = %v, %v, want false", verified, err)
+	}
+
+	store.CreateEmailVerification(userID, "expired", time.Now().Add(-time.Minute))
+	if _, err := store.VerifyEmail("expired"); !errors.Is(err, database.ErrInvalidToken) {
+		t.Errorf("VerifyEmail(expired) error = %v, want ErrInvalidToken", err)
+	}
+
+	store.CreateEmailVerification(userID, "good", time.Now().Add(time.Hour))
+	if got, err := store.VerifyEmail("good"); err != nil || got != userID {
+		t.Fatalf("VerifyEmail() = %d, %v, want %d", got, err, userID)
+	}
+	if _, err := store.VerifyEmail("good"); !errors.Is(err, database.ErrInvalidToken) {
+		t.Errorf("VerifyEmail() twice error = %v, want ErrInvalidToken", err)
+	}
+
+	user, _, err := store.GetUser(models.Credentials{Identity: "finn"})
+	if err != nil || !user.Verified {
+		t.Errorf("GetUser() verified = %v, %v, want true", user.Verified, err)
+	}
+}
+
+func TestStoreTwoFactor(t *testing.T) {
+	forEachDialect(t, testStoreTwoFactor)
+}
+
+func testStoreTwoFactor(t *testing.T, db *sql.DB, dialect database.Dialect) {
+	store := newTestStore(t, db, dialect)
+
+	store.InsertUser(models.User{Nickname: "gail", Age: "40", Firstname: "Gail", Lastname: "Ray", Email: "gail@example.com"})
+	userID, err := store.GetUserID("gail")
+	if err != nil {
+		t.Fatalf("GetUserID() error = %v", err)
+	}
+
+	if tf, err := store.GetTwoFactor(userID); err != nil || tf != nil {
+		t.Fatalf("GetTwoFactor() before enrollment = %v, %v, want nil", tf, err)
+	}
+
+	store.StartTwoFactor(userID, "FIRST")
+	if err := store.StartTwoFactor(userID, "SECOND"); err != nil {
+		t.Fatalf("StartTwoFactor() again error = %v", err)
+	}
+	if err := store.EnableTwoFactor(userID, 100, []string{"a", "b"}); err != nil {
+		t.Fatalf("EnableTwoFactor() error = %v", err)
+	}
+	tf, err := store.GetTwoFactor(userID)
+	if err != nil || tf == nil || !tf.Enabled || strings.TrimSpace(tf.Secret) != "SECOND" || tf.LastStep != 100 {
+		t.Fatalf("GetTwoFactor() = %+v, %v", tf, err)
+	}
+
+	if ok, _ := store.UseTOTPStep(userID, 100); ok {
+		t.Error("UseTOTPStep() accepted a step twice")
+	}
+	if ok, err := store.UseTOTPStep(userID, 101); err != nil || !ok {
+		t.Errorf("UseTOTPStep(101) = %v, %v, want true", ok, err)
+	}
+
+	if ok, err := store.UseRecoveryCode(userID, "a"); err != nil || !ok {
+		t.Errorf("UseRecoveryCode() = %v, %v, want true", ok, err)
+	}
+	if ok, _ := store.UseRecoveryCode(userID, "a"); ok {
+		t.Error("UseRecoveryCode() accepted a code twice")
+	}
+	if n, err := store.CountRecoveryCodes(userID); err != nil || n != 1 {
+		t.Errorf("CountRecoveryCodes() = %d, %v, want 1", n, err)
+	}
+
+	store.CreatePendingLogin(userID, "pending", time.Now().Add(time.Minute))
+	for i := range 2 {
+		if got, err := store.CheckPendingLogin("pending", 2); err != nil || got != userID {
+			t.Fatalf("CheckPendingLogin() attempt %d = %d, %v, want %d", i+1, got, err, userID)
+		}
+	}
+	if _, err := store.CheckPendingLogin("pending", 2); !errors.Is(err, database.ErrInvalidToken) {
+		t.Errorf("CheckPendingLogin() past the limit error = %v, want ErrInvalidToken", err)
+	}
+
+	if err := store.DisableTwoFactor(userID); err != nil {
+		t.Fatalf("DisableTwoFactor() error = %v", err)
+	}
+	if tf, _ := store.GetTwoFactor(userID); tf != nil {
+		t.Errorf("GetTwoFactor() after disable = %+v, want nil", tf)
+	}
+}
+
+func TestStoreLoginAttempts(t *testing.T) {
+	forEachDialect(t, testStoreLoginAttempts)
+}
+
+func testStoreLoginAttempts(t *testing.T, db *sql.DB, dialect database.Dialect) {
+	store := newTestStore(t, db, dialect)
+
+	store.InsertUser(models.User{Nickname: "hank", Age: "33", Firstname: "Hank", Lastname: "Moe", Email: "hank@example.com"})
+	userID, err := store.GetUserID("hank")
+	if err != nil {
+		t.Fatalf("GetUserID() error = %v", err)
+	}
+
+	for i := 1; i <= 2; i++ {
+		if n, err := store.RecordLoginAttempt(userID, "10.0.0.1", "curl", false); err != nil || n != i {
+			t.Fatalf("RecordLoginAttempt() failure %d = %d, %v", i, n, err)
+		}
+	}
+	if _, err := store.RecordLoginAttempt(0, "10.0.0.1", "curl", false); err != nil {
+		t.Fatalf("RecordLoginAttempt() for unknown user error = %v", err)
+	}
+
+	failures, err := store.AccountFailures(userID)
+	if err != nil || failures.Count != 2 || failures.Last.IsZero() || !failures.LockedUntil.IsZero() {
+		t.Fatalf("AccountFailures() = %+v, %v", failures, err)
+	}
+	if ip, err := store.IPFailures("10.0.0.1", time.Now().Add(-time.Minute)); err != nil || ip.Count != 3 {
+		t.Errorf("IPFailures() = %+v, %v, want 3 failures", ip, err)
+	}
+	if ip, _ := store.IPFailures("10.0.0.1", time.Now().Add(time.Minute)); ip.Count != 0 {
+		t.Errorf("IPFailures() counted %d failures outside the window", ip.Count)
+	}
+
+	until := time.Now().Add(time.Hour)
+	if err := store.LockAccount(userID, until, "stale", time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("LockAccount() error = %v", err)
+	}
+	store.LockAccount(userID, until, "unlock", time.Now().Add(time.Hour))
+	if failures, _ := store.AccountFailures(userID); failures.LockedUntil.Before(until.Add(-time.Second)) {
+		t.Errorf("LockedUntil = %v, want %v", failures.LockedUntil, until)
+	}
+
+	if _, err := store.UnlockAccount("stale"); !errors.Is(err, database.ErrInvalidToken) {
+		t.Errorf("UnlockAccount() with replaced token error = %v, want ErrInvalidToken", err)
+	}
+	if id, err := store.UnlockAccount("unlock"); err != nil || id != userID {
+		t.Fatalf("UnlockAccount() = %d, %v", id, err)
+	}
+	if _, err := store.UnlockAccount("unlock"); !errors.Is(err, database.ErrInvalidToken) {
+		t.Errorf("UnlockAccount() twice error = %v, want ErrInvalidToken", err)
+	}
+	if failures, _ := store.AccountFailures(userID); failures.Count != 0 || !failures.LockedUntil.IsZero() {
+		t.Errorf("AccountFailures() after unlock = %+v", failures)
+	}
+
+	store.RecordLoginAttempt(userID, "10.0.0.2", "firefox", true)
+	attempts, err := store.ListLoginAttempts(userID, 2)
+	if err != nil || len(attempts) != 2 {
+		t.Fatalf("ListLoginAttempts() = %+v, %v", attempts, err)
+	}
+	if !attempts[0].Success || attempts[0].IP != "10.0.0.2" || attempts[0].UserAgent != "firefox" || attempts[1].Success {
+		t.Errorf("ListLoginAttempts() = %+v, want the success first", attempts)
+	}
+
+	if n, err := store.PruneLoginAttempts(time.Now().Add(-time.Hour)); err != nil || n != 0 {
+		t.Errorf("PruneLoginAttempts() of older attempts = %d, %v, want 0", n, err)
+	}
+	if n, err := store.PruneLoginAttempts(time.Now().Add(time.Second)); err != nil || n != 4 {
+		t.Errorf("PruneLoginAttempts() = %d, %v, want 4", n, err)
+	}
+	if attempts, _ := store.ListLoginAttempts(userID, 20); len(attempts) != 0 {
+		t.Errorf("ListLoginAttempts() after prune = %+v, want none", attempts)
+	}
+}
+
+func TestStoreDuplicateUser(t *testing.T) {
+	forEachDialect(t, testStoreDuplicateUser)
+}
+
+func testStoreDuplicateUser(t *testing.T, db *sql.DB, dialect database.Dialect) {
+	store := newTestStore(t, db, dialect)
+
+	user := models.User{Nickname: "iris", Age: "28", Firstname: "Iris", Lastname: "Oak", Email: "iris@example.com"}
+	if err := store.InsertUser(user); err != nil {
+		t.Fatalf("InsertUser() error = %v", err)
+	}
+
+	for _, tt := range []struct {
+		user  models.User
+		field string
+	}{
+		{models.User{Nickname: "iris", Age: "28", Email: "other@example.com"}, "nickname"},
+		{models.User{Nickname: "iris2", Age: "28", Email: "iris@example.com"}, "email"},
+		{models.User{Nickname: "Iris", Age: "28", Email: "other@example.com"}, "nickname"},
+		{models.User{Nickname: "iris2", Age: "28", Email: "Iris@Example.com"}, "email"},
+	} {
+		var duplicate *database.DuplicateError
+		if err := store.InsertUser(tt.user); !errors.As(err, &duplicate) || duplicate.Field != tt.field {
+			t.Errorf("InsertUser() duplicate %s error = %v, want DuplicateError", tt.field, err)
+		}
+	}
+
+	if _, err := store.GetUserID("IRIS@example.com"); err != nil {
+		t.Errorf("GetUserID() by email in another case error = %v", err)
+	}
+}
+
+func TestStoreRoles(t *testing.T) {
+	forEachDialect(t, testStoreRoles)
+}
+
+func testStoreRoles(t *testing.T, db *sql.DB, dialect database.Dialect) {
+	store := newTestStore(t, db, dialect)
+
+	store.InsertUser(models.User{Nickname: "jade", Age: "35", Firstname: "Jade", Lastname: "Lee", Email: "jade@example.com"})
+	store.InsertUser(models.User{Nickname: "kim", Age: "29", Firstname: "Kim", Lastname: "Ng", Email: "kim@example.com"})
+	jade, _ := store.GetUserID("jade")
+	kim, _ := store.GetUserID("kim")
+
+	if role, err := store.GetUserRole(kim); err != nil || role != models.RoleUser {
+		t.Fatalf("GetUserRole() of a new account = %q, %v, want user", role, err)
+	}
+
+	if _, err := store.BootstrapAdmin("nobody"); err == nil {
+		t.Error("BootstrapAdmin() of an unknown nickname succeeded")
+	}
+	if id, err := store.BootstrapAdmin("jade"); err != nil || id != jade {
+		t.Fatalf("BootstrapAdmin() = %d, %v", id, err)
+	}
+	if _, err := store.BootstrapAdmin("kim"); !errors.Is(err, database.ErrAdminExists) {
+		t.Errorf("second BootstrapAdmin() error = %v, want ErrAdminExists", err)
+	}
+	if user, _, err := store.GetUser(models.Credentials{Identity: "jade"}); err != nil || user.Role != models.RoleAdmin {
+		t.Errorf("GetUser() role = %q, %v, want admin", user.Role, err)
+	}
+
+	if err := store.SetUserRole(jade, models.RoleUser); !errors.Is(err, database.ErrLastAdmin) {
+		t.Errorf("demoting the last admin error = %v, want ErrLastAdmin", err)
+	}
+	if err := store.SetUserRole(kim, models.RoleModerator); err != nil {
+		t.Fatalf("SetUserRole() error = %v", err)
+	}
+	if err := store.SetUserRole(1<<20, models.RoleModerator); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("SetUserRole() of an unknown account error = %v, want sql.ErrNoRows", err)
+	}
+
+	staff, err := store.ListStaff()
+	if err != nil || len(staff) != 2 || staff[0].Nickname != "jade" || staff[1].Role != models.RoleModerator {
+		t.Errorf("ListStaff() = %+v, %v, want jade the admin then kim the moderator", staff, err)
+	}
+}
+
+func TestStoreReports(t *testing.T) {
+	forEachDialect(t, testStoreReports)
+}
+
+func testStoreReports(t *testing.T, db *sql.DB, dialect database.Dialect) {
+	store := newTestStore(t, db, dialect)
+
+	for _, nick := range []string{"lena", "milo", "nora", "omar"} {
+		store.InsertUser(models.User{Nickname: nick, Age: "30", Firstname: nick, Lastname: "Test", Email: nick + "@example.com"})
+	}
+	lena, _ := store.GetUserID("lena")
+	milo, _ := store.GetUserID("milo")
+	nora, _ := store.GetUserID("nora")
+	omar, _ := store.GetUserID("omar")
+
+	postID, err := store.InsertPost(milo, &models.Post{Title: "Deals", Content: "Cheap watches", Category: []string{"general"}, CreatedAt: time.Now().Format(time.RFC3339)})
+	if err != nil {
+		t.Fatalf("InsertPost() error = %v", err)
+	}
+	if err := store.AddComment(int(postID), lena, 0, &models.Post{Content: "Go away"}); err != nil {
+		t.Fatalf("AddComment() error = %v", err)
+	}
+	var commentID int
+	if err := db.QueryRow(`SELECT id FROM comments`).Scan(&commentID); err != nil {
+		t.Fatalf("reading comment id: %v", err)
+	}
+	msg := models.Message{SenderID: milo, Sender: "milo", ReceiverID: lena, Receiver: "lena", Content: "Buy now"}
+	if err := store.SaveMessage(&msg); err != nil || msg.ID == 0 {
+		t.Fatalf("SaveMessage() id = %d, %v", msg.ID, err)
+	}
+
+	targets := []struct {
+		targetType string
+		targetID   int
+		reporter   int
+		want       int
+	}{
+		{models.ReportPost, int(postID), lena, milo},
+		{models.ReportComment, commentID, milo, lena},
+		{models.ReportMessage, msg.ID, lena, milo},
+	}
+	for _, tt := range targets {
+		if got, err := store.ReportedUser(tt.targetType, tt.targetID, tt.reporter); err != nil || got != tt.want {
+			t.Errorf("ReportedUser(%s) = %d, %v, want %d", tt.targetType, got, err, tt.want)
+		}
+	}
+	// Only the receiver can report a private message.
+	if _, err := store.ReportedUser(models.ReportMessage, msg.ID, nora); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("ReportedUser() of someone else's message error = %v, want sql.ErrNoRows", err)
+	}
+
+	id, err := store.CreateReport(lena, models.ReportPost, int(postID), milo, "spam", "")
+	if err != nil {
+		t.Fatalf("CreateReport() error = %v", err)
+	}
+	var dup *database.DuplicateError
+	if _, err := store.CreateReport(lena, models.ReportPost, int(postID), milo, "hate", ""); !errors.As(err, &dup) {
+		t.Errorf("second CreateReport() error = %v, want *DuplicateError", err)
+	}
+	if _, err := store.CreateReport(lena, models.ReportMessage, msg.ID, milo, "spam", ""); err != nil {
+		t.Fatalf("CreateReport() of a message error = %v", err)
+	}
+
+	open, err := store.ListReports(models.ReportOpen, 10, 0)
+	if err != nil || len(open) != 2 {
+		t.Fatalf("ListReports() = %d reports, %v, want 2", len(open), err)
+	}
+	if r := open[0]; r.ID != id || r.Reporter != "lena" || r.ReportedUser != "milo" || r.Excerpt != "Cheap watches" {
+		t.Errorf("ListReports()[0] = %+v", r)
+	}
+	if open[1].Excerpt != "Buy now" {
+		t.Errorf("message report excerpt = %q, want %q", open[1].Excerpt, "Buy now")
+	}
+
+	if err := store.ClaimReport(id, nora); err != nil {
+		t.Fatalf("ClaimReport() error = %v", err)
+	}
+	if err := store.ClaimReport(id, nora); err != nil {
+		t.Errorf("reclaiming own report error = %v", err)
+	}
+	if err := store.ClaimReport(id, omar); !errors.Is(err, database.ErrReportClaimed) {
+		t.Errorf("ClaimReport() by another moderator error = %v, want ErrReportClaimed", err)
+	}
+	if err := store.ResolveReport(id, omar, models.ReportDismissed, ""); !errors.Is(err, database.ErrReportClaimed) {
+		t.Errorf("ResolveReport() by another moderator error = %v, want ErrReportClaimed", err)
+	}
+	if err := store.ResolveReport(id, nora, models.ReportActioned, "post removed"); err != nil {
+		t.Fatalf("ResolveReport() error = %v", err)
+	}
+	if err := store.ResolveReport(id, nora, models.ReportDismissed, ""); !errors.Is(err, database.ErrReportClosed) {
+		t.Errorf("second ResolveReport() error = %v, want ErrReportClosed", err)
+	}
+	if err := store.ClaimReport(1<<20, nora); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("ClaimReport() of an unknown report error = %v, want sql.ErrNoRows", err)
+	}
+
+	report, err := store.GetReport(id)
+	if err != nil {
+		t.Fatalf("GetReport() error = %v", err)
+	}
+	if report.Status != models.ReportActioned || report.ClaimedBy != "nora" || report.ResolvedBy != "nora" ||
+		report.Resolution != "post removed" || report.ResolvedAt == nil {
+		t.Errorf("GetReport() = %+v", report)
+	}
+
+	// Resolving an unclaimed report claims it on the way.
+	if err := store.ResolveReport(open[1].ID, omar, models.ReportDismissed, ""); err != nil {
+		t.Fatalf("ResolveReport() of an unclaimed report error = %v", err)
+	}
+	if report, _ := store.GetReport(open[1].ID); report == nil || report.ClaimedBy != "omar" {
+		t.Errorf("unclaimed report after resolution = %+v, want claimed by omar", report)
+	}
+}
+
+func TestStorePostRevisions(t *testing.T) {
+	forEachDialect(t, testStorePostRevisions)
+}
+
+func testStorePostRevisions(t *testing.T, db *sql.DB, dialect database.Dialect) {
+	store := newTestStore(t, db, dialect)
+
+	store.InsertUser(models.User{Nickname: "pia", Age: "31", Firstname: "Pia", Lastname: "Ek", Email: "pia@example.com"})
+	store.InsertUser(models.User{Nickname: "quin", Age: "33", Firstname: "Quin", Lastname: "Ho", Email: "quin@example.com"})
+	pia, _ := store.GetUserID("pia")
+	quin, _ := store.GetUserID("quin")
+
+	postID, err := store.InsertPost(pia, &models.Post{Title: "Draft", Content: "First words", Category: []string{"general"}, CreatedAt: time.Now().Format(time.RFC3339)})
+	if err != nil {
+		t.Fatalf("InsertPost() error = %v", err)
+	}
+
+	post, author, err := store.GetPost(int(postID))
+	if err != nil || author != pia || post.Title != "Draft" || post.Edited {
+		t.Fatalf("GetPost() = %+v, %d, %v", post, author, err)
+	}
+	if revisions, err := store.ListPostRevisions(int(postID)); err != nil || len(revisions) != 1 || revisions[0].Editor != "pia" {
+		t.Fatalf("ListPostRevisions() of an unedited post = %+v, %v", revisions, err)
+	}
+
+	edit := &models.Post{Title: "Final", Content: "Better words", Category: []string{"general", "news"}}
+	if err := store.UpdatePost(int(postID), pia, edit); err != nil || !edit.Edited || edit.UpdatedAt == "" {
+		t.Fatalf("UpdatePost() = %+v, %v", edit, err)
+	}
+	if err := store.UpdatePost(int(postID), quin, &models.Post{Title: "Final", Content: "Moderated words", Category: []string{"news"}}); err != nil {
+		t.Fatalf("UpdatePost() by a moderator error = %v", err)
+	}
+	if err := store.UpdatePost(1<<20, pia, edit); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("UpdatePost() of an unknown post error = %v, want sql.ErrNoRows", err)
+	}
+
+	revisions, err := store.ListPostRevisions(int(postID))
+	if err != nil || len(revisions) != 3 {
+		t.Fatalf("ListPostRevisions() = %d revisions, %v, want 3", len(revisions), err)
+	}
+	want := []struct {
+		title, content, editor string
+		categories             int
+	}{
+		{"Draft", "First words", "pia", 1},
+		{"Final", "Better words", "pia", 2},
+		{"Final", "Moderated words", "quin", 1},
+	}
+	for i, w := range want {
+		rev := revisions[i]
+		if rev.Revision != i+1 || rev.Title != w.title || rev.Content != w.content || rev.Editor != w.editor || len(rev.Category) != w.categories || rev.EditedAt == "" {
+			t.Errorf("revision %d = %+v, want %+v", i+1, rev, w)
+		}
+	}
+	if _, err := store.ListPostRevisions(1 << 20); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("ListPostRevisions() of an unknown post error = %v, want sql.ErrNoRows", err)
+	}
+
+	posts, err := store.GetAllPosts("")
+	if err != nil || len(posts) != 1 || !posts[0].Edited || posts[0].UpdatedAt == "" || posts[0].Content != "Moderated words" {
+		t.Errorf("GetAllPosts() after edits = %+v, %v", posts, err)
+	}
+}
+
+func TestStoreSoftDelete(t *testing.T) {
+	forEachDialect(t, testStoreSoftDelete)
+}
+
+func testStoreSoftDelete(t *testing.T, db *sql.DB, dialect database.Dialect) {
+	store := newTestStore(t, db, dialect)
+
+	store.InsertUser(models.User{Nickname: "rae", Age: "27", Firstname: "Rae", Lastname: "Vo", Email: "rae@example.com"})
+	store.InsertUser(models.User{Nickname: "sol", Age: "41", Firstname: "Sol", Lastname: "Wu", Email: "sol@example.com"})
+	rae, _ := store.GetUserID("rae")
+	sol, _ := store.GetUserID("sol")
+
+	postID, err := store.InsertPost(rae, &models.Post{Title: "Hello", Content: "Going soon", Category: []string{"general"}, CreatedAt: time.Now().Format(time.RFC3339)})
+	if err != nil {
+		t.Fatalf("InsertPost() error = %v", err)
+	}
+	post := int(postID)
+	store.ToggleReaction(sol, post, "like")
+	comment := &models.Post{Content: "Keep it"}
+	if err := store.AddComment(post, sol, 0, comment); err != nil {
+		t.Fatalf("AddComment() error = %v", err)
+	}
+	commentID := comment.Comments[0].ID
+	store.ToggleCommentReaction(rae, commentID, "like")
+	store.AddComment(post, rae, 0, &models.Post{Content: "Reply"})
+
+	if c, err := store.GetComment(commentID); err != nil || c.UserID != sol {
+		t.Fatalf("GetComment() = %+v, %v", c, err)
+	}
+	if err := store.DeleteComment(commentID, sol); err != nil {
+		t.Fatalf("DeleteComment() error = %v", err)
+	}
+	if _, err := store.GetComment(commentID); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetComment() of a deleted comment error = %v, want sql.ErrNoRows", err)
+	}
+
+	// A moderator deletes the post; it stays listed as a tombstone.
+	if err := store.DeletePost(post, sol); err != nil {
+		t.Fatalf("DeletePost() error = %v", err)
+	}
+	if err := store.DeletePost(post, sol); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("second DeletePost() error = %v, want sql.ErrNoRows", err)
+	}
+	posts, err := store.GetAllPosts("")
+	if err != nil || len(posts) != 1 {
+		t.Fatalf("GetAllPosts() = %d posts, %v, want the tombstone", len(posts), err)
+	}
+	tomb := posts[0]
+	if !tomb.Deleted || tomb.DeletedBy != models.DeletedByModerator || tomb.DeletedAt == "" || tomb.Title != "" || tomb.Content != "" || tomb.Username != "" {
+		t.Errorf("post tombstone = %+v", tomb)
+	}
+	if len(tomb.Comments) != 2 || !tomb.Comments[0].Deleted || tomb.Comments[0].DeletedBy != models.DeletedByAuthor ||
+		tomb.Comments[0].Content != "" || tomb.Comments[1].Content != "Reply" {
+		t.Errorf("comments under the tombstone = %+v", tomb.Comments)
+	}
+	if _, _, err := store.GetPost(post); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetPost() of a deleted post error = %v, want sql.ErrNoRows", err)
+	}
+	if err := store.AddComment(post, rae, 0, &models.Post{Content: "Too late"}); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("AddComment() on a deleted post error = %v, want sql.ErrNoRows", err)
+	}
+
+	if err := store.RestorePost(post); err != nil {
+		t.Fatalf("RestorePost() error = %v", err)
+	}
+	if err := store.RestorePost(post); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("RestorePost() of a live post error = %v, want sql.ErrNoRows", err)
+	}
+	if p, _, err := store.GetPost(post); err != nil || p.Content != "Going soon" {
+		t.Errorf("GetPost() after restore = %+v, %v", p, err)
+	}
+	if err := store.DeletePost(post, rae); err != nil {
+		t.Fatalf("DeletePost() error = %v", err)
+	}
+
+	// Nothing is old enough yet; then everything is.
+	if p, c, err := store.PurgeDeleted(time.Now().Add(-time.Hour)); err != nil || p != 0 || c != 0 {
+		t.Errorf("PurgeDeleted() of recent deletions = %d, %d, %v, want 0, 0", p, c, err)
+	}
+	if p, c, err := store.PurgeDeleted(time.Now().Add(time.Minute)); err != nil || p != 1 || c != 2 {
+		t.Errorf("PurgeDeleted() = %d, %d, %v, want 1 post and 2 comments", p, c, err)
+	}
+	if posts, err := store.GetAllPosts(""); err != nil || len(posts) != 0 {
+		t.Errorf("GetAllPosts() after purge = %d posts, %v, want none", len(posts), err)
+	}
+	if err := store.RestorePost(post); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("RestorePost() of a purged post error = %v, want sql.ErrNoRows", err)
+	}
+}
+
+func TestStoreCommentThreads(t *testing.T) {
+	forEachDialect(t, testStoreCommentThreads)
+}
+
+func testStoreCommentThreads(t *testing.T, db *sql.DB, dialect database.Dialect) {
+	store := newTestStore(t, db, dialect)
+
+	store.InsertUser(models.User{Nickname: "tao", Age: "26", Firstname: "Tao", Lastname: "Li", Email: "tao@example.com"})
+	tao, _ := store.GetUserID("tao")
+	newPost := func() int {
+		id, err := store.InsertPost(tao, &models.Post{Title: "Thread", Content: "Discuss", Category: []string{"general"}, CreatedAt: time.Now().Format(time.RFC3339)})
+		if err != nil {
+			t.Fatalf("InsertPost() error = %v", err)
+		}
+		return int(id)
+	}
+	post, other := newPost(), newPost()
+
+	add := func(postID, parentID int, content string) int {
+		t.Helper()
+		holder := &models.Post{Content: content}
+		if err := store.AddComment(postID, tao, parentID, holder); err != nil {
+			t.Fatalf("AddComment(%q) error = %v", content, err)
+		}
+		return holder.Comments[0].ID
+	}
+	first := add(post, 0, "first")
+	add(post, 0, "second")
+	reply := add(post, first, "reply")
+	nested := add(post, reply, "nested")
+	add(post, first, "another reply")
+
+	if c, err := store.GetComment(nested); err != nil || c.ParentID != reply || c.Depth != 2 || strings.Count(c.Path, "/") != 2 {
+		t.Errorf("GetComment() of a nested reply = %+v, %v", c, err)
+	}
+	if err := store.AddComment(other, tao, first, &models.Post{Content: "wrong post"}); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("AddComment() replying across posts error = %v, want sql.ErrNoRows", err)
+	}
+
+	contents := func(comments []models.Comment) string {
+		var list []string
+		for _, c := range comments {
+			list = append(list, strings.Repeat(">", c.Depth)+c.Content)
+		}
+		return strings.Join(list, " ")
+	}
+	tests := []struct {
+		name           string
+		parent, levels int
+		want           string
+	}{
+		{"Whole thread", 0, 0, "first >reply >>nested >another reply second"},
+		{"Top level only", 0, 1, "first second"},
+		{"Subtree", first, 0, ">reply >>nested >another reply"},
+		{"Subtree one level", first, 1, ">reply >another reply"},
+		{"Leaf", nested, 0, ""},
+	}
+	for _, tt := range tests {
+		comments, err := store.ListComments(post, tt.parent, tt.levels)
+		if err != nil || contents(comments) != tt.want {
+			t.Errorf("%s: ListComments() = %q, %v, want %q", tt.name, contents(comments), err, tt.want)
+		}
+	}
+	if top, _ := store.ListComments(post, 0, 1); len(top) != 2 || top[0].Replies != 2 || top[1].Replies != 0 {
+		t.Errorf("reply counts = %+v", top)
+	}
+	if _, err := store.ListComments(post, 1<<20, 0); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("ListComments() under an unknown comment error = %v, want sql.ErrNoRows", err)
+	}
+
+	// A deleted comment with replies holds the thread together until they
+	// are gone too.
+	store.DeleteComment(reply, tao)
+	if err := store.AddComment(post, tao, reply, &models.Post{Content: "to a tombstone"}); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("AddComment() replying to a deleted comment error = %v, want sql.ErrNoRows", err)
+	}
+	later := time.Now().Add(time.Minute)
+	if _, c, err := store.PurgeDeleted(later); err != nil || c != 0 {
+		t.Errorf("PurgeDeleted() with live replies = %d, %v, want 0", c, err)
+	}
+	store.DeleteComment(nested, tao)
+	if _, c, err := store.PurgeDeleted(later); err != nil || c != 1 {
+		t.Errorf("PurgeDeleted() of the leaf = %d, %v, want 1", c, err)
+	}
+	if _, c, err := store.PurgeDeleted(later); err != nil || c != 1 {
+		t.Errorf("PurgeDeleted() of the emptied parent = %d, %v, want 1", c, err)
+	}
+	if comments, _ := store.ListComments(post, 0, 0); contents(comments) != "first >another reply second" {
+		t.Errorf("thread after purge = %q", contents(comments))
+	}
+
+	// A deleted post's thread cannot be listed, whole or by branch.
+	store.DeletePost(post, tao)
+	for _, parent := range []int{0, first} {
+		if comments, err := store.ListComments(post, parent, 0); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("ListComments(parent %d) on a deleted post = %q, %v, want sql.ErrNoRows", parent, contents(comments), err)
+		}
+	}
+}
+
+func TestStoreCommentEdits(t *testing.T) {
+	forEachDialect(t, testStoreCommentEdits)
+}
+
+func testStoreCommentEdits(t *testing.T, db *sql.DB, dialect database.Dialect) {
+	store := newTestStore(t, db, dialect)
+
+	store.InsertUser(models.User{Nickname: "uma", Age: "31", Firstname: "Uma", Lastname: "Rao", Email: "uma@example.com"})
+	uma, _ := store.GetUserID("uma")
+	postID, _ := store.InsertPost(uma, &models.Post{Title: "Edits", Content: "Body", Category: []string{"general"}, CreatedAt: time.Now().Format(time.RFC3339)})
+	holder := &models.Post{Content: "first draft"}
+	if err := store.AddComment(int(postID), uma, 0, holder); err != nil {
+		t.Fatalf("AddComment() error = %v", err)
+	}
+	id := holder.Comments[0].ID
+
+	comment, err := store.GetComment(id)
+	if err != nil || comment.Edited {
+		t.Fatalf("GetComment() = %+v, %v, want an unedited comment", comment, err)
+	}
+	comment.Content = "second draft"
+	if err := store.UpdateComment(comment); err != nil || !comment.Edited || comment.UpdatedAt == "" {
+		t.Fatalf("UpdateComment() = %+v, %v", comment, err)
+	}
+	if listed, _ := store.ListComments(int(postID), 0, 0); len(listed) != 1 || listed[0].Content != "second draft" || !listed[0].Edited {
+		t.Errorf("ListComments() after edit = %+v", listed)
+	}
+
+	store.ToggleCommentReaction(uma, id, "like")
+	if likes, _, _ := store.GetCommentReactionCounts(id); likes != 1 {
+		t.Fatalf("likes before delete = %d, want 1", likes)
+	}
+	if err := store.DeleteComment(id, uma); err != nil {
+		t.Fatalf("DeleteComment() error = %v", err)
+	}
+	if likes, dislikes, _ := store.GetCommentReactionCounts(id); likes != 0 || dislikes != 0 {
+		t.Errorf("reactions after delete = %d, %d, want none", likes, dislikes)
+	}
+	if err := store.UpdateComment(comment); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("UpdateComment() of a deleted comment error = %v, want sql.ErrNoRows", err)
+	}
+}
@@ -0,0 +1,165 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nyagooh/Real-time-forum.git/backend/models"
+)
+
+// GetTwoFactor returns the user's TOTP enrollment, or nil if they never
+// started one.
+func (s *Store) GetTwoFactor(userID int) (*models.TwoFactor, error) {
+	query := `
+	SELECT secret, enabled_at IS NOT NULL, last_step
+	FROM two_factor
+	WHERE user_id = ?`
+
+	var tf models.TwoFactor
+	err := s.queryRow(query, userID).Scan(&tf.Secret, &tf.Enabled, &tf.LastStep)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("database error: %v", err)
+	}
+
+	return &tf, nil
+}
+
+// StartTwoFactor stores a new secret awaiting confirmation, replacing an
+// earlier unconfirmed one.
+func (s *Store) StartTwoFactor(userID int, secret string) error {
+	return s.withTx(func(t tx) error {
+		if _, err := t.exec(`DELETE FROM two_factor WHERE user_id = ? AND enabled_at IS NULL`, userID); err != nil {
+			return err
+		}
+		_, err := t.exec(`INSERT INTO two_factor (user_id, secret) VALUES (?, ?)`, userID, secret)
+		return err
+	})
+}
+
+// EnableTwoFactor turns on a confirmed enrollment, remembering step as used,
+// and replaces the user's recovery codes.
+func (s *Store) EnableTwoFactor(userID int, step int64, recoveryHashes []string) error {
+	return s.withTx(func(t tx) error {
+		result, err := t.exec(`
+		UPDATE two_factor
+		SET enabled_at = ?, last_step = ?
+		WHERE user_id = ? AND enabled_at IS NULL`,
+			time.Now(), step, userID)
+		if err != nil {
+			return err
+		}
+		if n, err := result.RowsAffected(); err != nil {
+			return err
+		} else if n == 0 {
+			return fmt.Errorf("no two-factor enrollment to confirm")
+		}
+
+		if _, err := t.exec(`DELETE FROM recovery_codes WHERE user_id = ?`, userID); err != nil {
+			return err
+		}
+		for _, hash := range recoveryHashes {
+			if _, err := t.exec(`INSERT INTO recovery_codes (user_id, code_hash) VALUES (?, ?)`, userID, hash); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// DisableTwoFactor removes the user's secret and recovery codes.
+func (s *Store) DisableTwoFactor(userID int) error {
+	return s.withTx(func(t tx) error {
+		if _, err := t.exec(`DELETE FROM recovery_codes WHERE user_id = ?`, userID); err != nil {
+			return err
+		}
+		_, err := t.exec(`DELETE FROM two_factor WHERE user_id = ?`, userID)
+		return err
+	})
+}
+
+// UseTOTPStep records step as the newest accepted time step. It reports false
+// if that step, or a later one, was already used.
+func (s *Store) UseTOTPStep(userID int, step int64) (bool, error) {
+	query := `
+	UPDATE two_factor
+	SET last_step = ?
+	WHERE user_id = ? AND last_step < ?`
+
+	return s.affectsRow(query, step, userID, step)
+}
+
+// UseRecoveryCode spends one of the user's recovery codes. It reports false if
+// the code is unknown or already used.
+func (s *Store) UseRecoveryCode(userID int, codeHash string) (bool, error) {
+	query := `
+	UPDATE recovery_codes
+	SET used_at = ?
+	WHERE user_id = ? AND code_hash = ? AND used_at IS NULL`
+
+	return s.affectsRow(query, time.Now(), userID, codeHash)
+}
+
+// CountRecoveryCodes returns how many unused recovery codes the user has left.
+func (s *Store) CountRecoveryCodes(userID int) (int, error) {
+	var n int
+	err := s.queryRow(`SELECT COUNT(*) FROM recovery_codes WHERE user_id = ? AND used_at IS NULL`, userID).Scan(&n)
+	return n, err
+}
+
+// CreatePendingLogin stores a login that still needs its second factor, and
+// forgets the ones that expired.
+func (s *Store) CreatePendingLogin(userID int, tokenHash string, expiresAt time.Time) error {
+	if _, err := s.exec(`DELETE FROM pending_logins WHERE expires_at < ?`, time.Now()); err != nil {
+		return err
+	}
+
+	query := `
+	INSERT INTO pending_logins (token_hash, user_id, expires_at)
+	VALUES (?, ?, ?)`
+
+	_, err := s.exec(query, tokenHash, userID, expiresAt)
+
+	return err
+}
+
+// CheckPendingLogin counts an attempt against a pending login and returns its
+// user. It returns ErrInvalidToken once the login expired or ran out of
+// attempts.
+func (s *Store) CheckPendingLogin(tokenHash string, maxAttempts int) (int, error) {
+	query := `
+	UPDATE pending_logins
+	SET attempts = attempts + 1
+	WHERE token_hash = ? AND expires_at > ? AND attempts < ?`
+
+	ok, err := s.affectsRow(query, tokenHash, time.Now(), maxAttempts)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return 0, ErrInvalidToken
+	}
+
+	var userID int
+	err = s.queryRow(`SELECT user_id FROM pending_logins WHERE token_hash = ?`, tokenHash).Scan(&userID)
+	return userID, err
+}
+
+func (s *Store) DeletePendingLogin(tokenHash string) error {
+	_, err := s.exec(`DELETE FROM pending_logins WHERE token_hash = ?`, tokenHash)
+	return err
+}
+
+// affectsRow runs an UPDATE or DELETE and reports whether it changed a row.
+func (s *Store) affectsRow(query string, args ...any) (bool, error) {
+	result, err := s.exec(query, args...)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
@@ -3,26 +3,41 @@ package database
 import (
 	"database/sql"
 	"fmt"
+	"log/slog"
+	"time"
 
-	"github.com/nyagooh/Real-time-forum.git/backend/errLog"
 	"github.com/nyagooh/Real-time-forum.git/backend/models"
 )
 
-func InsertUser(user models.User) error {
+// DuplicateError means an insert clashed with an existing row on Field.
+type DuplicateError struct {
+	Field string
+}
+
+func (e *DuplicateError) Error() string {
+	return e.Field + " is already taken"
+}
+
+// InsertUser adds an account. A nickname or email already in use, in any
+// case, gives a *DuplicateError naming it.
+func (s *Store) InsertUser(user models.User) error {
 	query := `INSERT INTO users (nickname, age, gender, firstname, lastname, email, password)
 	VALUES (?, ?, ?, ?, ?, ?, ?)`
-	_, err := DB.Exec(query, user.Nickname, user.Age, user.Gender, user.Firstname, user.Lastname, user.Email, user.Password)
+	_, err := s.exec(query, user.Nickname, user.Age, user.Gender, user.Firstname, user.Lastname, user.Email, user.Password)
+	if column, ok := uniqueViolation(err); ok {
+		return &DuplicateError{Field: column}
+	}
 
 	return err
 }
 
-func GetUser(credential models.Credentials) (user models.UserIdentity, check string, err error) {
+func (s *Store) GetUser(credential models.Credentials) (user models.UserIdentity, check string, err error) {
 	query := `
-	SELECT id, nickname, email, password
+	SELECT id, nickname, email, verified_at IS NOT NULL, role, password
 	FROM users
-	WHERE (nickname = ? OR email = ?)`
+	WHERE (nickname = ? OR email = lower(?))`
 
-	err = DB.QueryRow(query, credential.Identity, credential.Identity).Scan(&user.ID, &user.Nickname, &user.Email, &check)
+	err = s.queryRow(query, credential.Identity, credential.Identity).Scan(&user.ID, &user.Nickname, &user.Email, &user.Verified, &user.Role, &check)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return user, "", fmt.Errorf("user not found: %v", err)
@@ -33,14 +48,14 @@ func GetUser(credential models.Credentials) (user models.UserIdentity, check str
 	return user, check, err
 }
 
-func GetUsers() ([]string, error) {
+func (s *Store) GetUsers() ([]string, error) {
 	query := `
 	SELECT nickname
 	FROM users
 	`
-	rows, err := DB.Query(query)
+	rows, err := s.query(query)
 	if err != nil {
-		errLog.Error.Println(err.Error())
+		slog.Error("Failed to query users", "err", err)
 		return nil, err
 	}
 	defer rows.Close()
@@ -49,29 +64,29 @@ func GetUsers() ([]string, error) {
 	for rows.Next() {
 		var user string
 		if err := rows.Scan(&user); err != nil {
-			errLog.Error.Println(err.Error())
+			slog.Error("Failed to scan user", "err", err)
 			return nil, err
 		}
 		users = append(users, user)
 	}
 
 	if err = rows.Err(); err != nil {
-		errLog.Error.Println(err.Error())
+		slog.Error("Failed to iterate users", "err", err)
 		return nil, err
 	}
 
 	return users, nil
 }
 
-func GetUserID(identity string) (int, error) {
+func (s *Store) GetUserID(identity string) (int, error) {
 	query := `
         SELECT id 
         FROM users 
-        WHERE nickname = ? OR email = ?
+        WHERE nickname = ? OR email = lower(?)
 		LIMIT 1`
 
 	var userID int
-	err := DB.QueryRow(query, identity, identity).Scan(&userID)
+	err := s.queryRow(query, identity, identity).Scan(&userID)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return 0, fmt.Errorf("user not found: %v", err)
@@ -82,14 +97,14 @@ func GetUserID(identity string) (int, error) {
 	return userID, nil
 }
 
-func GetUserByID(id int) (string, error) {
+func (s *Store) GetUserByID(id int) (string, error) {
 	query := `
 	SELECT nickname
 	FROM users
 	WHERE id = ?`
 
 	var nickname string
-	err := DB.QueryRow(query, id).Scan(&nickname)
+	err := s.queryRow(query, id).Scan(&nickname)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return "", fmt.Errorf("user not found: %v", err)
@@ -99,3 +114,109 @@ func GetUserByID(id int) (string, error) {
 
 	return nickname, nil
 }
+
+func (s *Store) GetUserEmail(id int) (string, error) {
+	query := `
+	SELECT email
+	FROM users
+	WHERE id = ?`
+
+	var email string
+	err := s.queryRow(query, id).Scan(&email)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", fmt.Errorf("user not found: %v", err)
+		}
+		return "", fmt.Errorf("database error: %v", err)
+	}
+
+	return email, nil
+}
+
+// IsVerified reports whether the user has confirmed their email address.
+func (s *Store) IsVerified(id int) (bool, error) {
+	query := `
+	SELECT verified_at IS NOT NULL
+	FROM users
+	WHERE id = ?`
+
+	var verified bool
+	err := s.queryRow(query, id).Scan(&verified)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return false, fmt.Errorf("user not found: %v", err)
+		}
+		return false, fmt.Errorf("database error: %v", err)
+	}
+
+	return verified, nil
+}
+
+// GetUsersByInteraction lists every other user, most recent conversation first.
+func (s *Store) GetUsersByInteraction(userID int) ([]models.ChatUser, error) {
+	query := fmt.Sprintf(`
+       WITH last_messages AS (
+			SELECT
+        		u.id AS id,
+        		u.nickname,
+        		COALESCE(
+            		(SELECT %s
+             		FROM messages m
+             		WHERE (m.sender_id = u.id AND m.receiver_id = ?)
+                		OR (m.sender_id = ? AND m.receiver_id = u.id)
+            		), 
+            		''
+        		) AS sort_time
+    		FROM 
+       			users u
+    		WHERE 
+        		u.id != ?
+		)
+		SELECT 
+    		id AS id,
+    		nickname,
+    		sort_time
+		FROM 
+    		last_messages
+		ORDER BY 
+    		CASE WHEN sort_time = '' THEN 1 ELSE 0 END,
+    		sort_time DESC,
+    		nickname ASC;
+    `, s.dialect.isoTimestamp("MAX(m.timestamp)"))
+
+	rows, err := s.query(query, userID, userID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []models.ChatUser
+	for rows.Next() {
+		var user models.ChatUser
+		var lastMessageTime sql.NullString
+
+		err := rows.Scan(&user.ID, &user.Username, &lastMessageTime)
+		if err != nil {
+			return nil, err
+		}
+
+		if lastMessageTime.Valid {
+			// Parse the timestamp and format it
+			if t, err := time.Parse(time.RFC3339, lastMessageTime.String); err == nil {
+				user.Lasttime = t.Format(time.RFC3339) // Convert to ISO8601 format
+			} else {
+				user.Lasttime = "" // If parsing fails, set empty string
+			}
+		} else {
+			user.Lasttime = "" // Set empty string if no interaction
+		}
+
+		users = append(users, user)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
@@ -1,45 +1,133 @@
 package errLog
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"log"
+	"log/slog"
 	"os"
-)
+	"strings"
 
-// Global variables for loggers and file handles
-var (
-	Info      *log.Logger
-	Error     *log.Logger
-	infoFile  *os.File
-	errorFile *os.File
+	"github.com/nyagooh/Real-time-forum.git/backend/config"
 )
 
-// Initialize loggers and keep file handles open
-func InitLoggers() {
-	var err error
+// Open log files, reopened by Reopen and closed by CloseLoggers
+var files []*RotatingFile
 
-	infoFile, err = os.OpenFile("backend/errLog/info.log", os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
+// InitLoggers builds the default slog logger from the log settings. With the
+// file output, every record at or above the configured level goes to the info
+// log and errors are also copied to the error log.
+func InitLoggers(cfg config.Log) error {
+	level, err := ParseLevel(cfg.Level)
 	if err != nil {
-		log.Fatal("Failed to open info log file:", err)
+		return err
 	}
 
-	errorFile, err = os.OpenFile("backend/errLog/error.log", os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
-	if err != nil {
-		log.Fatal("Failed to open error log file:", err)
+	var handler slog.Handler
+	switch cfg.Output {
+	case "stdout":
+		handler = newHandler(os.Stdout, cfg.Format, level)
+	case "stderr":
+		handler = newHandler(os.Stderr, cfg.Format, level)
+	case "file":
+		opts := RotateOptions{
+			MaxSize:    cfg.MaxSize,
+			MaxAge:     cfg.MaxAge,
+			MaxBackups: cfg.MaxBackups,
+			Compress:   cfg.Compress,
+		}
+		infoFile, err := OpenRotatingFile(cfg.InfoPath, opts)
+		if err != nil {
+			return err
+		}
+		errorFile, err := OpenRotatingFile(cfg.ErrorPath, opts)
+		if err != nil {
+			infoFile.Close()
+			return err
+		}
+		files = []*RotatingFile{infoFile, errorFile}
+
+		handler = fanoutHandler{
+			newHandler(infoFile, cfg.Format, level),
+			newHandler(errorFile, cfg.Format, slog.LevelError),
+		}
+	default:
+		return fmt.Errorf("unknown log output %q", cfg.Output)
+	}
+
+	slog.SetDefault(slog.New(contextHandler{handler}))
+	return nil
+}
+
+// ParseLevel converts debug, info, warn or error into a slog level.
+func ParseLevel(level string) (slog.Level, error) {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(strings.ToUpper(level))); err != nil {
+		return l, fmt.Errorf("unknown log level %q", level)
 	}
+	return l, nil
+}
+
+// ErrorLog adapts the default logger for APIs that still want a *log.Logger,
+// such as http.Server.ErrorLog.
+func ErrorLog() *log.Logger {
+	return slog.NewLogLogger(slog.Default().Handler(), slog.LevelError)
+}
 
-	// Initialize global loggers
-	Info = log.New(infoFile, "INFO\t", log.Ldate|log.Ltime|log.Lshortfile)
-	Error = log.New(errorFile, "ERROR\t", log.Ldate|log.Ltime|log.Lshortfile)
+func newHandler(w io.Writer, format string, level slog.Level) slog.Handler {
+	opts := &slog.HandlerOptions{Level: level, AddSource: true}
+	if format == "json" {
+		return slog.NewJSONHandler(w, opts)
+	}
+	return slog.NewTextHandler(w, opts)
 }
 
 // Close log files when the application shuts down
 func CloseLoggers() {
-	if infoFile != nil {
-		infoFile.Close()
+	for _, file := range files {
+		file.Close()
 	}
-	if errorFile != nil {
-		errorFile.Close()
+	files = nil
+}
+
+// fanoutHandler sends each record to every handler that accepts its level.
+type fanoutHandler []slog.Handler
+
+func (f fanoutHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, h := range f {
+		if h.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f fanoutHandler) Handle(ctx context.Context, record slog.Record) error {
+	var firstErr error
+	for _, h := range f {
+		if !h.Enabled(ctx, record.Level) {
+			continue
+		}
+		if err := h.Handle(ctx, record.Clone()); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func (f fanoutHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	handlers := make(fanoutHandler, len(f))
+	for i, h := range f {
+		handlers[i] = h.WithAttrs(attrs)
+	}
+	return handlers
+}
+
+func (f fanoutHandler) WithGroup(name string) slog.Handler {
+	handlers := make(fanoutHandler, len(f))
+	for i, h := range f {
+		handlers[i] = h.WithGroup(name)
 	}
-	fmt.Println("Log files closed successfully.")
+	return handlers
 }
@@ -0,0 +1,275 @@
+package errLog
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Layout of the timestamp in rotated file names; it sorts chronologically.
+const backupTimeFormat = "20060102T150405.000"
+
+// RotateOptions controls when a RotatingFile rotates and what it keeps.
+type RotateOptions struct {
+	MaxSize    int64         // rotate before a write would exceed this many bytes; 0 disables
+	MaxAge     time.Duration // rotate once the file is older than this; 0 disables
+	MaxBackups int           // rotated files to keep; 0 keeps all
+	Compress   bool          // gzip rotated files
+}
+
+// RotatingFile is an io.Writer appending to a log file that is renamed to
+// <name>-<timestamp><ext> when it gets too large or too old. Rotated files are
+// compressed and pruned in the background.
+type RotatingFile struct {
+	path string
+	opts RotateOptions
+
+	mu       sync.Mutex
+	file     *os.File
+	size     int64
+	openedAt time.Time
+
+	millMu sync.Mutex // serializes compression and pruning
+	wg     sync.WaitGroup
+}
+
+// OpenRotatingFile opens path for appending, rotating it first if it is
+// already past its limits.
+func OpenRotatingFile(path string, opts RotateOptions) (*RotatingFile, error) {
+	r := &RotatingFile{path: path, opts: opts}
+
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+
+	// A file left over from a previous run may already be too large.
+	if r.due(0) {
+		if err := r.rotate(); err != nil {
+			r.file.Close()
+			return nil, err
+		}
+	}
+
+	return r, nil
+}
+
+func (r *RotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return 0, fmt.Errorf("log file %s is closed", r.path)
+	}
+
+	if r.due(int64(len(p))) {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// Reopen closes and reopens the file at its path, so that a file renamed by
+// an external logrotate is released and a fresh one created.
+func (r *RotatingFile) Reopen() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file != nil {
+		r.file.Close()
+	}
+	return r.open()
+}
+
+// Rotate rotates the file immediately.
+func (r *RotatingFile) Rotate() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.rotate()
+}
+
+// Close closes the file and waits for pending compression to finish.
+func (r *RotatingFile) Close() error {
+	r.mu.Lock()
+	var err error
+	if r.file != nil {
+		err = r.file.Close()
+		r.file = nil
+	}
+	r.mu.Unlock()
+
+	r.wg.Wait()
+	return err
+}
+
+// due reports whether writing n more bytes should first rotate the file.
+func (r *RotatingFile) due(n int64) bool {
+	if r.size == 0 {
+		return false
+	}
+	if r.opts.MaxSize > 0 && r.size+n > r.opts.MaxSize {
+		return true
+	}
+	return r.opts.MaxAge > 0 && time.Since(r.openedAt) > r.opts.MaxAge
+}
+
+func (r *RotatingFile) open() error {
+	file, err := os.OpenFile(r.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
+	if err != nil {
+		return fmt.Errorf("failed to open log file %s: %v", r.path, err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to stat log file %s: %v", r.path, err)
+	}
+
+	r.file = file
+	r.size = info.Size()
+	r.openedAt = time.Now()
+	return nil
+}
+
+// rotate renames the current file to a timestamped backup and opens a new
+// one. The caller holds r.mu.
+func (r *RotatingFile) rotate() error {
+	if r.file != nil {
+		r.file.Close()
+		r.file = nil
+	}
+
+	backup := r.backupName(time.Now())
+	if err := os.Rename(r.path, backup); err != nil && !os.IsNotExist(err) {
+		// Keep logging to the old file rather than losing records.
+		if openErr := r.open(); openErr != nil {
+			return openErr
+		}
+		return fmt.Errorf("failed to rotate log file %s: %v", r.path, err)
+	}
+
+	if err := r.open(); err != nil {
+		return err
+	}
+
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		r.mill(backup)
+	}()
+
+	return nil
+}
+
+func (r *RotatingFile) backupName(t time.Time) string {
+	dir, name := filepath.Split(r.path)
+	ext := filepath.Ext(name)
+	prefix := strings.TrimSuffix(name, ext)
+	return filepath.Join(dir, prefix+"-"+t.Format(backupTimeFormat)+ext)
+}
+
+// mill compresses a freshly rotated backup and removes backups beyond the
+// retention count. Failures are reported on stderr, since the logger that
+// would record them is the one writing to this file.
+func (r *RotatingFile) mill(backup string) {
+	r.millMu.Lock()
+	defer r.millMu.Unlock()
+
+	if r.opts.Compress {
+		if err := compressFile(backup); err != nil {
+			fmt.Fprintf(os.Stderr, "errLog: %v\n", err)
+		}
+	}
+
+	if r.opts.MaxBackups > 0 {
+		backups, err := r.Backups()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "errLog: %v\n", err)
+			return
+		}
+		for i := 0; i < len(backups)-r.opts.MaxBackups; i++ {
+			os.Remove(backups[i])
+		}
+	}
+}
+
+// Backups lists the rotated files of this log, oldest first.
+func (r *RotatingFile) Backups() ([]string, error) {
+	dir, name := filepath.Split(r.path)
+	if dir == "" {
+		dir = "."
+	}
+	ext := filepath.Ext(name)
+	prefix := strings.TrimSuffix(name, ext) + "-"
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list log backups: %v", err)
+	}
+
+	var backups []string
+	for _, entry := range entries {
+		n := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(n, prefix) {
+			continue
+		}
+		stamp := strings.TrimSuffix(strings.TrimSuffix(n, ".gz"), ext)
+		stamp = strings.TrimPrefix(stamp, prefix)
+		if _, err := time.Parse(backupTimeFormat, stamp); err != nil {
+			continue
+		}
+		backups = append(backups, filepath.Join(dir, n))
+	}
+
+	sort.Strings(backups)
+	return backups, nil
+}
+
+func compressFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open %s for compression: %v", path, err)
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(path+".gz", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
+	if err != nil {
+		return fmt.Errorf("failed to create %s.gz: %v", path, err)
+	}
+
+	gz := gzip.NewWriter(dst)
+	_, err = io.Copy(gz, src)
+	if err == nil {
+		err = gz.Close()
+	}
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path + ".gz")
+		return fmt.Errorf("failed to compress %s: %v", path, err)
+	}
+
+	return os.Remove(path)
+}
+
+// Reopen reopens every log file; main calls it on SIGHUP.
+func Reopen() {
+	for _, file := range files {
+		if err := file.Reopen(); err != nil {
+			fmt.Fprintf(os.Stderr, "errLog: %v\n", err)
+		}
+	}
+	slog.Info("Log files reopened")
+}
@@ -0,0 +1,79 @@
+package errLog
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nyagooh/Real-time-forum.git/backend/config"
+	"github.com/nyagooh/Real-time-forum.git/backend/errLog"
+)
+
+func TestFileOutputSplitsByLevel(t *testing.T) {
+	dir := t.TempDir()
+	cfg := config.Log{
+		Level:     "info",
+		Format:    "json",
+		Output:    "file",
+		InfoPath:  filepath.Join(dir, "info.log"),
+		ErrorPath: filepath.Join(dir, "error.log"),
+	}
+
+	previous := slog.Default()
+	if err := errLog.InitLoggers(cfg); err != nil {
+		t.Fatalf("InitLoggers: %v", err)
+	}
+	t.Cleanup(func() {
+		errLog.CloseLoggers()
+		slog.SetDefault(previous)
+	})
+
+	ctx := errLog.WithRequestID(context.Background(), "req-1")
+	slog.DebugContext(ctx, "hidden")
+	slog.InfoContext(ctx, "hello", "user_id", 7)
+	slog.ErrorContext(ctx, "boom")
+
+	infoLines := readLines(t, cfg.InfoPath)
+	if len(infoLines) != 2 {
+		t.Fatalf("info log has %d lines, want 2: %v", len(infoLines), infoLines)
+	}
+	errorLines := readLines(t, cfg.ErrorPath)
+	if len(errorLines) != 1 {
+		t.Fatalf("error log has %d lines, want 1: %v", len(errorLines), errorLines)
+	}
+
+	var record map[string]any
+	if err := json.Unmarshal([]byte(infoLines[0]), &record); err != nil {
+		t.Fatalf("info line is not JSON: %v", err)
+	}
+	if record["msg"] != "hello" || record["request_id"] != "req-1" || record["user_id"] != float64(7) {
+		t.Errorf("unexpected record: %v", record)
+	}
+	if !strings.Contains(errorLines[0], `"msg":"boom"`) {
+		t.Errorf("error log = %q, want the error record", errorLines[0])
+	}
+}
+
+func TestInitLoggersRejectsUnknownValues(t *testing.T) {
+	for _, cfg := range []config.Log{
+		{Level: "loud", Format: "text", Output: "stderr"},
+		{Level: "info", Format: "text", Output: "syslog"},
+	} {
+		if err := errLog.InitLoggers(cfg); err == nil {
+			t.Errorf("InitLoggers(%+v) succeeded, want error", cfg)
+		}
+	}
+}
+
+func readLines(t *testing.T, path string) []string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Split(strings.TrimSpace(string(data)), "\n")
+}
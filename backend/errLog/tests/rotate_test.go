@@ -0,0 +1,144 @@
+package errLog
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nyagooh/Real-time-forum.git/backend/errLog"
+)
+
+func TestRotatesBySizeAndCompresses(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "info.log")
+	file, err := errLog.OpenRotatingFile(path, errLog.RotateOptions{MaxSize: 10, Compress: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	write(t, file, "first\n")
+	write(t, file, "second\n") // would exceed 10 bytes, so rotates first
+	if err := file.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := readFile(t, path); got != "second\n" {
+		t.Errorf("current log = %q, want %q", got, "second\n")
+	}
+
+	backups, err := file.Backups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 1 || !strings.HasSuffix(backups[0], ".log.gz") {
+		t.Fatalf("backups = %v, want one gzipped file", backups)
+	}
+	if got := readGzip(t, backups[0]); got != "first\n" {
+		t.Errorf("backup = %q, want %q", got, "first\n")
+	}
+}
+
+func TestRotatesByAge(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "info.log")
+	file, err := errLog.OpenRotatingFile(path, errLog.RotateOptions{MaxAge: 20 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	write(t, file, "old\n")
+	time.Sleep(40 * time.Millisecond)
+	write(t, file, "new\n")
+	file.Close()
+
+	backups, _ := file.Backups()
+	if len(backups) != 1 || readFile(t, backups[0]) != "old\n" {
+		t.Fatalf("backups = %v, want one holding the old line", backups)
+	}
+}
+
+func TestKeepsMaxBackups(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "error.log")
+	file, err := errLog.OpenRotatingFile(path, errLog.RotateOptions{MaxBackups: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 4; i++ {
+		write(t, file, "line\n")
+		if err := file.Rotate(); err != nil {
+			t.Fatal(err)
+		}
+		// Backup names have millisecond resolution.
+		time.Sleep(2 * time.Millisecond)
+	}
+	file.Close()
+
+	backups, _ := file.Backups()
+	if len(backups) != 2 {
+		t.Errorf("kept %d backups, want 2: %v", len(backups), backups)
+	}
+}
+
+func TestReopenAfterExternalRotation(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "info.log")
+	file, err := errLog.OpenRotatingFile(path, errLog.RotateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	write(t, file, "before\n")
+	moved := filepath.Join(dir, "info.log.1")
+	if err := os.Rename(path, moved); err != nil {
+		t.Fatal(err)
+	}
+	if err := file.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	write(t, file, "after\n")
+
+	if got := readFile(t, moved); got != "before\n" {
+		t.Errorf("moved file = %q", got)
+	}
+	if got := readFile(t, path); got != "after\n" {
+		t.Errorf("reopened file = %q", got)
+	}
+}
+
+func write(t *testing.T, w io.Writer, s string) {
+	t.Helper()
+	if _, err := io.WriteString(w, s); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func readGzip(t *testing.T, path string) string {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
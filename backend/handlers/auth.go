@@ -1,16 +1,19 @@
 package handlers
 
 import (
-	"database/sql"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/nyagooh/Real-time-forum.git/backend/config"
 	"github.com/nyagooh/Real-time-forum.git/backend/database"
-	"github.com/nyagooh/Real-time-forum.git/backend/errLog"
+	"github.com/nyagooh/Real-time-forum.git/backend/mail"
 	"github.com/nyagooh/Real-time-forum.git/backend/middleware"
 	"github.com/nyagooh/Real-time-forum.git/backend/models"
 	"github.com/nyagooh/Real-time-forum.git/backend/utils"
@@ -18,7 +21,8 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
-func RegisterHandler(db *sql.DB) http.HandlerFunc {
+// RegisterHandler creates the account and emails a link to verify its address.
+func RegisterHandler(users database.UserStore, verifications database.EmailVerificationStore, mailer mail.Mailer, cfg config.Account, publicURL string) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		switch r.Method {
 
@@ -26,33 +30,66 @@ func RegisterHandler(db *sql.DB) http.HandlerFunc {
 			http.ServeFile(w, r, filepath.Join("frontend", "index.html"))
 
 		case http.MethodPost:
-			user, err := parseAndValidateUserRequest(r)
+			user, err := parseAndValidateUserRequest(r, cfg.Registration)
+			var invalid utils.ValidationError
+			if errors.As(err, &invalid) {
+				slog.WarnContext(r.Context(), "Invalid registration request", "err", err)
+				sendValidationError(w, invalid, http.StatusBadRequest)
+				return
+			}
 			if err != nil {
-				errLog.Error.Println(err.Error())
+				slog.WarnContext(r.Context(), "Invalid registration request", "err", err)
 				handleError(w, err, http.StatusBadRequest)
 				return
 			}
 
 			hashedPassword, err := utils.HashPassword(user.Password)
 			if err != nil {
-				errLog.Error.Println(err.Error())
+				slog.ErrorContext(r.Context(), "Failed to hash password", "err", err)
 				handleError(w, fmt.Errorf("failed to hash password: %v", err), http.StatusInternalServerError)
 				return
 			}
 			user.Password = hashedPassword
 
-			if err := database.InsertUser(user); err != nil {
-				errLog.Error.Println(err.Error())
+			err = users.InsertUser(user)
+			var duplicate *database.DuplicateError
+			if errors.As(err, &duplicate) {
+				slog.WarnContext(r.Context(), "Registration with taken field", "field", duplicate.Field)
+				sendValidationError(w, utils.ValidationError{{
+					Field:   duplicate.Field,
+					Code:    "taken",
+					Message: duplicate.Error(),
+				}}, http.StatusConflict)
+				return
+			}
+			if err != nil {
+				slog.ErrorContext(r.Context(), "Failed to insert user", "err", err)
 				handleError(w, fmt.Errorf("failed to insert user: %v", err), http.StatusInternalServerError)
 				return
 			}
 
+			// The account exists either way; a failed email can be resent
+			// after logging in.
+			userID, err := users.GetUserID(user.Nickname)
+			if err == nil {
+				err = sendVerificationEmail(r, verifications, mailer, cfg, publicURL, userID, user.Email)
+			}
+			if err != nil {
+				slog.ErrorContext(r.Context(), "Failed to send verification email", "nickname", user.Nickname, "err", err)
+			}
+
 			sendSuccessResponse(w, http.StatusOK, map[string]bool{"success": true})
 		}
 	}
 }
 
-func LoginHandler(db *sql.DB) http.HandlerFunc {
+// LoginHandler checks the password and starts a session. Accounts with
+// two-factor authentication get a pending token instead, to be traded for
+// the session at LoginTwoFactorHandler. Every try is logged, and repeated
+// failures slow down or lock further tries as account.login_throttle says.
+func LoginHandler(users database.UserStore, sessions database.SessionStore, twoFactor database.TwoFactorStore, attempts database.LoginAttemptStore, mailer mail.Mailer, sessionCfg config.Session, account config.Account, publicURL string) http.HandlerFunc {
+	throttle := account.LoginThrottle
+
 	return func(w http.ResponseWriter, r *http.Request) {
 		switch r.Method {
 
@@ -62,85 +99,135 @@ func LoginHandler(db *sql.DB) http.HandlerFunc {
 		case http.MethodPost:
 			credentials, err := parseAndValidateLoginRequest(r)
 			if err != nil {
-				errLog.Error.Println(err.Error())
+				slog.WarnContext(r.Context(), "Invalid login request", "err", err)
 				handleError(w, err, http.StatusBadRequest)
 				return
 			}
 
-			user, hashedPassword, err := database.GetUser(credentials)
-			if err != nil {
-				errLog.Error.Println(err.Error())
-				handleError(w, fmt.Errorf("invalid nickname or password"), http.StatusUnauthorized)
+			if err := ipThrottle(r, attempts, throttle); err != nil {
+				handleThrottleError(w, r, err)
 				return
 			}
 
-			if err := bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(credentials.Password)); err != nil {
-				errLog.Error.Println(err.Error())
+			user, hashedPassword, err := users.GetUser(credentials)
+			if err != nil {
+				slog.WarnContext(r.Context(), "Login failed: unknown user", "identity", credentials.Identity, "err", err)
+				recordLoginFailure(r, users, attempts, mailer, throttle, publicURL, 0)
 				handleError(w, fmt.Errorf("invalid nickname or password"), http.StatusUnauthorized)
 				return
 			}
 
 			id, err := utils.StrToInt(user.ID)
 			if err != nil {
-				errLog.Error.Println(err.Error())
+				slog.ErrorContext(r.Context(), "Invalid user ID", "user_id", user.ID, "err", err)
 				handleError(w, fmt.Errorf("invalid id format: %v", err), http.StatusInternalServerError)
 				return
 			}
 
-			existingSession, err := database.GetSessionToken(id)
-			if err != nil {
-				if !errors.Is(err, sql.ErrNoRows) {
-					errLog.Error.Printf("Error fetching session: %v\n", err.Error())
-					handleError(w, fmt.Errorf("server error: %w", err), http.StatusInternalServerError)
-					return
-				}
+			// Checked before the password, so a locked account cannot be
+			// guessed at.
+			if err := accountThrottle(attempts, throttle, id); err != nil {
+				handleThrottleError(w, r, err)
+				return
 			}
 
-			// If there's an existing session, delete it
-			if existingSession != "" {
-				if err := database.DeleteSession(id); err != nil {
-					errLog.Error.Printf("Error deleting session: %v\n", err.Error())
-					handleError(w, fmt.Errorf("server error: %w", err), http.StatusInternalServerError)
-					return
-				}
+			if err := bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(credentials.Password)); err != nil {
+				slog.WarnContext(r.Context(), "Login failed: wrong password", "user_id", user.ID)
+				recordLoginFailure(r, users, attempts, mailer, throttle, publicURL, id)
+				handleError(w, fmt.Errorf("invalid nickname or password"), http.StatusUnauthorized)
+				return
 			}
 
-			sessionToken, err := utils.GenerateSessionToken()
+			tf, err := twoFactor.GetTwoFactor(id)
 			if err != nil {
-				errLog.Error.Println(err.Error())
-				handleError(w, fmt.Errorf("server error: %v", err), http.StatusInternalServerError)
+				slog.ErrorContext(r.Context(), "Failed to load two-factor settings", "user_id", id, "err", err)
+				handleError(w, fmt.Errorf("server error"), http.StatusInternalServerError)
 				return
 			}
+			if tf != nil && tf.Enabled {
+				pendingToken, hash, err := utils.GenerateOneTimeToken()
+				if err == nil {
+					err = twoFactor.CreatePendingLogin(id, hash, time.Now().Add(account.TwoFactor.PendingLifetime))
+				}
+				if err != nil {
+					slog.ErrorContext(r.Context(), "Failed to create pending login", "user_id", id, "err", err)
+					handleError(w, fmt.Errorf("server error"), http.StatusInternalServerError)
+					return
+				}
 
-			expiresAt := time.Now().Add(24 * time.Hour)
-
-			if err = database.InsertSession(id, sessionToken, expiresAt); err != nil {
-				errLog.Error.Println(err.Error())
-				handleError(w, fmt.Errorf("server error"), http.StatusInternalServerError)
+				sendSuccessResponse(w, http.StatusOK, map[string]any{
+					"success":           true,
+					"twoFactorRequired": true,
+					"pendingToken":      pendingToken,
+				})
 				return
 			}
 
-			middleware.SetCookie(w, sessionToken, expiresAt)
-
-			sendSuccessResponse(w, http.StatusOK, map[string]any{
-				"success": true,
-				"user":    user,
-			})
+			if startSession(w, r, sessions, sessionCfg, id, user, credentials.Remember) {
+				recordLoginSuccess(r, attempts, id)
+			}
 		}
 	}
 }
 
-func LogoutHandler(db *sql.DB) http.HandlerFunc {
+// maxUserAgentLength caps stored user agents; they are only shown back to
+// the user, and a page's worth is plenty.
+const maxUserAgentLength = 512
+
+// startSession adds a session for this device alongside the user's others,
+// sets the session and CSRF cookies and answers with the user, reporting
+// whether it got that far. Remembered sessions last their whole cap without
+// needing renewal.
+func startSession(w http.ResponseWriter, r *http.Request, sessions database.SessionStore, sessionCfg config.Session, id int, user models.UserIdentity, remember bool) bool {
+	sessionToken, err := utils.GenerateSessionToken()
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to generate session token", "err", err)
+		handleError(w, fmt.Errorf("server error: %v", err), http.StatusInternalServerError)
+		return false
+	}
+
+	now := time.Now()
+	maxExpiresAt := now.Add(sessionCfg.Cap(remember))
+	expiresAt := now.Add(sessionCfg.Lifetime)
+	if remember {
+		expiresAt = maxExpiresAt
+	}
+
+	if err = sessions.InsertSession(id, sessionToken, expiresAt, maxExpiresAt, userAgent(r), middleware.ClientIP(r)); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to insert session", "user_id", id, "err", err)
+		handleError(w, fmt.Errorf("server error"), http.StatusInternalServerError)
+		return false
+	}
+
+	csrfToken, err := utils.GenerateCSRFToken()
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to generate CSRF token", "err", err)
+		handleError(w, fmt.Errorf("server error"), http.StatusInternalServerError)
+		return false
+	}
+
+	middleware.SetCookie(w, sessionToken, expiresAt)
+	middleware.SetCSRFCookie(w, csrfToken)
+
+	sendSuccessResponse(w, http.StatusOK, map[string]any{
+		"success":   true,
+		"user":      user,
+		"csrfToken": csrfToken,
+	})
+	return true
+}
+
+func LogoutHandler(sessions database.SessionStore) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		sessionToken, err := r.Cookie("session_token")
 		if err != nil {
-			errLog.Error.Println(err.Error())
+			slog.WarnContext(r.Context(), "Logout without session cookie", "err", err)
 			handleError(w, fmt.Errorf("no session token found"), http.StatusUnauthorized)
 			return
 		}
 
-		if err := database.DeleteSessionByToken(sessionToken.Value); err != nil {
-			errLog.Error.Println(err.Error())
+		if err := sessions.DeleteSessionByToken(sessionToken.Value); err != nil {
+			slog.ErrorContext(r.Context(), "Failed to delete session", "err", err)
 			handleError(w, fmt.Errorf("server error"), http.StatusInternalServerError)
 			return
 		}
@@ -151,12 +238,16 @@ func LogoutHandler(db *sql.DB) http.HandlerFunc {
 	}
 }
 
-func ValidateSession(db *sql.DB) http.HandlerFunc {
+func ValidateSession(sessions database.SessionStore) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// validate session from sesssion_token cookie and return user data
-		user, err := database.GetUserFromSession(db, r)
+		var user *models.UserIdentity
+		sessionToken, err := r.Cookie("session_token")
+		if err == nil {
+			user, err = sessions.GetUserFromSession(sessionToken.Value)
+		}
 		if err != nil {
-			errLog.Error.Println(err.Error())
+			slog.DebugContext(r.Context(), "No valid session", "err", err)
 			w.Header().Set("Content-Type", "application/json")
 			w.WriteHeader(http.StatusInternalServerError)
 			json.NewEncoder(w).Encode(map[string]any{
@@ -166,10 +257,26 @@ func ValidateSession(db *sql.DB) http.HandlerFunc {
 			return
 		}
 
+		// Hand the CSRF token to the page, issuing one for sessions that
+		// predate CSRF protection.
+		var csrfToken string
+		if cookie, err := r.Cookie(middleware.CSRFCookieName); err == nil && cookie.Value != "" {
+			csrfToken = cookie.Value
+		} else {
+			csrfToken, err = utils.GenerateCSRFToken()
+			if err != nil {
+				slog.ErrorContext(r.Context(), "Failed to generate CSRF token", "err", err)
+				handleError(w, fmt.Errorf("server error"), http.StatusInternalServerError)
+				return
+			}
+			middleware.SetCSRFCookie(w, csrfToken)
+		}
+
 		sendSuccessResponse(w, http.StatusOK, map[string]any{
 			"success":    true,
 			"user":       user,
 			"isLoggedIn": true,
+			"csrfToken":  csrfToken,
 		})
 	}
 }
@@ -188,17 +295,42 @@ func parseAndValidateLoginRequest(r *http.Request) (models.Credentials, error) {
 	return credentials, nil
 }
 
-func parseAndValidateUserRequest(r *http.Request) (models.User, error) {
+// parseAndValidateUserRequest decodes a registration and checks every field,
+// returning a utils.ValidationError listing all that fail.
+func parseAndValidateUserRequest(r *http.Request, cfg config.Registration) (models.User, error) {
 	var user models.User
 
 	if err := json.NewDecoder(r.Body).Decode(&user); err != nil {
 		return models.User{}, fmt.Errorf("failed to decode JSON: %v", err)
 	}
 
-	if err := utils.ValidateEmail(user.Email); err != nil {
-		return models.User{}, fmt.Errorf("invalid email: %v", err)
+	user.Nickname = strings.TrimSpace(user.Nickname)
+	user.Firstname = strings.TrimSpace(user.Firstname)
+	user.Lastname = strings.TrimSpace(user.Lastname)
+	user.Email = strings.ToLower(strings.TrimSpace(user.Email))
+	user.Gender = strings.TrimSpace(user.Gender)
+
+	var invalid utils.ValidationError
+	invalid.Add(utils.ValidateNickname(user.Nickname, cfg.ReservedNicknames))
+	invalid.Add(utils.ValidatePassword(user.Password, user.Nickname, cfg.PasswordMinLength))
+
+	age, ageErr := utils.ValidateAge(user.Age, cfg.MinAge, cfg.MaxAge)
+	invalid.Add(ageErr)
+	user.Age = strconv.Itoa(age)
+
+	invalid.Add(utils.ValidateChoice("gender", user.Gender, cfg.Genders))
+	invalid.Add(utils.ValidateName("firstname", user.Firstname))
+	invalid.Add(utils.ValidateName("lastname", user.Lastname))
+
+	if user.Email == "" {
+		invalid.Add(&utils.FieldError{Field: "email", Code: "required", Message: "email is required"})
+	} else if err := utils.ValidateEmail(user.Email); err != nil {
+		invalid.Add(&utils.FieldError{Field: "email", Code: "invalid", Message: err.Error()})
 	}
 
+	if len(invalid) > 0 {
+		return models.User{}, invalid
+	}
 	return user, nil
 }
 
@@ -211,6 +343,18 @@ func handleError(w http.ResponseWriter, err error, statusCode int) {
 	})
 }
 
+// sendValidationError answers with every failing field. message repeats the
+// first one for clients that only show a single line.
+func sendValidationError(w http.ResponseWriter, invalid utils.ValidationError, statusCode int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(map[string]any{
+		"success": false,
+		"message": invalid[0].Message,
+		"errors":  invalid,
+	})
+}
+
 func sendSuccessResponse(w http.ResponseWriter, statusCode int, data any) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(statusCode)
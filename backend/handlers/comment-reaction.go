@@ -2,68 +2,84 @@ package handlers
 
 import (
 	"fmt"
+	"log/slog"
 	"net/http"
 	"strconv"
 
 	"github.com/nyagooh/Real-time-forum.git/backend/database"
-	"github.com/nyagooh/Real-time-forum.git/backend/errLog"
 	"github.com/nyagooh/Real-time-forum.git/backend/middleware"
 )
 
 // LikeCommentHandler handles liking a comment
-func LikeCommentHandler(w http.ResponseWriter, r *http.Request) {
-	userID := r.Context().Value(middleware.UserIDKey).(int)
-	commentIDStr := r.URL.Query().Get("commentId")
+func LikeCommentHandler(reactions database.ReactionStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			slog.WarnContext(r.Context(), "Invalid request method", "method", r.Method)
+			handleError(w, fmt.Errorf("method not allowed"), http.StatusMethodNotAllowed)
+			return
+		}
 
-	commentID, err := strconv.Atoi(commentIDStr)
-	if err != nil {
-		errLog.Error.Println(err.Error())
-		handleError(w, fmt.Errorf("invalid Comment ID: %v", err), http.StatusBadRequest)
-		return
-	}
+		userID := r.Context().Value(middleware.UserIDKey).(int)
+		commentIDStr := r.URL.Query().Get("commentId")
 
-	err = database.ToggleCommentReaction(userID, commentID, "like")
-	if err != nil {
-		errLog.Error.Println(err.Error())
-		handleError(w, fmt.Errorf("failed to like comment: %v", err), http.StatusInternalServerError)
-		return
-	}
+		commentID, err := strconv.Atoi(commentIDStr)
+		if err != nil {
+			slog.WarnContext(r.Context(), "Invalid comment ID", "comment_id", commentIDStr)
+			handleError(w, fmt.Errorf("invalid Comment ID: %v", err), http.StatusBadRequest)
+			return
+		}
 
-	// Return updated reaction counts
-	likes, dislikes, _ := database.GetCommentReactionCounts(commentID)
+		err = reactions.ToggleCommentReaction(userID, commentID, "like")
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to like comment", "comment_id", commentID, "err", err)
+			handleError(w, fmt.Errorf("failed to like comment: %v", err), http.StatusInternalServerError)
+			return
+		}
 
-	sendSuccessResponse(w, http.StatusOK, map[string]any{
-		"success":  true,
-		"likes":    likes,
-		"dislikes": dislikes,
-	})
+		// Return updated reaction counts
+		likes, dislikes, _ := reactions.GetCommentReactionCounts(commentID)
+
+		sendSuccessResponse(w, http.StatusOK, map[string]any{
+			"success":  true,
+			"likes":    likes,
+			"dislikes": dislikes,
+		})
+	}
 }
 
 // DislikeCommentHandler handles disliking a comment
-func DislikeCommentHandler(w http.ResponseWriter, r *http.Request) {
-	userID := r.Context().Value(middleware.UserIDKey).(int)
-	commentIDStr := r.URL.Query().Get("commentId")
+func DislikeCommentHandler(reactions database.ReactionStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			slog.WarnContext(r.Context(), "Invalid request method", "method", r.Method)
+			handleError(w, fmt.Errorf("method not allowed"), http.StatusMethodNotAllowed)
+			return
+		}
 
-	commentID, err := strconv.Atoi(commentIDStr)
-	if err != nil {
-		errLog.Error.Println(err.Error())
-		handleError(w, fmt.Errorf("invalid Comment ID: %v", err), http.StatusBadRequest)
-		return
-	}
+		userID := r.Context().Value(middleware.UserIDKey).(int)
+		commentIDStr := r.URL.Query().Get("commentId")
 
-	err = database.ToggleCommentReaction(userID, commentID, "dislike")
-	if err != nil {
-		errLog.Error.Println(err.Error())
-		handleError(w, fmt.Errorf("failed to dislike comment: %v", err), http.StatusInternalServerError)
-		return
-	}
+		commentID, err := strconv.Atoi(commentIDStr)
+		if err != nil {
+			slog.WarnContext(r.Context(), "Invalid comment ID", "comment_id", commentIDStr)
+			handleError(w, fmt.Errorf("invalid Comment ID: %v", err), http.StatusBadRequest)
+			return
+		}
 
-	// Return updated reaction counts
-	likes, dislikes, _ := database.GetCommentReactionCounts(commentID)
+		err = reactions.ToggleCommentReaction(userID, commentID, "dislike")
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to dislike comment", "comment_id", commentID, "err", err)
+			handleError(w, fmt.Errorf("failed to dislike comment: %v", err), http.StatusInternalServerError)
+			return
+		}
 
-	sendSuccessResponse(w, http.StatusOK, map[string]any{
-		"success":  true,
-		"likes":    likes,
-		"dislikes": dislikes,
-	})
+		// Return updated reaction counts
+		likes, dislikes, _ := reactions.GetCommentReactionCounts(commentID)
+
+		sendSuccessResponse(w, http.StatusOK, map[string]any{
+			"success":  true,
+			"likes":    likes,
+			"dislikes": dislikes,
+		})
+	}
 }
@@ -1,68 +1,265 @@
 package handlers
 
 import (
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/nyagooh/Real-time-forum.git/backend/config"
 	"github.com/nyagooh/Real-time-forum.git/backend/database"
-	"github.com/nyagooh/Real-time-forum.git/backend/errLog"
 	"github.com/nyagooh/Real-time-forum.git/backend/middleware"
 	"github.com/nyagooh/Real-time-forum.git/backend/models"
 )
 
-func AddCommentHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		errLog.Error.Println("invalid request method")
-		handleError(w, fmt.Errorf("invalid request method"), http.StatusMethodNotAllowed)
-		return
-	}
+// AddCommentHandler adds a comment to the post given by the "id" query
+// parameter, or a reply to the comment given by parentId, up to the
+// configured depth.
+func AddCommentHandler(posts database.PostStore, content config.Content) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			slog.WarnContext(r.Context(), "Invalid request method", "method", r.Method)
+			handleError(w, fmt.Errorf("invalid request method"), http.StatusMethodNotAllowed)
+			return
+		}
 
-	var commentRequest struct {
-		Text string `json:"text"`
-	}
+		var commentRequest struct {
+			Text     string `json:"text"`
+			ParentID int    `json:"parentId"`
+		}
+
+		err := json.NewDecoder(r.Body).Decode(&commentRequest)
+		if err != nil {
+			slog.WarnContext(r.Context(), "Invalid comment request", "err", err)
+			handleError(w, fmt.Errorf("invalid request body: %v", err), http.StatusBadRequest)
+			return
+		}
+
+		if commentRequest.Text == "" {
+			slog.WarnContext(r.Context(), "Empty comment rejected")
+			handleError(w, fmt.Errorf("comment content cannot be empty"), http.StatusBadRequest)
+			return
+		}
+
+		cleanedText := SanitizeInput(commentRequest.Text)
+
+		userID := r.Context().Value(middleware.UserIDKey).(int)
+		postIDStr := r.URL.Query().Get("id")
 
-	err := json.NewDecoder(r.Body).Decode(&commentRequest)
-	if err != nil {
-		errLog.Error.Println(err.Error())
-		handleError(w, fmt.Errorf("invalid request body: %v", err), http.StatusBadRequest)
-		return
+		postID, err := strconv.Atoi(postIDStr)
+		if err != nil {
+			slog.WarnContext(r.Context(), "Invalid post ID", "post_id", postIDStr)
+			handleError(w, fmt.Errorf("invalid Post ID: %v", err), http.StatusBadRequest)
+			return
+		}
+
+		if parentID := commentRequest.ParentID; parentID != 0 {
+			parent, err := posts.GetComment(parentID)
+			if errors.Is(err, sql.ErrNoRows) || (err == nil && parent.PostID != postID) {
+				slog.WarnContext(r.Context(), "Reply to unknown comment", "post_id", postID, "parent_id", parentID)
+				handleError(w, fmt.Errorf("the comment you are replying to was not found"), http.StatusNotFound)
+				return
+			}
+			if err != nil {
+				slog.ErrorContext(r.Context(), "Failed to load parent comment", "parent_id", parentID, "err", err)
+				handleError(w, fmt.Errorf("server error"), http.StatusInternalServerError)
+				return
+			}
+			if parent.Depth >= content.CommentMaxDepth {
+				slog.WarnContext(r.Context(), "Reply nested too deep", "parent_id", parentID, "depth", parent.Depth)
+				handleError(w, fmt.Errorf("replies can only nest %d levels deep", content.CommentMaxDepth), http.StatusBadRequest)
+				return
+			}
+		}
+
+		post := &models.Post{
+			Content:   cleanedText,
+			CreatedAt: time.Now().Format(time.RFC3339),
+		}
+
+		err = posts.AddComment(postID, userID, commentRequest.ParentID, post)
+		if errors.Is(err, sql.ErrNoRows) {
+			slog.WarnContext(r.Context(), "Comment on unknown post", "post_id", postID, "parent_id", commentRequest.ParentID)
+			handleError(w, fmt.Errorf("post not found"), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to add comment", "post_id", postID, "user_id", userID, "err", err)
+			handleError(w, fmt.Errorf("failed to add comment: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		sendSuccessResponse(w, http.StatusOK, map[string]any{
+			"success": true,
+			"comment": post.Comments[0],
+		})
 	}
+}
 
-	if commentRequest.Text == "" {
-		errLog.Error.Println("comment content cannot be empty")
-		handleError(w, fmt.Errorf("comment content cannot be empty"), http.StatusBadRequest)
-		return
+// EditCommentHandler lets the author of a comment change its text within
+// the configured edit window. The comment is marked edited.
+func EditCommentHandler(posts database.PostStore, content config.Content) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			handleError(w, fmt.Errorf("method not allowed"), http.StatusMethodNotAllowed)
+			return
+		}
+
+		var req struct {
+			ID   int    `json:"id"`
+			Text string `json:"text"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ID <= 0 {
+			slog.WarnContext(r.Context(), "Invalid comment edit request", "err", err)
+			handleError(w, fmt.Errorf("a comment id is required"), http.StatusBadRequest)
+			return
+		}
+		if strings.TrimSpace(req.Text) == "" {
+			handleError(w, fmt.Errorf("comment content cannot be empty"), http.StatusBadRequest)
+			return
+		}
+
+		userID := r.Context().Value(middleware.UserIDKey).(int)
+		comment, err := posts.GetComment(req.ID)
+		if errors.Is(err, sql.ErrNoRows) {
+			slog.WarnContext(r.Context(), "Edit of unknown comment", "id", req.ID)
+			handleError(w, fmt.Errorf("comment not found"), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to load comment", "id", req.ID, "err", err)
+			handleError(w, fmt.Errorf("server error"), http.StatusInternalServerError)
+			return
+		}
+		if comment.UserID != userID {
+			slog.WarnContext(r.Context(), "Edit of someone else's comment", "user_id", userID, "id", req.ID)
+			handleError(w, fmt.Errorf("you can only edit your own comments"), http.StatusForbidden)
+			return
+		}
+		if window := content.CommentEditWindow; window > 0 {
+			createdAt, err := time.Parse(time.RFC3339, comment.CreatedAt)
+			if err != nil || time.Since(createdAt) > window {
+				slog.WarnContext(r.Context(), "Comment edit window passed", "id", req.ID, "created_at", comment.CreatedAt)
+				handleError(w, fmt.Errorf("comments can only be edited for %s after posting", window), http.StatusForbidden)
+				return
+			}
+		}
+
+		comment.Content = SanitizeInput(req.Text)
+		err = posts.UpdateComment(comment)
+		if errors.Is(err, sql.ErrNoRows) {
+			handleError(w, fmt.Errorf("comment not found"), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to edit comment", "id", req.ID, "err", err)
+			handleError(w, fmt.Errorf("server error"), http.StatusInternalServerError)
+			return
+		}
+
+		slog.InfoContext(r.Context(), "Edited comment", "id", req.ID, "user_id", userID)
+		sendSuccessResponse(w, http.StatusOK, map[string]any{
+			"success": true,
+			"comment": comment,
+		})
 	}
+}
 
-	cleanedText := SanitizeInput(commentRequest.Text)
+// ListCommentsHandler returns the comments on the post given by "id": the
+// whole thread, or with "parent" only the replies under that comment, which
+// is how clients expand a collapsed branch. "depth" limits how many levels
+// come back; comments whose replies were left out still count them in
+// replies. The thread is a tree of children, or with format=flat a list in
+// reading order whose depth and path say where each comment goes.
+func ListCommentsHandler(posts database.PostStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			handleError(w, fmt.Errorf("method not allowed"), http.StatusMethodNotAllowed)
+			return
+		}
 
-	userID := r.Context().Value(middleware.UserIDKey).(int)
-	postIDStr := r.URL.Query().Get("id")
+		query := r.URL.Query()
+		postID, err := strconv.Atoi(query.Get("id"))
+		if err != nil || postID <= 0 {
+			slog.WarnContext(r.Context(), "Invalid post ID", "post_id", query.Get("id"))
+			handleError(w, fmt.Errorf("a post id is required"), http.StatusBadRequest)
+			return
+		}
 
-	postID, err := strconv.Atoi(postIDStr)
-	if err != nil {
-		errLog.Error.Println(err.Error())
-		handleError(w, fmt.Errorf("invalid Post ID: %v", err), http.StatusBadRequest)
-		return
+		var parentID, levels int
+		if v := query.Get("parent"); v != "" {
+			if parentID, err = strconv.Atoi(v); err != nil || parentID <= 0 {
+				handleError(w, fmt.Errorf("parent must be a comment id"), http.StatusBadRequest)
+				return
+			}
+		}
+		if v := query.Get("depth"); v != "" {
+			if levels, err = strconv.Atoi(v); err != nil || levels <= 0 {
+				handleError(w, fmt.Errorf("depth must be a positive number"), http.StatusBadRequest)
+				return
+			}
+		}
+		format := query.Get("format")
+		if format != "" && format != "tree" && format != "flat" {
+			handleError(w, fmt.Errorf("format must be tree or flat"), http.StatusBadRequest)
+			return
+		}
+
+		comments, err := posts.ListComments(postID, parentID, levels)
+		if errors.Is(err, sql.ErrNoRows) {
+			slog.WarnContext(r.Context(), "Comments of unknown post or parent", "post_id", postID, "parent_id", parentID)
+			handleError(w, fmt.Errorf("post or comment not found"), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to list comments", "post_id", postID, "parent_id", parentID, "err", err)
+			handleError(w, fmt.Errorf("server error"), http.StatusInternalServerError)
+			return
+		}
+
+		if format != "flat" {
+			comments = commentTree(comments)
+		}
+		if comments == nil {
+			comments = []models.Comment{}
+		}
+
+		sendSuccessResponse(w, http.StatusOK, map[string]any{
+			"success":  true,
+			"comments": comments,
+		})
 	}
+}
 
-	post := &models.Post{
-		Content:   cleanedText,
-		CreatedAt: time.Now().Format(time.RFC3339),
+// commentTree nests a thread-ordered list of comments under their parents.
+// Comments whose parent is not in the list are the roots.
+func commentTree(flat []models.Comment) []models.Comment {
+	listed := make(map[int]bool, len(flat))
+	children := make(map[int][]models.Comment)
+	for _, c := range flat {
+		listed[c.ID] = true
 	}
 
-	err = database.AddComment(postID, userID, post)
-	if err != nil {
-		errLog.Error.Println(err.Error())
-		handleError(w, fmt.Errorf("failed to add comment: %v", err), http.StatusInternalServerError)
-		return
+	var roots []models.Comment
+	for _, c := range flat {
+		if listed[c.ParentID] {
+			children[c.ParentID] = append(children[c.ParentID], c)
+		} else {
+			roots = append(roots, c)
+		}
 	}
 
-	sendSuccessResponse(w, http.StatusOK, map[string]any{
-		"success": true,
-		"comment": post.Comments[0],
-	})
+	var attach func(list []models.Comment) []models.Comment
+	attach = func(list []models.Comment) []models.Comment {
+		for i := range list {
+			list[i].Children = attach(children[list[i].ID])
+		}
+		return list
+	}
+	return attach(roots)
 }
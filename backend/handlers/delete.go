@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/nyagooh/Real-time-forum.git/backend/database"
+	"github.com/nyagooh/Real-time-forum.git/backend/middleware"
+	"github.com/nyagooh/Real-time-forum.git/backend/models"
+)
+
+// authorOrModerator reports whether the signed-in user wrote the content,
+// being authorID, or may moderate other users' content.
+func authorOrModerator(r *http.Request, roles database.RoleStore, authorID int) (bool, error) {
+	if r.Context().Value(middleware.UserIDKey).(int) == authorID {
+		return true, nil
+	}
+	return middleware.HasPermission(r, roles, models.PermModerateContent)
+}
+
+// maxDeleteReasonLength caps the reason given for a delete, which only
+// goes to the log.
+const maxDeleteReasonLength = 500
+
+// contentRequest is the body of a delete or restore. Reason is optional and
+// only read for deletes.
+type contentRequest struct {
+	ID     int    `json:"id"`
+	Reason string `json:"reason"`
+}
+
+// decodeContentRequest reads the {"id": ..., "reason": ...} body of a
+// delete or restore.
+func decodeContentRequest(w http.ResponseWriter, r *http.Request, what string) (contentRequest, bool) {
+	var req contentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ID <= 0 {
+		slog.WarnContext(r.Context(), "Invalid "+what+" id", "err", err)
+		handleError(w, fmt.Errorf("a %s id is required", what), http.StatusBadRequest)
+		return req, false
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	if len(req.Reason) > maxDeleteReasonLength {
+		handleError(w, fmt.Errorf("reason must be at most %d characters", maxDeleteReasonLength), http.StatusBadRequest)
+		return req, false
+	}
+	return req, true
+}
+
+// deleteHandler turns a post or comment into a tombstone. author returns who
+// wrote it, or sql.ErrNoRows if it does not exist or is already deleted.
+// The reason given, meant for moderators removing other users' content, is
+// logged with the delete.
+func deleteHandler(what string, roles database.RoleStore, author func(id int) (int, error), remove func(id, userID int) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete {
+			handleError(w, fmt.Errorf("method not allowed"), http.StatusMethodNotAllowed)
+			return
+		}
+
+		userID := r.Context().Value(middleware.UserIDKey).(int)
+		req, ok := decodeContentRequest(w, r, what)
+		if !ok {
+			return
+		}
+		id := req.ID
+
+		authorID, err := author(id)
+		if err == nil {
+			var allowed bool
+			allowed, err = authorOrModerator(r, roles, authorID)
+			if err == nil && !allowed {
+				slog.WarnContext(r.Context(), "Delete of someone else's "+what, "user_id", userID, "id", id)
+				handleError(w, fmt.Errorf("you can only delete your own %ss", what), http.StatusForbidden)
+				return
+			}
+		}
+		if err == nil {
+			err = remove(id, userID)
+		}
+		if errors.Is(err, sql.ErrNoRows) {
+			slog.WarnContext(r.Context(), "Delete of unknown "+what, "id", id)
+			handleError(w, fmt.Errorf("%s not found", what), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to delete "+what, "id", id, "err", err)
+			handleError(w, fmt.Errorf("server error"), http.StatusInternalServerError)
+			return
+		}
+
+		slog.InfoContext(r.Context(), "Deleted "+what, "id", id, "user_id", userID, "author_id", authorID,
+			"by_moderator", authorID != userID, "reason", req.Reason)
+		sendSuccessResponse(w, http.StatusOK, map[string]bool{"success": true})
+	}
+}
+
+// restoreHandler brings back a deleted post or comment. It goes behind
+// RequirePermission.
+func restoreHandler(what string, restore func(id int) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			handleError(w, fmt.Errorf("method not allowed"), http.StatusMethodNotAllowed)
+			return
+		}
+
+		userID := r.Context().Value(middleware.UserIDKey).(int)
+		req, ok := decodeContentRequest(w, r, what)
+		if !ok {
+			return
+		}
+		id := req.ID
+
+		err := restore(id)
+		if errors.Is(err, sql.ErrNoRows) {
+			slog.WarnContext(r.Context(), "Restore of unknown "+what, "id", id)
+			handleError(w, fmt.Errorf("no deleted %s with that id, it may have been purged", what), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to restore "+what, "id", id, "err", err)
+			handleError(w, fmt.Errorf("server error"), http.StatusInternalServerError)
+			return
+		}
+
+		slog.InfoContext(r.Context(), "Restored "+what, "id", id, "moderator_id", userID)
+		sendSuccessResponse(w, http.StatusOK, map[string]bool{"success": true})
+	}
+}
+
+// DeletePostHandler lets the author or a moderator delete a post. It stays
+// as a tombstone until purged.
+func DeletePostHandler(posts database.PostStore, roles database.RoleStore) http.HandlerFunc {
+	author := func(id int) (int, error) {
+		_, authorID, err := posts.GetPost(id)
+		return authorID, err
+	}
+	return deleteHandler("post", roles, author, posts.DeletePost)
+}
+
+// RestorePostHandler brings back a deleted post.
+func RestorePostHandler(posts database.PostStore) http.HandlerFunc {
+	return restoreHandler("post", posts.RestorePost)
+}
+
+// DeleteCommentHandler lets the author or a moderator delete a comment. It
+// stays as a tombstone until purged; its reactions go right away.
+func DeleteCommentHandler(posts database.PostStore, roles database.RoleStore) http.HandlerFunc {
+	author := func(id int) (int, error) {
+		comment, err := posts.GetComment(id)
+		if err != nil {
+			return 0, err
+		}
+		return comment.UserID, nil
+	}
+	return deleteHandler("comment", roles, author, posts.DeleteComment)
+}
+
+// RestoreCommentHandler brings back a deleted comment.
+func RestoreCommentHandler(posts database.PostStore) http.HandlerFunc {
+	return restoreHandler("comment", posts.RestoreComment)
+}
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// How long a single readiness check may take before it counts as failed.
+const readinessTimeout = 2 * time.Second
+
+var startedAt = time.Now()
+
+// Check is one named readiness probe; a nil error means healthy.
+type Check struct {
+	Name string
+	Run  func(ctx context.Context) error
+}
+
+type checkResult struct {
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+	Duration string `json:"duration"`
+}
+
+// HealthHandler reports that the process is alive and serving requests.
+func HealthHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sendSuccessResponse(w, http.StatusOK, map[string]any{
+			"status": "ok",
+			"uptime": time.Since(startedAt).Round(time.Second).String(),
+		})
+	}
+}
+
+// ReadyHandler runs every check concurrently and answers 200 when all pass,
+// or 503 with the failing checks so a load balancer stops sending traffic.
+func ReadyHandler(checks ...Check) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+		defer cancel()
+
+		results := make(map[string]checkResult, len(checks))
+		var mu sync.Mutex
+		var wg sync.WaitGroup
+
+		for _, check := range checks {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				start := time.Now()
+				err := check.Run(ctx)
+				result := checkResult{Status: "ok", Duration: time.Since(start).String()}
+				if err != nil {
+					result.Status = "fail"
+					result.Error = err.Error()
+				}
+
+				mu.Lock()
+				results[check.Name] = result
+				mu.Unlock()
+			}()
+		}
+		wg.Wait()
+
+		status, code := "ok", http.StatusOK
+		for _, result := range results {
+			if result.Status != "ok" {
+				status, code = "fail", http.StatusServiceUnavailable
+			}
+		}
+
+		sendSuccessResponse(w, code, map[string]any{
+			"status": status,
+			"checks": results,
+		})
+	}
+}
+
+// CheckUploadsWritable fails unless a file can be created in UploadsDir.
+func CheckUploadsWritable(context.Context) error {
+	if err := os.MkdirAll(UploadsDir, 0755); err != nil {
+		return err
+	}
+	file, err := os.CreateTemp(UploadsDir, ".readyz-*")
+	if err != nil {
+		return err
+	}
+	file.Close()
+	return os.Remove(file.Name())
+}
@@ -2,66 +2,82 @@ package handlers
 
 import (
 	"fmt"
+	"log/slog"
 	"net/http"
 	"strconv"
 
 	"github.com/nyagooh/Real-time-forum.git/backend/database"
-	"github.com/nyagooh/Real-time-forum.git/backend/errLog"
 	"github.com/nyagooh/Real-time-forum.git/backend/middleware"
 )
 
 // LikePostHandler handles liking a post.
-func LikePostHandler(w http.ResponseWriter, r *http.Request) {
-	userID := r.Context().Value(middleware.UserIDKey).(int)
-	postIDStr := r.URL.Query().Get("id")
+func LikePostHandler(reactions database.ReactionStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			slog.WarnContext(r.Context(), "Invalid request method", "method", r.Method)
+			handleError(w, fmt.Errorf("method not allowed"), http.StatusMethodNotAllowed)
+			return
+		}
 
-	postID, err := strconv.Atoi(postIDStr)
-	if err != nil {
-		errLog.Error.Println(err.Error())
-		handleError(w, fmt.Errorf("invalid Post ID: %v", err), http.StatusBadRequest)
-		return
-	}
+		userID := r.Context().Value(middleware.UserIDKey).(int)
+		postIDStr := r.URL.Query().Get("id")
 
-	err = database.ToggleReaction(userID, postID, "like")
-	if err != nil {
-		errLog.Error.Println(err.Error())
-		handleError(w, fmt.Errorf("failed to like post: %v", err), http.StatusInternalServerError)
-		return
-	}
+		postID, err := strconv.Atoi(postIDStr)
+		if err != nil {
+			slog.WarnContext(r.Context(), "Invalid post ID", "post_id", postIDStr)
+			handleError(w, fmt.Errorf("invalid Post ID: %v", err), http.StatusBadRequest)
+			return
+		}
 
-	likes, dislikes, _ := database.GetReactionCounts(postID)
+		err = reactions.ToggleReaction(userID, postID, "like")
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to like post", "post_id", postID, "err", err)
+			handleError(w, fmt.Errorf("failed to like post: %v", err), http.StatusInternalServerError)
+			return
+		}
 
-	sendSuccessResponse(w, http.StatusOK, map[string]any{
-		"success":  true,
-		"likes":    likes,
-		"dislikes": dislikes,
-	})
+		likes, dislikes, _ := reactions.GetReactionCounts(postID)
+
+		sendSuccessResponse(w, http.StatusOK, map[string]any{
+			"success":  true,
+			"likes":    likes,
+			"dislikes": dislikes,
+		})
+	}
 }
 
 // DislikePostHandler handles disliking a post.
-func DislikePostHandler(w http.ResponseWriter, r *http.Request) {
-	userID := r.Context().Value(middleware.UserIDKey).(int)
-	postIDStr := r.URL.Query().Get("id")
+func DislikePostHandler(reactions database.ReactionStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			slog.WarnContext(r.Context(), "Invalid request method", "method", r.Method)
+			handleError(w, fmt.Errorf("method not allowed"), http.StatusMethodNotAllowed)
+			return
+		}
 
-	postID, err := strconv.Atoi(postIDStr)
-	if err != nil {
-		errLog.Error.Println(err.Error())
-		handleError(w, fmt.Errorf("invalid Post ID: %v", err), http.StatusBadRequest)
-		return
-	}
+		userID := r.Context().Value(middleware.UserIDKey).(int)
+		postIDStr := r.URL.Query().Get("id")
 
-	err = database.ToggleReaction(userID, postID, "dislike")
-	if err != nil {
-		errLog.Error.Println(err.Error())
-		handleError(w, fmt.Errorf("failed to dislike post: %v", err), http.StatusInternalServerError)
-		return
-	}
+		postID, err := strconv.Atoi(postIDStr)
+		if err != nil {
+			slog.WarnContext(r.Context(), "Invalid post ID", "post_id", postIDStr)
+			handleError(w, fmt.Errorf("invalid Post ID: %v", err), http.StatusBadRequest)
+			return
+		}
 
-	likes, dislikes, _ := database.GetReactionCounts(postID)
+		err = reactions.ToggleReaction(userID, postID, "dislike")
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to dislike post", "post_id", postID, "err", err)
+			handleError(w, fmt.Errorf("failed to dislike post: %v", err), http.StatusInternalServerError)
+			return
+		}
 
-	sendSuccessResponse(w, http.StatusOK, map[string]any{
-		"success":  true,
-		"likes":    likes,
-		"dislikes": dislikes,
-	})
+		likes, dislikes, _ := reactions.GetReactionCounts(postID)
+
+		sendSuccessResponse(w, http.StatusOK, map[string]any{
+			"success":  true,
+			"likes":    likes,
+			"dislikes": dislikes,
+		})
+	}
 }
@@ -0,0 +1,217 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nyagooh/Real-time-forum.git/backend/config"
+	"github.com/nyagooh/Real-time-forum.git/backend/database"
+	"github.com/nyagooh/Real-time-forum.git/backend/mail"
+	"github.com/nyagooh/Real-time-forum.git/backend/middleware"
+	"github.com/nyagooh/Real-time-forum.git/backend/utils"
+)
+
+// loginHistoryLimit is how many sign-ins LoginHistoryHandler shows.
+const loginHistoryLimit = 20
+
+// errLoginThrottled is answered with 429 and a Retry-After header.
+type errLoginThrottled struct {
+	wait   time.Duration
+	locked bool
+}
+
+func (e errLoginThrottled) Error() string {
+	if e.locked {
+		return "account temporarily locked after too many failed logins, check your email to unlock it"
+	}
+	return fmt.Sprintf("too many failed logins, try again in %d seconds", retryAfterSeconds(e.wait))
+}
+
+func retryAfterSeconds(wait time.Duration) int {
+	return int(math.Ceil(wait.Seconds()))
+}
+
+// ipThrottle returns errLoginThrottled while the client IP must wait after
+// its recent failed logins.
+func ipThrottle(r *http.Request, attempts database.LoginAttemptStore, cfg config.LoginThrottle) error {
+	now := time.Now()
+	failures, err := attempts.IPFailures(middleware.ClientIP(r), now.Add(-cfg.IPWindow))
+	if err != nil {
+		return err
+	}
+
+	if wait := failures.Last.Add(cfg.Delay(failures.Count)).Sub(now); wait > 0 {
+		return errLoginThrottled{wait: wait}
+	}
+	return nil
+}
+
+// accountThrottle returns errLoginThrottled while the account is locked or
+// must wait after its run of failed logins.
+func accountThrottle(attempts database.LoginAttemptStore, cfg config.LoginThrottle, userID int) error {
+	now := time.Now()
+	failures, err := attempts.AccountFailures(userID)
+	if err != nil {
+		return err
+	}
+
+	if wait := failures.LockedUntil.Sub(now); wait > 0 {
+		return errLoginThrottled{wait: wait, locked: true}
+	}
+	if wait := failures.Last.Add(cfg.Delay(failures.Count)).Sub(now); wait > 0 {
+		return errLoginThrottled{wait: wait}
+	}
+	return nil
+}
+
+// handleThrottleError answers a failed throttle check.
+func handleThrottleError(w http.ResponseWriter, r *http.Request, err error) {
+	var throttled errLoginThrottled
+	if errors.As(err, &throttled) {
+		slog.WarnContext(r.Context(), "Login throttled", "ip", middleware.ClientIP(r), "locked", throttled.locked, "wait", throttled.wait)
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfterSeconds(throttled.wait)))
+		handleError(w, throttled, http.StatusTooManyRequests)
+		return
+	}
+
+	slog.ErrorContext(r.Context(), "Failed to check login throttle", "err", err)
+	handleError(w, fmt.Errorf("server error"), http.StatusInternalServerError)
+}
+
+// recordLoginSuccess logs a completed sign-in, which ends the account's run
+// of failures. It is only called once a session has been issued, so a
+// password without its second factor does not count.
+func recordLoginSuccess(r *http.Request, attempts database.LoginAttemptStore, userID int) {
+	if _, err := attempts.RecordLoginAttempt(userID, middleware.ClientIP(r), userAgent(r), true); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to record login attempt", "user_id", userID, "err", err)
+	}
+}
+
+// recordLoginFailure logs a failed login, userID being 0 for an unknown
+// identity, and locks the account each time its run of failures reaches
+// another multiple of the limit.
+func recordLoginFailure(r *http.Request, users database.UserStore, attempts database.LoginAttemptStore, mailer mail.Mailer, cfg config.LoginThrottle, publicURL string, userID int) {
+	failures, err := attempts.RecordLoginAttempt(userID, middleware.ClientIP(r), userAgent(r), false)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to record login attempt", "user_id", userID, "err", err)
+		return
+	}
+	if userID == 0 || failures%cfg.LockAfter != 0 {
+		return
+	}
+
+	if err := lockAccount(r, users, attempts, mailer, cfg, publicURL, userID); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to lock account", "user_id", userID, "err", err)
+	}
+}
+
+func lockAccount(r *http.Request, users database.UserStore, attempts database.LoginAttemptStore, mailer mail.Mailer, cfg config.LoginThrottle, publicURL string, userID int) error {
+	token, hash, err := utils.GenerateOneTimeToken()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if err := attempts.LockAccount(userID, now.Add(cfg.LockDuration), hash, now.Add(cfg.UnlockTokenLifetime)); err != nil {
+		return err
+	}
+	slog.WarnContext(r.Context(), "Account locked after failed logins", "user_id", userID, "failures", cfg.LockAfter)
+
+	email, err := users.GetUserEmail(userID)
+	if err != nil {
+		return err
+	}
+
+	link := strings.TrimSuffix(publicURL, "/") + "/unlock-account?token=" + url.QueryEscape(token)
+	msg := mail.Message{
+		To:      email,
+		Subject: "Your forum account was locked",
+		Body: fmt.Sprintf("Someone failed to sign in to your forum account %d times in a row, ", cfg.LockAfter) +
+			fmt.Sprintf("so it is locked for %s.\n\n", formatLifetime(cfg.LockDuration)) +
+			"If that was you, open this link to unlock it now:\n\n" + link + "\n\n" +
+			"If it was not you, consider choosing a new password once you are back in.\n",
+	}
+	return mailer.Send(r.Context(), msg)
+}
+
+// userAgent returns the request's user agent, cut to a length worth keeping.
+func userAgent(r *http.Request) string {
+	ua := r.UserAgent()
+	if len(ua) > maxUserAgentLength {
+		ua = ua[:maxUserAgentLength]
+	}
+	return ua
+}
+
+// UnlockAccountHandler lifts a lock with the token from the unlock email.
+func UnlockAccountHandler(attempts database.LoginAttemptStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+
+		case http.MethodGet:
+			http.ServeFile(w, r, filepath.Join("frontend", "index.html"))
+
+		case http.MethodPost:
+			var req struct {
+				Token string `json:"token"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Token == "" {
+				slog.WarnContext(r.Context(), "Invalid unlock request", "err", err)
+				handleError(w, fmt.Errorf("token is required"), http.StatusBadRequest)
+				return
+			}
+
+			userID, err := attempts.UnlockAccount(utils.HashToken(req.Token))
+			if errors.Is(err, database.ErrInvalidToken) {
+				slog.WarnContext(r.Context(), "Unlock with unusable token")
+				handleError(w, fmt.Errorf("invalid or expired unlock link"), http.StatusBadRequest)
+				return
+			}
+			if err != nil {
+				slog.ErrorContext(r.Context(), "Failed to unlock account", "err", err)
+				handleError(w, fmt.Errorf("server error"), http.StatusInternalServerError)
+				return
+			}
+
+			slog.InfoContext(r.Context(), "Account unlocked", "user_id", userID)
+			sendSuccessResponse(w, http.StatusOK, map[string]bool{"success": true})
+
+		default:
+			handleError(w, fmt.Errorf("method not allowed"), http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// LoginHistoryHandler shows the user their recent sign-ins, successful or
+// not.
+func LoginHistoryHandler(attempts database.LoginAttemptStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			handleError(w, fmt.Errorf("method not allowed"), http.StatusMethodNotAllowed)
+			return
+		}
+
+		userID := r.Context().Value(middleware.UserIDKey).(int)
+
+		list, err := attempts.ListLoginAttempts(userID, loginHistoryLimit)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to list login attempts", "user_id", userID, "err", err)
+			handleError(w, fmt.Errorf("server error"), http.StatusInternalServerError)
+			return
+		}
+
+		sendSuccessResponse(w, http.StatusOK, map[string]any{
+			"success":  true,
+			"attempts": list,
+		})
+	}
+}
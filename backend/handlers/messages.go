@@ -1,67 +1,66 @@
 package handlers
 
 import (
-	"database/sql"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"strconv"
 
 	"github.com/nyagooh/Real-time-forum.git/backend/database"
-	"github.com/nyagooh/Real-time-forum.git/backend/errLog"
 	"github.com/nyagooh/Real-time-forum.git/backend/middleware"
 	ws "github.com/nyagooh/Real-time-forum.git/backend/websockets"
 )
 
 // ServeWs handles websocket requests from clients
-func ServeWs(db *sql.DB) http.HandlerFunc {
+func ServeWs(users database.UserStore, hub *ws.Hub) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		userIDval := r.Context().Value(middleware.UserIDKey)
 		if userIDval == nil {
-			errLog.Error.Println("Invalid userID value")
+			slog.WarnContext(r.Context(), "Missing user ID in request context")
 			handleError(w, fmt.Errorf("unauthorized"), http.StatusUnauthorized)
 			return
 		}
 
 		userID, ok := userIDval.(int)
 		if !ok {
-			errLog.Error.Println("error")
+			slog.ErrorContext(r.Context(), "Unexpected user ID type in request context")
 			handleError(w, fmt.Errorf("internal server error"), http.StatusInternalServerError)
 			return
 		}
 
-		query := `
-		SELECT nickname
-		FROM users
-		WHERE id = ?`
-
-		var username string
-		err := db.QueryRow(query, userID).Scan(&username)
+		username, err := users.GetUserByID(userID)
 		if err != nil {
-			errLog.Error.Println(err.Error())
+			slog.ErrorContext(r.Context(), "Failed to look up websocket user", "user_id", userID, "err", err)
 			handleError(w, fmt.Errorf("error retrieving username: %v", err), http.StatusInternalServerError)
 			return
 		}
 
+		session, ok := middleware.SessionToken(r)
+		if !ok {
+			slog.WarnContext(r.Context(), "Missing session token in request context")
+			handleError(w, fmt.Errorf("unauthorized"), http.StatusUnauthorized)
+			return
+		}
+
 		// Upgrade connection to websocket
 		conn, err := ws.Upgrader.Upgrade(w, r, nil)
 		if err != nil {
-			errLog.Info.Println("Upgradingconnection")
+			slog.ErrorContext(r.Context(), "Websocket upgrade failed", "user_id", userID, "err", err)
 			handleError(w, fmt.Errorf("error upgrading connection: %v", err), http.StatusInternalServerError)
 			return
 		}
 
 		// Create a new client
-		client := &ws.Client{
-			ID:       strconv.Itoa(userID),
-			Conn:     conn,
-			Send:     make(chan []byte, 256),
-			UserID:   strconv.Itoa(userID),
-			Username: username,
-		}
+		client := hub.NewClient(r.Context(), conn, userID, username, session)
 
 		// Register client with hub
-		ws.GlobalHub.Register <- client
-		// errLog.Info.Println("Client registered")
+		select {
+		case hub.Register <- client:
+		case <-hub.Done():
+			conn.Close()
+			return
+		}
+		slog.DebugContext(r.Context(), "Websocket client registered", "user_id", userID)
 
 		// Start goroutines for reading and writing
 		go client.WritePump()
@@ -69,7 +68,7 @@ func ServeWs(db *sql.DB) http.HandlerFunc {
 	}
 }
 
-func GetMessages(db *sql.DB) http.HandlerFunc {
+func GetMessages(messages database.MessageStore) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		switch r.Method {
 		case http.MethodGet:
@@ -88,14 +87,14 @@ func GetMessages(db *sql.DB) http.HandlerFunc {
 				limitInt = 10
 			}
 
-			messages, err := database.GetMessages(db, sender, receiver, offsetInt, limitInt)
+			history, err := messages.GetMessages(sender, receiver, offsetInt, limitInt)
 			if err != nil {
-				errLog.Error.Println(err.Error())
+				slog.ErrorContext(r.Context(), "Failed to load messages", "sender", sender, "receiver", receiver, "err", err)
 				handleError(w, fmt.Errorf("error retrieving messages: %v", err), http.StatusInternalServerError)
 				return
 			}
 
-			sendSuccessResponse(w, http.StatusOK, messages)
+			sendSuccessResponse(w, http.StatusOK, history)
 		default:
 			handleError(w, fmt.Errorf("method not allowed"), http.StatusMethodNotAllowed)
 		}
@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/nyagooh/Real-time-forum.git/backend/config"
+	"github.com/nyagooh/Real-time-forum.git/backend/database"
+	"github.com/nyagooh/Real-time-forum.git/backend/mail"
+	"github.com/nyagooh/Real-time-forum.git/backend/middleware"
+	"github.com/nyagooh/Real-time-forum.git/backend/utils"
+	ws "github.com/nyagooh/Real-time-forum.git/backend/websockets"
+)
+
+// ForgotPasswordHandler emails a reset link to the account named by a
+// nickname or email. It answers the same way whether or not the account
+// exists, so it cannot be used to probe for users. The lookup and the mail
+// happen after the answer, so its timing gives nothing away either.
+func ForgotPasswordHandler(users database.UserStore, resets database.PasswordResetStore, mailer mail.Mailer, cfg config.Account, publicURL string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+
+		case http.MethodGet:
+			http.ServeFile(w, r, filepath.Join("frontend", "index.html"))
+
+		case http.MethodPost:
+			var req struct {
+				Identity string `json:"identity"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil || strings.TrimSpace(req.Identity) == "" {
+				slog.WarnContext(r.Context(), "Invalid forgot password request", "err", err)
+				handleError(w, fmt.Errorf("identity is required"), http.StatusBadRequest)
+				return
+			}
+
+			// Detached from the request so the reset outlives the response,
+			// while keeping its request ID for the logs.
+			ctx := context.WithoutCancel(r.Context())
+			go func() {
+				if err := sendPasswordReset(ctx, users, resets, mailer, cfg, publicURL, req.Identity); err != nil {
+					slog.ErrorContext(ctx, "Failed to send password reset", "err", err)
+				}
+			}()
+
+			sendSuccessResponse(w, http.StatusOK, map[string]any{
+				"success": true,
+				"message": "if the account exists, a reset link has been sent to its email address",
+			})
+
+		default:
+			handleError(w, fmt.Errorf("method not allowed"), http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+func sendPasswordReset(ctx context.Context, users database.UserStore, resets database.PasswordResetStore, mailer mail.Mailer, cfg config.Account, publicURL, identity string) error {
+	userID, err := users.GetUserID(identity)
+	if err != nil {
+		slog.WarnContext(ctx, "Password reset for unknown account", "identity", identity, "err", err)
+		return nil
+	}
+
+	email, err := users.GetUserEmail(userID)
+	if err != nil {
+		return err
+	}
+
+	token, hash, err := utils.GenerateOneTimeToken()
+	if err != nil {
+		return err
+	}
+
+	if err := resets.CreatePasswordReset(userID, hash, time.Now().Add(cfg.ResetTokenLifetime)); err != nil {
+		return err
+	}
+
+	link := strings.TrimSuffix(publicURL, "/") + "/reset-password?token=" + url.QueryEscape(token)
+	msg := mail.Message{
+		To:      email,
+		Subject: "Reset your forum password",
+		Body: "Someone asked to reset the password of your forum account.\n\n" +
+			"Open this link to choose a new one:\n\n" + link + "\n\n" +
+			fmt.Sprintf("The link works once and expires in %s. ", formatLifetime(cfg.ResetTokenLifetime)) +
+			"If you did not ask for it, you can ignore this email.\n",
+	}
+	if err := mailer.Send(ctx, msg); err != nil {
+		return err
+	}
+
+	slog.InfoContext(ctx, "Password reset sent", "user_id", userID)
+	return nil
+}
+
+// formatLifetime writes whole hours or minutes the way people read them in
+// an email, e.g. "1 hour" rather than "1h0m0s".
+func formatLifetime(d time.Duration) string {
+	unit, n := "", 0
+	switch {
+	case d >= time.Hour && d%time.Hour == 0:
+		unit, n = "hour", int(d/time.Hour)
+	case d >= time.Minute && d%time.Minute == 0:
+		unit, n = "minute", int(d/time.Minute)
+	default:
+		return d.String()
+	}
+	if n != 1 {
+		unit += "s"
+	}
+	return fmt.Sprintf("%d %s", n, unit)
+}
+
+// ResetPasswordHandler sets a new password from a reset token and signs the
+// user out everywhere, closing the websockets of the ended sessions. The
+// password must pass the same strength rules as at registration.
+func ResetPasswordHandler(resets database.PasswordResetStore, hub *ws.Hub, cfg config.Registration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+
+		case http.MethodGet:
+			http.ServeFile(w, r, filepath.Join("frontend", "index.html"))
+
+		case http.MethodPost:
+			var req struct {
+				Token    string `json:"token"`
+				Password string `json:"password"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				slog.WarnContext(r.Context(), "Invalid reset password request", "err", err)
+				handleError(w, fmt.Errorf("failed to decode JSON: %v", err), http.StatusBadRequest)
+				return
+			}
+			if req.Token == "" {
+				slog.WarnContext(r.Context(), "Invalid reset password request", "err", "missing token")
+				handleError(w, fmt.Errorf("token is required"), http.StatusBadRequest)
+				return
+			}
+			if fe := utils.ValidatePassword(req.Password, "", cfg.PasswordMinLength); fe != nil {
+				slog.WarnContext(r.Context(), "Invalid reset password request", "err", fe)
+				sendValidationError(w, utils.ValidationError{*fe}, http.StatusBadRequest)
+				return
+			}
+
+			hashedPassword, err := utils.HashPassword(req.Password)
+			if err != nil {
+				slog.ErrorContext(r.Context(), "Failed to hash password", "err", err)
+				handleError(w, fmt.Errorf("failed to hash password: %v", err), http.StatusInternalServerError)
+				return
+			}
+
+			userID, sessions, err := resets.ResetPassword(utils.HashToken(req.Token), hashedPassword)
+			if errors.Is(err, database.ErrInvalidToken) {
+				slog.WarnContext(r.Context(), "Password reset with unusable token")
+				handleError(w, fmt.Errorf("invalid or expired reset link"), http.StatusBadRequest)
+				return
+			}
+			if err != nil {
+				slog.ErrorContext(r.Context(), "Failed to reset password", "err", err)
+				handleError(w, fmt.Errorf("server error"), http.StatusInternalServerError)
+				return
+			}
+
+			slog.InfoContext(r.Context(), "Password reset", "user_id", userID, "sessions_ended", len(sessions))
+			hub.CloseSessions("password reset", sessions...)
+			middleware.DeleteCookie(w)
+
+			sendSuccessResponse(w, http.StatusOK, map[string]bool{"success": true})
+
+		default:
+			handleError(w, fmt.Errorf("method not allowed"), http.StatusMethodNotAllowed)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/nyagooh/Real-time-forum.git/backend/database"
+	"github.com/nyagooh/Real-time-forum.git/backend/models"
+	"github.com/nyagooh/Real-time-forum.git/backend/utils"
+)
+
+// postDiff is the line-by-line change of each field between two revisions.
+type postDiff struct {
+	Title    []utils.DiffLine `json:"title"`
+	Content  []utils.DiffLine `json:"content"`
+	Category []utils.DiffLine `json:"categories"`
+	ImageURL []utils.DiffLine `json:"imageURL"`
+}
+
+// postRevisions loads the revisions of the post named by the "id" query
+// parameter, answering the request itself when it cannot.
+func postRevisions(w http.ResponseWriter, r *http.Request, posts database.PostStore) ([]models.PostRevision, bool) {
+	if r.Method != http.MethodGet {
+		handleError(w, fmt.Errorf("method not allowed"), http.StatusMethodNotAllowed)
+		return nil, false
+	}
+
+	postID, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil || postID <= 0 {
+		slog.WarnContext(r.Context(), "Revisions request without post id", "id", r.URL.Query().Get("id"))
+		handleError(w, fmt.Errorf("a post id is required"), http.StatusBadRequest)
+		return nil, false
+	}
+
+	revisions, err := posts.ListPostRevisions(postID)
+	if errors.Is(err, sql.ErrNoRows) {
+		slog.WarnContext(r.Context(), "Revisions of unknown post", "post_id", postID)
+		handleError(w, fmt.Errorf("post not found"), http.StatusNotFound)
+		return nil, false
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to list post revisions", "post_id", postID, "err", err)
+		handleError(w, fmt.Errorf("server error"), http.StatusInternalServerError)
+		return nil, false
+	}
+	return revisions, true
+}
+
+// ListPostRevisionsHandler lists every version of a post, oldest first and
+// ending with the current one.
+func ListPostRevisionsHandler(posts database.PostStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		revisions, ok := postRevisions(w, r, posts)
+		if !ok {
+			return
+		}
+
+		sendSuccessResponse(w, http.StatusOK, map[string]any{
+			"success":   true,
+			"revisions": revisions,
+		})
+	}
+}
+
+// DiffPostRevisionsHandler compares revisions "from" and "to" of a post. By
+// default it shows what the latest edit changed.
+func DiffPostRevisionsHandler(posts database.PostStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		revisions, ok := postRevisions(w, r, posts)
+		if !ok {
+			return
+		}
+
+		revision := func(name string, fallback int) (models.PostRevision, bool) {
+			n := fallback
+			if v := r.URL.Query().Get(name); v != "" {
+				var err error
+				if n, err = strconv.Atoi(v); err != nil {
+					return models.PostRevision{}, false
+				}
+			}
+			if n < 1 || n > len(revisions) {
+				return models.PostRevision{}, false
+			}
+			return revisions[n-1], true
+		}
+
+		to, okTo := revision("to", len(revisions))
+		from, okFrom := revision("from", max(to.Revision-1, 1))
+		if !okTo || !okFrom {
+			slog.WarnContext(r.Context(), "Diff of unknown revision", "from", r.URL.Query().Get("from"), "to", r.URL.Query().Get("to"))
+			handleError(w, fmt.Errorf("revision not found, this post has revisions 1 to %d", len(revisions)), http.StatusNotFound)
+			return
+		}
+
+		sendSuccessResponse(w, http.StatusOK, map[string]any{
+			"success": true,
+			"from":    from,
+			"to":      to,
+			"diff": postDiff{
+				Title:    utils.DiffLines(from.Title, to.Title),
+				Content:  utils.DiffLines(from.Content, to.Content),
+				Category: utils.DiffLines(strings.Join(from.Category, "\n"), strings.Join(to.Category, "\n")),
+				ImageURL: utils.DiffLines(from.ImageURL, to.ImageURL),
+			},
+		})
+	}
+}
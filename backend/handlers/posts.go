@@ -1,71 +1,169 @@
 package handlers
 
 import (
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"html"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/nyagooh/Real-time-forum.git/backend/config"
 	"github.com/nyagooh/Real-time-forum.git/backend/database"
-	"github.com/nyagooh/Real-time-forum.git/backend/errLog"
+	"github.com/nyagooh/Real-time-forum.git/backend/metrics"
 	"github.com/nyagooh/Real-time-forum.git/backend/middleware"
 
 	"github.com/nyagooh/Real-time-forum.git/backend/models"
 	"github.com/nyagooh/Real-time-forum.git/backend/utils"
 )
 
-func CreatePostHandler(w http.ResponseWriter, r *http.Request) {
-	switch {
-	case r.Method == http.MethodGet:
-		// Get all posts
-		posts, err := database.GetAllPosts("")
-		if err != nil {
-			errLog.Error.Println(err.Error())
-			handleError(w, fmt.Errorf("error retrieving posts: %v", err), http.StatusInternalServerError)
-			return
-		}
+// UploadsDir is where post images are stored; it is served under /assets/uploads.
+const UploadsDir = "frontend/assets/uploads"
+
+func CreatePostHandler(posts database.PostStore, uploads config.Uploads) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet:
+			// Get all posts
+			start := time.Now()
+			allPosts, err := posts.GetAllPosts("")
+			metrics.GetAllPostsDuration.Observe(metrics.Since(start))
+			if err != nil {
+				slog.ErrorContext(r.Context(), "Failed to load posts", "err", err)
+				handleError(w, fmt.Errorf("error retrieving posts: %v", err), http.StatusInternalServerError)
+				return
+			}
 
-		sendSuccessResponse(w, http.StatusOK, map[string]any{
-			"success": true,
-			"post":    posts,
-		})
+			sendSuccessResponse(w, http.StatusOK, map[string]any{
+				"success": true,
+				"post":    allPosts,
+			})
 
-	case r.Method == http.MethodPost:
-		// Ensure the uploads directory exists
-		if _, err := os.Stat("frontend/assets/uploads"); os.IsNotExist(err) {
-			err := os.Mkdir("frontend/assets/uploads", 0755)
+		case r.Method == http.MethodPost:
+			post, err := parseAndValidatePostRequest(r, uploads.MaxSize)
 			if err != nil {
-				log.Printf("Error creating uploads directory: %v", err)
-				handleError(w, fmt.Errorf("failed to create uploads directory: %v", err), http.StatusInternalServerError)
+				slog.WarnContext(r.Context(), "Invalid post request", "err", err)
+				handleError(w, err, http.StatusInternalServerError)
+				return
+			}
+
+			userIDval := r.Context().Value(middleware.UserIDKey)
+			if userIDval == nil {
+				slog.WarnContext(r.Context(), "Missing user ID in request context")
+				handleError(w, fmt.Errorf("unauthorized"), http.StatusUnauthorized)
 				return
 			}
+
+			userID, ok := userIDval.(int)
+			if !ok {
+				slog.ErrorContext(r.Context(), "Unexpected user ID type in request context")
+				handleError(w, fmt.Errorf("internal server error"), http.StatusInternalServerError)
+				return
+			}
+
+			post.Title = SanitizeInput(post.Title)
+			post.Content = SanitizeInput(post.Content)
+
+			if post.Title == "" {
+				slog.WarnContext(r.Context(), "Post title cannot be empty")
+				handleError(w, fmt.Errorf("title cannot be empty"), http.StatusBadRequest)
+				return
+			}
+			if post.Content == "" {
+				slog.WarnContext(r.Context(), "Post content cannot be empty")
+				handleError(w, fmt.Errorf("content cannot be empty"), http.StatusBadRequest)
+				return
+			}
+
+			imageURL, status, err := savePostImage(r, uploads)
+			if err != nil {
+				handleError(w, err, status)
+				return
+			}
+			post.ImageURL = imageURL
+
+			// Set the creation timestamp
+			post.CreatedAt = time.Now().Format(time.RFC3339)
+
+			postID, err := posts.InsertPost(userID, post)
+			if err != nil {
+				slog.ErrorContext(r.Context(), "Failed to insert post", "user_id", userID, "err", err)
+				handleError(w, fmt.Errorf("failed to insert post: %v", err), http.StatusInternalServerError)
+				return
+			}
+
+			sendSuccessResponse(w, http.StatusOK, map[string]any{
+				"success":  true,
+				"title":    post.Title,
+				"content":  post.Content,
+				"imageURL": post.ImageURL,
+				"id":       postID,
+			})
+
+		default:
+			slog.WarnContext(r.Context(), "Method not allowed", "method", r.Method)
+			handleError(w, fmt.Errorf("method not allowed"), http.StatusMethodNotAllowed)
+			return
+		}
+	}
+}
+
+// EditPostHandler changes a post's title, content, categories and image,
+// keeping the version it replaces as a revision. Only the author or a
+// moderator may edit. Without a new image the current one stays, unless
+// removeImage is "true"; replaced images stay on disk for the revisions.
+func EditPostHandler(posts database.PostStore, roles database.RoleStore, uploads config.Uploads) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			handleError(w, fmt.Errorf("method not allowed"), http.StatusMethodNotAllowed)
+			return
 		}
 
-		post, err := parseAndValidatePostRequest(r)
+		userID := r.Context().Value(middleware.UserIDKey).(int)
+
+		post, err := parseAndValidatePostRequest(r, uploads.MaxSize)
 		if err != nil {
-			errLog.Error.Println(err.Error())
-			handleError(w, err, http.StatusInternalServerError)
+			slog.WarnContext(r.Context(), "Invalid edit post request", "err", err)
+			handleError(w, err, http.StatusBadRequest)
 			return
 		}
 
-		userIDval := r.Context().Value(middleware.UserIDKey)
-		if userIDval == nil {
-			errLog.Error.Println("Invalid userID value")
-			handleError(w, fmt.Errorf("unauthorized"), http.StatusUnauthorized)
+		postID, err := strconv.Atoi(r.FormValue("id"))
+		if err != nil || postID <= 0 {
+			slog.WarnContext(r.Context(), "Edit post request without id", "id", r.FormValue("id"))
+			handleError(w, fmt.Errorf("a post id is required"), http.StatusBadRequest)
 			return
 		}
 
-		userID, ok := userIDval.(int)
+		current, authorID, err := posts.GetPost(postID)
+		if errors.Is(err, sql.ErrNoRows) {
+			slog.WarnContext(r.Context(), "Edit of unknown post", "post_id", postID)
+			handleError(w, fmt.Errorf("post not found"), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to load post", "post_id", postID, "err", err)
+			handleError(w, fmt.Errorf("server error"), http.StatusInternalServerError)
+			return
+		}
+
+		ok, err := authorOrModerator(r, roles, authorID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to check permission", "user_id", userID, "err", err)
+			handleError(w, fmt.Errorf("server error"), http.StatusInternalServerError)
+			return
+		}
 		if !ok {
-			errLog.Error.Println("error")
-			handleError(w, fmt.Errorf("internal server error"), http.StatusInternalServerError)
+			slog.WarnContext(r.Context(), "Edit of someone else's post", "user_id", userID, "post_id", postID)
+			handleError(w, fmt.Errorf("you can only edit your own posts"), http.StatusForbidden)
 			return
 		}
 
@@ -73,143 +171,154 @@ func CreatePostHandler(w http.ResponseWriter, r *http.Request) {
 		post.Content = SanitizeInput(post.Content)
 
 		if post.Title == "" {
-			errLog.Error.Println("Post title cannot be empty")
+			slog.WarnContext(r.Context(), "Post title cannot be empty")
 			handleError(w, fmt.Errorf("title cannot be empty"), http.StatusBadRequest)
 			return
 		}
 		if post.Content == "" {
-			errLog.Error.Println("Post content cannot be empty")
+			slog.WarnContext(r.Context(), "Post content cannot be empty")
 			handleError(w, fmt.Errorf("content cannot be empty"), http.StatusBadRequest)
 			return
 		}
 
-		// Handle image upload
-		file, header, err := r.FormFile("image")
-		var filename string
+		imageURL, status, err := savePostImage(r, uploads)
+		if err != nil {
+			handleError(w, err, status)
+			return
+		}
+		switch {
+		case imageURL != "":
+			post.ImageURL = imageURL
+		case r.FormValue("removeImage") != "true":
+			post.ImageURL = current.ImageURL
+		}
 
-		if err == nil && header != nil {
-			defer file.Close()
+		post.ID, post.Username, post.CreatedAt = current.ID, current.Username, current.CreatedAt
 
-			if header.Size > 10<<20 {
-				log.Printf("File too large: %d bytes", header.Size)
-				handleError(w, fmt.Errorf("image size must be less than 10MB. Your file is %.2f MB", float64(header.Size)/(1<<20)), http.StatusBadRequest)
-				return
-			}
+		// An edit that changes nothing leaves no revision behind.
+		if post.Title == current.Title && post.Content == current.Content &&
+			strings.Join(post.Category, ",") == strings.Join(current.Category, ",") && post.ImageURL == current.ImageURL {
+			sendSuccessResponse(w, http.StatusOK, map[string]any{
+				"success": true,
+				"post":    current,
+			})
+			return
+		}
 
-			// Add debug logging for content type
-			fileType := header.Header.Get("Content-Type")
-
-			// Generate unique filename with original extension
-			ext := filepath.Ext(header.Filename)
-			if ext == "" {
-				// If no extension provided, derive it from content type
-				switch fileType {
-				case "image/jpeg", "image/jpg":
-					ext = ".jpg"
-				case "image/png":
-					ext = ".png"
-				case "image/gif":
-					ext = ".gif"
-				case "image/svg+xml":
-					ext = ".svg"
-				default:
-					log.Printf("Unsupported file type: %s", fileType)
-					handleError(w, fmt.Errorf("unsupported file type. Allowed types: JPEG, PNG, GIF, SVG"), http.StatusBadRequest)
-					return
-				}
-			}
+		err = posts.UpdatePost(postID, userID, post)
+		if errors.Is(err, sql.ErrNoRows) {
+			handleError(w, fmt.Errorf("post not found"), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to update post", "post_id", postID, "err", err)
+			handleError(w, fmt.Errorf("server error"), http.StatusInternalServerError)
+			return
+		}
 
-			// Generate unique filename
-			filename = fmt.Sprintf("%d%s", time.Now().UnixNano(), ext)
-			filePath := filepath.Join("frontend/assets/uploads/", filename)
-
-			// For GIF files, skip compression and just save the original
-			if strings.ToLower(ext) == ".gif" || strings.ToLower(ext) == ".svg" {
-				tempFile, err := os.Create(filePath)
-				if err != nil {
-					log.Printf("Error creating file: %v", err)
-					handleError(w, fmt.Errorf("failed to create file: %v", err), http.StatusInternalServerError)
-					return
-				}
-				defer tempFile.Close()
-
-				_, err = io.Copy(tempFile, file)
-				if err != nil {
-					log.Printf("Error saving file: %v", err)
-					handleError(w, fmt.Errorf("failed to save file: %v", err), http.StatusInternalServerError)
-					return
-				}
-
-			} else {
-				// For other image types, proceed with compression
-				tempFilename := fmt.Sprintf("temp_%d%s", time.Now().UnixNano(), ext)
-				tempFilePath := filepath.Join("frontend/assets/uploads/", tempFilename)
-				tempFile, err := os.Create(tempFilePath)
-				if err != nil {
-					log.Printf("Error creating temporary file: %v", err)
-					handleError(w, fmt.Errorf("failed to create temporary file: %v", err), http.StatusInternalServerError)
-					return
-				}
-				defer tempFile.Close()
-
-				_, err = io.Copy(tempFile, file)
-				if err != nil {
-					log.Printf("Error saving uploaded file: %v", err)
-					handleError(w, fmt.Errorf("failed to save file: %v", err), http.StatusInternalServerError)
-					return
-				}
-
-				// Compress and resize non-GIF images
-				err = utils.CompressAndResizeImage(tempFilePath, filePath, 800, 600, 80)
-				if err != nil {
-					log.Printf("Error compressing image: %v", err)
-					handleError(w, fmt.Errorf("failed to compress image: %v", err), http.StatusInternalServerError)
-					return
-				}
-
-				// Delete temporary file
-				os.Remove(tempFilePath)
-			}
+		slog.InfoContext(r.Context(), "Post edited", "post_id", postID, "editor_id", userID, "by_moderator", authorID != userID)
+		sendSuccessResponse(w, http.StatusOK, map[string]any{
+			"success": true,
+			"post":    post,
+		})
+	}
+}
 
-		} else if err != http.ErrMissingFile {
-			log.Printf("Error retrieving file: %v", err)
-			handleError(w, fmt.Errorf("failed to save file: %v", err), http.StatusInternalServerError)
-			return
+// savePostImage stores the request's "image" upload, compressing all but
+// GIFs and SVGs, and returns its URL. Without an upload it returns "". On
+// failure it returns the status to answer with.
+func savePostImage(r *http.Request, uploads config.Uploads) (string, int, error) {
+	file, header, err := r.FormFile("image")
+	if err == http.ErrMissingFile {
+		return "", 0, nil
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to read uploaded image", "err", err)
+		return "", http.StatusInternalServerError, fmt.Errorf("failed to save file: %v", err)
+	}
+	defer file.Close()
+
+	if header.Size > uploads.MaxSize {
+		slog.WarnContext(r.Context(), "Uploaded image too large", "bytes", header.Size)
+		return "", http.StatusBadRequest, fmt.Errorf("image size must be less than %.0fMB. Your file is %.2f MB", float64(uploads.MaxSize)/(1<<20), float64(header.Size)/(1<<20))
+	}
+
+	// Ensure the uploads directory exists
+	if _, err := os.Stat(UploadsDir); os.IsNotExist(err) {
+		err := os.Mkdir(UploadsDir, 0755)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to create uploads directory", "err", err)
+			return "", http.StatusInternalServerError, fmt.Errorf("failed to create uploads directory: %v", err)
 		}
+	}
 
-		// Set the image URL if an image was uploaded
-		if header != nil {
-			post.ImageURL = "frontend/assets/uploads/" + filename
+	fileType := header.Header.Get("Content-Type")
+
+	// Generate unique filename with original extension
+	ext := filepath.Ext(header.Filename)
+	if ext == "" {
+		// If no extension provided, derive it from content type
+		switch fileType {
+		case "image/jpeg", "image/jpg":
+			ext = ".jpg"
+		case "image/png":
+			ext = ".png"
+		case "image/gif":
+			ext = ".gif"
+		case "image/svg+xml":
+			ext = ".svg"
+		default:
+			slog.WarnContext(r.Context(), "Unsupported image type", "content_type", fileType)
+			return "", http.StatusBadRequest, fmt.Errorf("unsupported file type. Allowed types: JPEG, PNG, GIF, SVG")
 		}
+	}
 
-		// Set the creation timestamp
-		post.CreatedAt = time.Now().Format(time.RFC3339)
+	filename := fmt.Sprintf("%d%s", time.Now().UnixNano(), ext)
+	filePath := filepath.Join(UploadsDir, filename)
 
-		postID, err := database.InsertPost(userID, post)
+	// For GIF files, skip compression and just save the original
+	if strings.ToLower(ext) == ".gif" || strings.ToLower(ext) == ".svg" {
+		out, err := os.Create(filePath)
 		if err != nil {
-			errLog.Error.Println(err.Error())
-			handleError(w, fmt.Errorf("failed to insert post: %v", err), http.StatusInternalServerError)
-			return
+			slog.ErrorContext(r.Context(), "Failed to create image file", "path", filePath, "err", err)
+			return "", http.StatusInternalServerError, fmt.Errorf("failed to create file: %v", err)
 		}
+		defer out.Close()
 
-		sendSuccessResponse(w, http.StatusOK, map[string]any{
-			"success":  true,
-			"title":    post.Title,
-			"content":  post.Content,
-			"imageURL": post.ImageURL,
-			"id":       postID,
-		})
+		if _, err := io.Copy(out, file); err != nil {
+			slog.ErrorContext(r.Context(), "Failed to save image", "path", filePath, "err", err)
+			return "", http.StatusInternalServerError, fmt.Errorf("failed to save file: %v", err)
+		}
+		return UploadsDir + "/" + filename, 0, nil
+	}
 
-	default:
-		errLog.Error.Println("Method not allowed")
-		handleError(w, fmt.Errorf("method not allowed"), http.StatusMethodNotAllowed)
-		return
+	// For other image types, proceed with compression
+	tempFilePath := filepath.Join(UploadsDir, fmt.Sprintf("temp_%d%s", time.Now().UnixNano(), ext))
+	tempFile, err := os.Create(tempFilePath)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to create temporary image file", "path", tempFilePath, "err", err)
+		return "", http.StatusInternalServerError, fmt.Errorf("failed to create temporary file: %v", err)
 	}
+	defer os.Remove(tempFilePath)
+	defer tempFile.Close()
+
+	if _, err := io.Copy(tempFile, file); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to save uploaded image", "path", tempFilePath, "err", err)
+		return "", http.StatusInternalServerError, fmt.Errorf("failed to save file: %v", err)
+	}
+
+	err = utils.CompressAndResizeImage(tempFilePath, filePath, uploads.MaxWidth, uploads.MaxHeight, uploads.Quality)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to compress image", "path", tempFilePath, "err", err)
+		return "", http.StatusInternalServerError, fmt.Errorf("failed to compress image: %v", err)
+	}
+
+	return UploadsDir + "/" + filename, 0, nil
 }
 
-func parseAndValidatePostRequest(r *http.Request) (*models.Post, error) {
+func parseAndValidatePostRequest(r *http.Request, maxSize int64) (*models.Post, error) {
 	// Parse the multipart form
-	err := r.ParseMultipartForm(10 << 20) // 10 MB max file size
+	err := r.ParseMultipartForm(maxSize)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse multipart form: %v", err)
 	}
@@ -0,0 +1,255 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/nyagooh/Real-time-forum.git/backend/database"
+	"github.com/nyagooh/Real-time-forum.git/backend/middleware"
+	"github.com/nyagooh/Real-time-forum.git/backend/models"
+	"github.com/nyagooh/Real-time-forum.git/backend/utils"
+	ws "github.com/nyagooh/Real-time-forum.git/backend/websockets"
+)
+
+const (
+	// reportDetailsMaxLength caps, in characters, what a reporter may add.
+	reportDetailsMaxLength = 1000
+	// reportPageSize is how many reports ListReportsHandler returns at once.
+	reportPageSize = 50
+)
+
+// ReportHandler files a report about the post, comment or private message
+// (targetType) with the given id and tells online moderators about it.
+func ReportHandler(reports database.ReportStore, roles database.RoleStore, hub *ws.Hub, targetType string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			handleError(w, fmt.Errorf("method not allowed"), http.StatusMethodNotAllowed)
+			return
+		}
+
+		userID := r.Context().Value(middleware.UserIDKey).(int)
+
+		var req struct {
+			ID      int    `json:"id"`
+			Reason  string `json:"reason"`
+			Details string `json:"details"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ID <= 0 {
+			slog.WarnContext(r.Context(), "Invalid report request", "target_type", targetType, "err", err)
+			handleError(w, fmt.Errorf("a %s id is required", targetType), http.StatusBadRequest)
+			return
+		}
+
+		req.Details = strings.TrimSpace(req.Details)
+		var invalid utils.ValidationError
+		invalid.Add(utils.ValidateChoice("reason", req.Reason, models.ReportReasons))
+		if req.Reason == "other" && req.Details == "" {
+			invalid.Add(&utils.FieldError{Field: "details", Code: "required", Message: "say what is wrong when the reason is other"})
+		} else if utf8.RuneCountInString(req.Details) > reportDetailsMaxLength {
+			invalid.Add(&utils.FieldError{Field: "details", Code: "too_long", Message: fmt.Sprintf("details must be at most %d characters", reportDetailsMaxLength)})
+		}
+		if len(invalid) > 0 {
+			slog.WarnContext(r.Context(), "Invalid report request", "target_type", targetType, "err", invalid)
+			sendValidationError(w, invalid, http.StatusBadRequest)
+			return
+		}
+
+		authorID, err := reports.ReportedUser(targetType, req.ID, userID)
+		if errors.Is(err, sql.ErrNoRows) {
+			slog.WarnContext(r.Context(), "Report of unknown content", "target_type", targetType, "target_id", req.ID)
+			handleError(w, fmt.Errorf("%s not found", targetType), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to look up reported content", "target_type", targetType, "target_id", req.ID, "err", err)
+			handleError(w, fmt.Errorf("server error"), http.StatusInternalServerError)
+			return
+		}
+		if authorID == userID {
+			handleError(w, fmt.Errorf("you cannot report your own %s", targetType), http.StatusBadRequest)
+			return
+		}
+
+		reportID, err := reports.CreateReport(userID, targetType, req.ID, authorID, req.Reason, req.Details)
+		var duplicate *database.DuplicateError
+		if errors.As(err, &duplicate) {
+			handleError(w, fmt.Errorf("you already reported this %s", targetType), http.StatusConflict)
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to create report", "target_type", targetType, "target_id", req.ID, "err", err)
+			handleError(w, fmt.Errorf("server error"), http.StatusInternalServerError)
+			return
+		}
+
+		slog.InfoContext(r.Context(), "Content reported", "report_id", reportID, "target_type", targetType, "target_id", req.ID, "reason", req.Reason)
+		notifyModerators(r, reports, roles, hub, "created", reportID)
+
+		sendSuccessResponse(w, http.StatusCreated, map[string]any{
+			"success": true,
+			"id":      reportID,
+		})
+	}
+}
+
+// notifyModerators pushes the report to the websocket of every online
+// moderator, so their queues update without polling. event says what
+// happened to it: "created", "claimed" or "resolved".
+func notifyModerators(r *http.Request, reports database.ReportStore, roles database.RoleStore, hub *ws.Hub, event string, reportID int) {
+	if err := sendToModerators(reports, roles, hub, event, reportID); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to notify moderators", "report_id", reportID, "err", err)
+	}
+}
+
+func sendToModerators(reports database.ReportStore, roles database.RoleStore, hub *ws.Hub, event string, reportID int) error {
+	report, err := reports.GetReport(reportID)
+	if err != nil {
+		return err
+	}
+	staff, err := roles.ListStaff()
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(map[string]any{
+		"type":   "report",
+		"event":  event,
+		"report": report,
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, member := range staff {
+		if member.Role.Can(models.PermReviewReports) {
+			hub.SendMessage(member.Nickname, data)
+		}
+	}
+	return nil
+}
+
+// ListReportsHandler returns a page of the moderation queue. status picks
+// open (the default), actioned or dismissed reports.
+func ListReportsHandler(reports database.ReportStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			handleError(w, fmt.Errorf("method not allowed"), http.StatusMethodNotAllowed)
+			return
+		}
+
+		status := r.URL.Query().Get("status")
+		if status == "" {
+			status = models.ReportOpen
+		}
+		if status != models.ReportOpen && status != models.ReportActioned && status != models.ReportDismissed {
+			handleError(w, fmt.Errorf("status must be open, actioned or dismissed"), http.StatusBadRequest)
+			return
+		}
+
+		offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+		if offset < 0 {
+			offset = 0
+		}
+
+		list, err := reports.ListReports(status, reportPageSize, offset)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to list reports", "status", status, "err", err)
+			handleError(w, fmt.Errorf("server error"), http.StatusInternalServerError)
+			return
+		}
+
+		sendSuccessResponse(w, http.StatusOK, map[string]any{
+			"success": true,
+			"reports": list,
+		})
+	}
+}
+
+// ClaimReportHandler lets a moderator take an open report so others leave
+// it alone.
+func ClaimReportHandler(reports database.ReportStore, roles database.RoleStore, hub *ws.Hub) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			handleError(w, fmt.Errorf("method not allowed"), http.StatusMethodNotAllowed)
+			return
+		}
+
+		moderatorID := r.Context().Value(middleware.UserIDKey).(int)
+
+		var req struct {
+			ID int `json:"id"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ID <= 0 {
+			slog.WarnContext(r.Context(), "Invalid claim request", "err", err)
+			handleError(w, fmt.Errorf("a report id is required"), http.StatusBadRequest)
+			return
+		}
+
+		if err := reports.ClaimReport(req.ID, moderatorID); err != nil {
+			handleReportError(w, r, err, req.ID)
+			return
+		}
+
+		slog.InfoContext(r.Context(), "Report claimed", "report_id", req.ID, "by", moderatorID)
+		notifyModerators(r, reports, roles, hub, "claimed", req.ID)
+
+		sendSuccessResponse(w, http.StatusOK, map[string]bool{"success": true})
+	}
+}
+
+// ResolveReportHandler closes a report as actioned or dismissed with the
+// moderator's note.
+func ResolveReportHandler(reports database.ReportStore, roles database.RoleStore, hub *ws.Hub) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			handleError(w, fmt.Errorf("method not allowed"), http.StatusMethodNotAllowed)
+			return
+		}
+
+		moderatorID := r.Context().Value(middleware.UserIDKey).(int)
+
+		var req struct {
+			ID         int    `json:"id"`
+			Status     string `json:"status"`
+			Resolution string `json:"resolution"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ID <= 0 ||
+			(req.Status != models.ReportActioned && req.Status != models.ReportDismissed) {
+			slog.WarnContext(r.Context(), "Invalid resolve request", "err", err, "status", req.Status)
+			handleError(w, fmt.Errorf("a report id and a status of actioned or dismissed are required"), http.StatusBadRequest)
+			return
+		}
+
+		err := reports.ResolveReport(req.ID, moderatorID, req.Status, strings.TrimSpace(req.Resolution))
+		if err != nil {
+			handleReportError(w, r, err, req.ID)
+			return
+		}
+
+		slog.InfoContext(r.Context(), "Report resolved", "report_id", req.ID, "status", req.Status, "by", moderatorID)
+		notifyModerators(r, reports, roles, hub, "resolved", req.ID)
+
+		sendSuccessResponse(w, http.StatusOK, map[string]bool{"success": true})
+	}
+}
+
+// handleReportError answers a failed claim or resolution.
+func handleReportError(w http.ResponseWriter, r *http.Request, err error, reportID int) {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		handleError(w, fmt.Errorf("report not found"), http.StatusNotFound)
+	case errors.Is(err, database.ErrReportClosed), errors.Is(err, database.ErrReportClaimed):
+		slog.WarnContext(r.Context(), "Report not available", "report_id", reportID, "err", err)
+		handleError(w, err, http.StatusConflict)
+	default:
+		slog.ErrorContext(r.Context(), "Failed to update report", "report_id", reportID, "err", err)
+		handleError(w, fmt.Errorf("server error"), http.StatusInternalServerError)
+	}
+}
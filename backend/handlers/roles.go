@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/nyagooh/Real-time-forum.git/backend/database"
+	"github.com/nyagooh/Real-time-forum.git/backend/middleware"
+	"github.com/nyagooh/Real-time-forum.git/backend/models"
+)
+
+// ListStaffHandler lists the forum's moderators and admins.
+func ListStaffHandler(roles database.RoleStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			handleError(w, fmt.Errorf("method not allowed"), http.StatusMethodNotAllowed)
+			return
+		}
+
+		staff, err := roles.ListStaff()
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to list staff", "err", err)
+			handleError(w, fmt.Errorf("server error"), http.StatusInternalServerError)
+			return
+		}
+
+		sendSuccessResponse(w, http.StatusOK, map[string]any{
+			"success": true,
+			"staff":   staff,
+		})
+	}
+}
+
+// SetRoleHandler gives the account named by nickname a new role.
+func SetRoleHandler(users database.UserStore, roles database.RoleStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			handleError(w, fmt.Errorf("method not allowed"), http.StatusMethodNotAllowed)
+			return
+		}
+
+		adminID := r.Context().Value(middleware.UserIDKey).(int)
+
+		var req struct {
+			Nickname string      `json:"nickname"`
+			Role     models.Role `json:"role"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Nickname == "" || !req.Role.Valid() {
+			slog.WarnContext(r.Context(), "Invalid role change request", "err", err, "role", req.Role)
+			handleError(w, fmt.Errorf("a nickname and a role of user, moderator or admin are required"), http.StatusBadRequest)
+			return
+		}
+
+		userID, err := users.GetUserID(req.Nickname)
+		if err != nil {
+			slog.WarnContext(r.Context(), "Role change for unknown user", "nickname", req.Nickname, "err", err)
+			handleError(w, fmt.Errorf("user not found"), http.StatusNotFound)
+			return
+		}
+
+		err = roles.SetUserRole(userID, req.Role)
+		if errors.Is(err, database.ErrLastAdmin) {
+			slog.WarnContext(r.Context(), "Refused to demote the last admin", "user_id", userID)
+			handleError(w, err, http.StatusConflict)
+			return
+		}
+		if errors.Is(err, sql.ErrNoRows) {
+			handleError(w, fmt.Errorf("user not found"), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to set role", "user_id", userID, "err", err)
+			handleError(w, fmt.Errorf("server error"), http.StatusInternalServerError)
+			return
+		}
+
+		slog.InfoContext(r.Context(), "Role changed", "user_id", userID, "role", req.Role, "by", adminID)
+		sendSuccessResponse(w, http.StatusOK, map[string]any{
+			"success": true,
+			"role":    req.Role,
+		})
+	}
+}
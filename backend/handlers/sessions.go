@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/nyagooh/Real-time-forum.git/backend/database"
+	"github.com/nyagooh/Real-time-forum.git/backend/middleware"
+	ws "github.com/nyagooh/Real-time-forum.git/backend/websockets"
+)
+
+// ListSessionsHandler lists the devices the user is signed in on.
+func ListSessionsHandler(sessions database.SessionStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			handleError(w, fmt.Errorf("method not allowed"), http.StatusMethodNotAllowed)
+			return
+		}
+
+		userID := r.Context().Value(middleware.UserIDKey).(int)
+		current, ok := middleware.SessionToken(r)
+		if !ok {
+			handleError(w, fmt.Errorf("unauthorized"), http.StatusUnauthorized)
+			return
+		}
+
+		list, err := sessions.ListSessions(userID, current)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to list sessions", "user_id", userID, "err", err)
+			handleError(w, fmt.Errorf("server error"), http.StatusInternalServerError)
+			return
+		}
+
+		sendSuccessResponse(w, http.StatusOK, map[string]any{
+			"success":  true,
+			"sessions": list,
+		})
+	}
+}
+
+// RevokeSessionHandler signs one of the user's devices out and disconnects
+// its chat connections. Revoking the current session works like logging out.
+func RevokeSessionHandler(sessions database.SessionStore, hub *ws.Hub) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			handleError(w, fmt.Errorf("method not allowed"), http.StatusMethodNotAllowed)
+			return
+		}
+
+		userID := r.Context().Value(middleware.UserIDKey).(int)
+		current, ok := middleware.SessionToken(r)
+		if !ok {
+			handleError(w, fmt.Errorf("unauthorized"), http.StatusUnauthorized)
+			return
+		}
+
+		var req struct {
+			ID int `json:"id"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ID <= 0 {
+			slog.WarnContext(r.Context(), "Invalid revoke session request", "err", err)
+			handleError(w, fmt.Errorf("a session id is required"), http.StatusBadRequest)
+			return
+		}
+
+		token, err := sessions.DeleteUserSession(userID, req.ID)
+		if errors.Is(err, sql.ErrNoRows) {
+			slog.WarnContext(r.Context(), "Revoke of unknown session", "user_id", userID, "session_id", req.ID)
+			handleError(w, fmt.Errorf("session not found"), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to revoke session", "user_id", userID, "session_id", req.ID, "err", err)
+			handleError(w, fmt.Errorf("server error"), http.StatusInternalServerError)
+			return
+		}
+
+		hub.CloseSessions("session revoked", token)
+		slog.InfoContext(r.Context(), "Session revoked", "user_id", userID, "session_id", req.ID)
+
+		if token == current {
+			middleware.DeleteCookie(w)
+		}
+
+		sendSuccessResponse(w, http.StatusOK, map[string]bool{"success": true})
+	}
+}
+
+// RevokeOtherSessionsHandler signs the user out everywhere but here.
+func RevokeOtherSessionsHandler(sessions database.SessionStore, hub *ws.Hub) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			handleError(w, fmt.Errorf("method not allowed"), http.StatusMethodNotAllowed)
+			return
+		}
+
+		userID := r.Context().Value(middleware.UserIDKey).(int)
+		current, ok := middleware.SessionToken(r)
+		if !ok {
+			handleError(w, fmt.Errorf("unauthorized"), http.StatusUnauthorized)
+			return
+		}
+
+		tokens, err := sessions.DeleteOtherSessions(userID, current)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to revoke other sessions", "user_id", userID, "err", err)
+			handleError(w, fmt.Errorf("server error"), http.StatusInternalServerError)
+			return
+		}
+
+		hub.CloseSessions("session revoked", tokens...)
+		slog.InfoContext(r.Context(), "Other sessions revoked", "user_id", userID, "count", len(tokens))
+
+		sendSuccessResponse(w, http.StatusOK, map[string]any{
+			"success": true,
+			"revoked": len(tokens),
+		})
+	}
+}
@@ -0,0 +1,371 @@
+package handlers
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nyagooh/Real-time-forum.git/backend/config"
+	"github.com/nyagooh/Real-time-forum.git/backend/database"
+	"github.com/nyagooh/Real-time-forum.git/backend/handlers"
+	"github.com/nyagooh/Real-time-forum.git/backend/mail"
+	"github.com/nyagooh/Real-time-forum.git/backend/models"
+	"github.com/nyagooh/Real-time-forum.git/backend/utils"
+)
+
+// fakeUsers is an in-memory UserStore keyed by nickname. Like the database it
+// treats nicknames and emails that differ only by case as the same.
+type fakeUsers struct {
+	users    map[string]models.User
+	verified bool
+}
+
+func newFakeUsers() *fakeUsers {
+	return &fakeUsers{users: make(map[string]models.User)}
+}
+
+func (f *fakeUsers) InsertUser(user models.User) error {
+	for _, existing := range f.users {
+		if strings.EqualFold(existing.Nickname, user.Nickname) {
+			return &database.DuplicateError{Field: "nickname"}
+		}
+		if strings.EqualFold(existing.Email, user.Email) {
+			return &database.DuplicateError{Field: "email"}
+		}
+	}
+	f.users[user.Nickname] = user
+	return nil
+}
+
+func (f *fakeUsers) GetUser(credential models.Credentials) (models.UserIdentity, string, error) {
+	for _, user := range f.users {
+		if user.Nickname == credential.Identity || user.Email == credential.Identity {
+			return models.UserIdentity{ID: "1", Nickname: user.Nickname, Email: user.Email}, user.Password, nil
+		}
+	}
+	return models.UserIdentity{}, "", fmt.Errorf("user not found")
+}
+
+func (f *fakeUsers) GetUsers() ([]string, error) { return nil, nil }
+
+func (f *fakeUsers) GetUserID(identity string) (int, error) {
+	for _, user := range f.users {
+		if user.Nickname == identity || user.Email == identity {
+			return 1, nil
+		}
+	}
+	return 0, fmt.Errorf("user not found")
+}
+
+func (f *fakeUsers) GetUserByID(id int) (string, error) {
+	for _, user := range f.users {
+		return user.Nickname, nil
+	}
+	return "", fmt.Errorf("user not found")
+}
+
+func (f *fakeUsers) GetUserEmail(id int) (string, error) {
+	for _, user := range f.users {
+		return user.Email, nil
+	}
+	return "", fmt.Errorf("user not found")
+}
+
+func (f *fakeUsers) IsVerified(id int) (bool, error) { return f.verified, nil }
+
+func (f *fakeUsers) GetUsersByInteraction(userID int) ([]models.ChatUser, error) { return nil, nil }
+
+// fakeSessions is an in-memory SessionStore keyed by token. Session IDs
+// follow insertion order.
+type fakeSessions struct {
+	tokens map[string]int
+	ids    []string
+}
+
+func newFakeSessions() *fakeSessions {
+	return &fakeSessions{tokens: make(map[string]int)}
+}
+
+func (f *fakeSessions) InsertSession(id int, session string, expiresAt, maxExpiresAt time.Time, userAgent, ip string) error {
+	f.tokens[session] = id
+	f.ids = append(f.ids, session)
+	return nil
+}
+
+func (f *fakeSessions) TouchSession(token string, lifetime time.Duration) (time.Time, error) {
+	return time.Time{}, nil
+}
+
+func (f *fakeSessions) ListSessions(userID int, current string) ([]models.Session, error) {
+	var list []models.Session
+	for i, token := range f.ids {
+		if id, ok := f.tokens[token]; ok && id == userID {
+			list = append(list, models.Session{ID: i + 1, Current: token == current})
+		}
+	}
+	return list, nil
+}
+
+func (f *fakeSessions) DeleteUserSession(userID, sessionID int) (string, error) {
+	if sessionID < 1 || sessionID > len(f.ids) || f.tokens[f.ids[sessionID-1]] != userID {
+		return "", sql.ErrNoRows
+	}
+	token := f.ids[sessionID-1]
+	delete(f.tokens, token)
+	return token, nil
+}
+
+func (f *fakeSessions) DeleteOtherSessions(userID int, keep string) ([]string, error) {
+	var revoked []string
+	for token, id := range f.tokens {
+		if id == userID && token != keep {
+			delete(f.tokens, token)
+			revoked = append(revoked, token)
+		}
+	}
+	return revoked, nil
+}
+
+func (f *fakeSessions) DeleteSession(id int) error {
+	for token, userID := range f.tokens {
+		if userID == id {
+			delete(f.tokens, token)
+		}
+	}
+	return nil
+}
+
+func (f *fakeSessions) DeleteSessionByToken(token string) error {
+	delete(f.tokens, token)
+	return nil
+}
+
+func (f *fakeSessions) GetUserIDFromSession(token string) (int, error) {
+	if id, ok := f.tokens[token]; ok {
+		return id, nil
+	}
+	return 0, sql.ErrNoRows
+}
+
+func (f *fakeSessions) GetUserFromSession(token string) (*models.UserIdentity, error) {
+	return nil, sql.ErrNoRows
+}
+
+func (f *fakeSessions) CleanupExpiredSessions() error { return nil }
+
+func TestLoginHandler(t *testing.T) {
+	users := newFakeUsers()
+	hashed, err := utils.HashPassword("correct-horse")
+	if err != nil {
+		t.Fatalf("failed to hash password: %v", err)
+	}
+	users.InsertUser(models.User{Nickname: "alice", Email: "alice@example.com", Password: hashed})
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantCookie bool
+	}{
+		{
+			name:       "Valid credentials",
+			body:       `{"identity":"alice","password":"correct-horse"}`,
+			wantStatus: http.StatusOK,
+			wantCookie: true,
+		},
+		{
+			name:       "Wrong password",
+			body:       `{"identity":"alice","password":"wrong"}`,
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "Unknown user",
+			body:       `{"identity":"bob","password":"correct-horse"}`,
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "Missing fields",
+			body:       `{"identity":""}`,
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sessions := newFakeSessions()
+			handler := handlers.LoginHandler(users, sessions, newFakeTwoFactor(), newFakeAttempts(), &mail.MemoryMailer{}, config.Default().Session, config.Default().Account, "http://forum.test")
+
+			req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(tt.body))
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("LoginHandler() status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+
+			gotCookie := strings.Contains(rec.Header().Get("Set-Cookie"), "session_token=")
+			if gotCookie != tt.wantCookie {
+				t.Errorf("LoginHandler() session cookie set = %v, want %v", gotCookie, tt.wantCookie)
+			}
+			if tt.wantCookie && len(sessions.tokens) != 1 {
+				t.Errorf("expected one stored session, got %d", len(sessions.tokens))
+			}
+		})
+	}
+}
+
+func TestRegisterHandler(t *testing.T) {
+	users := newFakeUsers()
+	verifications := &fakeTokens{tokens: make(map[string]time.Time)}
+	mailer := &mail.MemoryMailer{}
+	handler := handlers.RegisterHandler(users, verifications, mailer, config.Default().Account, "http://forum.test")
+
+	body := `{"nickname":"carol","age":"30","gender":"female","firstname":"Carol","lastname":"Smith","email":"Carol@Example.com","password":"s3cret-pass"}`
+	req := httptest.NewRequest(http.MethodPost, "/register", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("RegisterHandler() status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	stored, ok := users.users["carol"]
+	if !ok {
+		t.Fatalf("expected user to be stored")
+	}
+	if stored.Password == "s3cret-pass" {
+		t.Errorf("expected password to be hashed before storing")
+	}
+	if stored.Email != "carol@example.com" {
+		t.Errorf("stored email = %q, want it in lower case", stored.Email)
+	}
+	if messages := mailer.Messages(); len(messages) != 1 || messages[0].To != "carol@example.com" {
+		t.Errorf("verification messages = %+v, want one to carol@example.com", messages)
+	}
+	if len(verifications.tokens) != 1 {
+		t.Errorf("stored %d verification tokens, want 1", len(verifications.tokens))
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/register", strings.NewReader(`{"nickname":"dave","email":"not-an-email"}`))
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("RegisterHandler() invalid email status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
+func TestRegisterValidation(t *testing.T) {
+	users := newFakeUsers()
+	users.InsertUser(models.User{Nickname: "taken", Email: "taken@example.com"})
+	handler := handlers.RegisterHandler(users, &fakeTokens{tokens: make(map[string]time.Time)}, &mail.MemoryMailer{}, config.Default().Account, "http://forum.test")
+
+	valid := map[string]string{
+		"nickname": "erin_b", "age": "30", "gender": "female", "firstname": "Erin",
+		"lastname": "Berg", "email": "erin@example.com", "password": "s3cret-pass",
+	}
+	tests := []struct {
+		name       string
+		change     map[string]string
+		wantStatus int
+		wantErrors map[string]string // field to code
+	}{
+		{
+			name:       "Empty form",
+			change:     map[string]string{"nickname": "", "age": "", "gender": "", "firstname": "", "lastname": "", "email": "", "password": ""},
+			wantStatus: http.StatusBadRequest,
+			wantErrors: map[string]string{"nickname": "required", "password": "required", "age": "required", "gender": "required", "firstname": "required", "lastname": "required", "email": "required"},
+		},
+		{
+			name:       "Nickname with spaces",
+			change:     map[string]string{"nickname": "erin b"},
+			wantStatus: http.StatusBadRequest,
+			wantErrors: map[string]string{"nickname": "invalid_chars"},
+		},
+		{
+			name:       "Reserved nickname",
+			change:     map[string]string{"nickname": "Admin"},
+			wantStatus: http.StatusBadRequest,
+			wantErrors: map[string]string{"nickname": "reserved"},
+		},
+		{
+			name:       "Weak password",
+			change:     map[string]string{"password": "passwordonly"},
+			wantStatus: http.StatusBadRequest,
+			wantErrors: map[string]string{"password": "weak"},
+		},
+		{
+			name:       "Age not a number and unknown gender",
+			change:     map[string]string{"age": "thirty", "gender": "robot"},
+			wantStatus: http.StatusBadRequest,
+			wantErrors: map[string]string{"age": "invalid", "gender": "invalid_choice"},
+		},
+		{
+			name:       "Too young",
+			change:     map[string]string{"age": "9"},
+			wantStatus: http.StatusBadRequest,
+			wantErrors: map[string]string{"age": "out_of_range"},
+		},
+		{
+			name:       "Nickname taken",
+			change:     map[string]string{"nickname": "taken"},
+			wantStatus: http.StatusConflict,
+			wantErrors: map[string]string{"nickname": "taken"},
+		},
+		{
+			name:       "Nickname taken in another case",
+			change:     map[string]string{"nickname": "Taken"},
+			wantStatus: http.StatusConflict,
+			wantErrors: map[string]string{"nickname": "taken"},
+		},
+		{
+			name:       "Email taken in another case",
+			change:     map[string]string{"email": "Taken@Example.com"},
+			wantStatus: http.StatusConflict,
+			wantErrors: map[string]string{"email": "taken"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fields := make(map[string]string)
+			for k, v := range valid {
+				fields[k] = v
+			}
+			for k, v := range tt.change {
+				fields[k] = v
+			}
+			body, _ := json.Marshal(fields)
+
+			req := httptest.NewRequest(http.MethodPost, "/register", bytes.NewReader(body))
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+
+			var resp struct {
+				Message string             `json:"message"`
+				Errors  []utils.FieldError `json:"errors"`
+			}
+			json.Unmarshal(rec.Body.Bytes(), &resp)
+			got := make(map[string]string)
+			for _, fe := range resp.Errors {
+				got[fe.Field] = fe.Code
+				if fe.Message == "" {
+					t.Errorf("error for %s has no message", fe.Field)
+				}
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.wantErrors) {
+				t.Errorf("errors = %v, want %v", got, tt.wantErrors)
+			}
+			if resp.Message == "" {
+				t.Error("response has no message")
+			}
+		})
+	}
+}
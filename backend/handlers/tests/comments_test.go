@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nyagooh/Real-time-forum.git/backend/config"
+	"github.com/nyagooh/Real-time-forum.git/backend/handlers"
+	"github.com/nyagooh/Real-time-forum.git/backend/models"
+)
+
+func TestCommentThreads(t *testing.T) {
+	posts := newFakePosts()
+	posts.InsertPost(1, &models.Post{Title: "First"})
+	posts.InsertPost(1, &models.Post{Title: "Second"})
+	add := handlers.AddCommentHandler(posts, config.Content{CommentMaxDepth: 2})
+	list := handlers.ListCommentsHandler(posts)
+
+	tests := []struct {
+		name       string
+		target     string
+		body       string
+		wantStatus int
+	}{
+		{"Top level", "/comments?id=1", `{"text":"root"}`, http.StatusOK},
+		{"Reply", "/comments?id=1", `{"text":"reply","parentId":1}`, http.StatusOK},
+		{"Nested reply", "/comments?id=1", `{"text":"nested","parentId":2}`, http.StatusOK},
+		{"Too deep", "/comments?id=1", `{"text":"deeper","parentId":3}`, http.StatusBadRequest},
+		{"Unknown parent", "/comments?id=1", `{"text":"lost","parentId":9}`, http.StatusNotFound},
+		{"Parent on another post", "/comments?id=2", `{"text":"astray","parentId":1}`, http.StatusNotFound},
+		{"Unknown post", "/comments?id=9", `{"text":"nowhere"}`, http.StatusNotFound},
+		{"Second root", "/comments?id=1", `{"text":"another root"}`, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if status, resp := as(add, 1, http.MethodPost, tt.target, tt.body); status != tt.wantStatus {
+				t.Errorf("status = %d, want %d: %v", status, tt.wantStatus, resp)
+			}
+		})
+	}
+
+	// Round-trip the response through models.Comment to walk the tree.
+	thread := func(target string) []models.Comment {
+		t.Helper()
+		status, resp := as(list, 1, http.MethodGet, target, "")
+		if status != http.StatusOK {
+			t.Fatalf("GET %s status = %d: %v", target, status, resp)
+		}
+		raw, _ := json.Marshal(resp["comments"])
+		var comments []models.Comment
+		json.Unmarshal(raw, &comments)
+		return comments
+	}
+
+	tree := thread("/comments/thread?id=1")
+	if len(tree) != 2 || len(tree[0].Children) != 1 || len(tree[0].Children[0].Children) != 1 || tree[0].Children[0].Children[0].Content != "nested" {
+		t.Errorf("tree = %+v, want root > reply > nested and a second root", tree)
+	}
+	if flat := thread("/comments/thread?id=1&format=flat"); len(flat) != 4 || flat[2].Depth != 2 || flat[3].Content != "another root" {
+		t.Errorf("flat = %+v, want four comments in thread order", flat)
+	}
+	if branch := thread("/comments/thread?id=1&parent=1"); len(branch) != 1 || branch[0].Content != "reply" || len(branch[0].Children) != 1 {
+		t.Errorf("branch = %+v, want the reply with its nested child", branch)
+	}
+	if empty := thread("/comments/thread?id=2"); len(empty) != 0 {
+		t.Errorf("comments on an empty post = %+v", empty)
+	}
+
+	for _, target := range []string{"/comments/thread", "/comments/thread?id=1&depth=0", "/comments/thread?id=1&format=xml"} {
+		if status, _ := as(list, 1, http.MethodGet, target, ""); status != http.StatusBadRequest {
+			t.Errorf("GET %s status = %d, want %d", target, status, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestEditAndDeleteComment(t *testing.T) {
+	posts := newFakePosts()
+	posts.InsertPost(1, &models.Post{Title: "First"})
+	for _, text := range []string{"mine", "old", "theirs"} {
+		posts.AddComment(1, 1, 0, &models.Post{Content: text})
+	}
+	posts.comment(2).CreatedAt = time.Now().Add(-time.Hour).Format(time.RFC3339)
+	roles := fakeRoles{3: models.RoleModerator}
+	edit := handlers.EditCommentHandler(posts, config.Content{CommentEditWindow: 15 * time.Minute})
+	remove := handlers.DeleteCommentHandler(posts, roles)
+
+	tests := []struct {
+		name       string
+		handler    http.Handler
+		user       int
+		method     string
+		body       string
+		wantStatus int
+	}{
+		{"Wrong method", edit, 1, http.MethodPost, `{"id":1,"text":"x"}`, http.StatusMethodNotAllowed},
+		{"Empty text", edit, 1, http.MethodPut, `{"id":1,"text":"  "}`, http.StatusBadRequest},
+		{"Unknown comment", edit, 1, http.MethodPut, `{"id":9,"text":"x"}`, http.StatusNotFound},
+		{"Someone else's comment", edit, 2, http.MethodPut, `{"id":1,"text":"x"}`, http.StatusForbidden},
+		{"Moderators cannot edit", edit, 3, http.MethodPut, `{"id":1,"text":"x"}`, http.StatusForbidden},
+		{"Window passed", edit, 1, http.MethodPut, `{"id":2,"text":"x"}`, http.StatusForbidden},
+		{"Author edits", edit, 1, http.MethodPut, `{"id":1,"text":"changed"}`, http.StatusOK},
+		{"Someone else deletes", remove, 2, http.MethodDelete, `{"id":1}`, http.StatusForbidden},
+		{"Reason too long", remove, 3, http.MethodDelete, `{"id":3,"reason":"` + strings.Repeat("x", 501) + `"}`, http.StatusBadRequest},
+		{"Moderator removes", remove, 3, http.MethodDelete, `{"id":3,"reason":"spam"}`, http.StatusOK},
+		{"Author deletes", remove, 1, http.MethodDelete, `{"id":1}`, http.StatusOK},
+		{"Edit after delete", edit, 1, http.MethodPut, `{"id":1,"text":"again"}`, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if status, resp := as(tt.handler, tt.user, tt.method, "/comments/edit", tt.body); status != tt.wantStatus {
+				t.Errorf("status = %d, want %d: %v", status, tt.wantStatus, resp)
+			}
+		})
+	}
+
+	if c := posts.comment(1); c.Content != "changed" || !c.Edited || !c.Deleted {
+		t.Errorf("comment 1 = %+v, want edited then deleted", c)
+	}
+	if c := posts.comment(2); c.Content != "old" || c.Edited {
+		t.Errorf("comment 2 = %+v, want it untouched", c)
+	}
+}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/nyagooh/Real-time-forum.git/backend/handlers"
+)
+
+func TestReadyHandler(t *testing.T) {
+	pass := handlers.Check{Name: "database", Run: func(context.Context) error { return nil }}
+	fail := handlers.Check{Name: "hub", Run: func(context.Context) error { return errors.New("websocket hub is not running") }}
+
+	tests := []struct {
+		name       string
+		checks     []handlers.Check
+		wantCode   int
+		wantStatus string
+	}{
+		{"All pass", []handlers.Check{pass}, http.StatusOK, "ok"},
+		{"One fails", []handlers.Check{pass, fail}, http.StatusServiceUnavailable, "fail"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handlers.ReadyHandler(tt.checks...)(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+			if rec.Code != tt.wantCode {
+				t.Errorf("status code = %d, want %d", rec.Code, tt.wantCode)
+			}
+
+			var body struct {
+				Status string `json:"status"`
+				Checks map[string]struct {
+					Status string `json:"status"`
+					Error  string `json:"error"`
+				} `json:"checks"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("body is not JSON: %q", rec.Body.String())
+			}
+			if body.Status != tt.wantStatus {
+				t.Errorf("status = %q, want %q", body.Status, tt.wantStatus)
+			}
+			if len(body.Checks) != len(tt.checks) {
+				t.Errorf("got %d check results, want %d", len(body.Checks), len(tt.checks))
+			}
+			if hub, ok := body.Checks["hub"]; ok && hub.Error == "" {
+				t.Error("failing check has no error message")
+			}
+		})
+	}
+}
+
+func TestHealthHandler(t *testing.T) {
+	rec := httptest.NewRecorder()
+	handlers.HealthHandler()(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("status code = %d, want 200", rec.Code)
+	}
+}
@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nyagooh/Real-time-forum.git/backend/config"
+	"github.com/nyagooh/Real-time-forum.git/backend/database"
+	"github.com/nyagooh/Real-time-forum.git/backend/handlers"
+	"github.com/nyagooh/Real-time-forum.git/backend/mail"
+	"github.com/nyagooh/Real-time-forum.git/backend/models"
+	"github.com/nyagooh/Real-time-forum.git/backend/utils"
+)
+
+var unlockLink = regexp.MustCompile(`http://forum\.test/unlock-account\?token=(\S+)`)
+
+// fakeAttempts is an in-memory LoginAttemptStore.
+type fakeAttempts struct {
+	accounts map[int]*models.LoginFailures
+	ips      map[string]*models.LoginFailures
+	unlocks  map[string]int
+	log      []models.LoginAttempt
+}
+
+func newFakeAttempts() *fakeAttempts {
+	return &fakeAttempts{
+		accounts: make(map[int]*models.LoginFailures),
+		ips:      make(map[string]*models.LoginFailures),
+		unlocks:  make(map[string]int),
+	}
+}
+
+func (f *fakeAttempts) account(userID int) *models.LoginFailures {
+	if f.accounts[userID] == nil {
+		f.accounts[userID] = &models.LoginFailures{}
+	}
+	return f.accounts[userID]
+}
+
+func (f *fakeAttempts) RecordLoginAttempt(userID int, ip, userAgent string, success bool) (int, error) {
+	now := time.Now()
+	f.log = append(f.log, models.LoginAttempt{Success: success, IP: ip, UserAgent: userAgent, CreatedAt: now})
+	if !success {
+		if f.ips[ip] == nil {
+			f.ips[ip] = &models.LoginFailures{}
+		}
+		f.ips[ip].Count++
+		f.ips[ip].Last = now
+	}
+	if userID == 0 {
+		return 0, nil
+	}
+
+	account := f.account(userID)
+	if success {
+		account.Count = 0
+		return 0, nil
+	}
+	account.Count++
+	account.Last = now
+	return account.Count, nil
+}
+
+func (f *fakeAttempts) AccountFailures(userID int) (models.LoginFailures, error) {
+	return *f.account(userID), nil
+}
+
+func (f *fakeAttempts) IPFailures(ip string, since time.Time) (models.LoginFailures, error) {
+	if f.ips[ip] == nil {
+		return models.LoginFailures{}, nil
+	}
+	return *f.ips[ip], nil
+}
+
+func (f *fakeAttempts) LockAccount(userID int, until time.Time, tokenHash string, tokenExpiresAt time.Time) error {
+	f.account(userID).LockedUntil = until
+	f.unlocks[tokenHash] = userID
+	return nil
+}
+
+func (f *fakeAttempts) UnlockAccount(tokenHash string) (int, error) {
+	userID, ok := f.unlocks[tokenHash]
+	if !ok {
+		return 0, database.ErrInvalidToken
+	}
+	delete(f.unlocks, tokenHash)
+	f.accounts[userID] = &models.LoginFailures{}
+	return userID, nil
+}
+
+func (f *fakeAttempts) ListLoginAttempts(userID, limit int) ([]models.LoginAttempt, error) {
+	return f.log, nil
+}
+
+func (f *fakeAttempts) PruneLoginAttempts(cutoff time.Time) (int, error) { return 0, nil }
+
+func TestLoginBackoff(t *testing.T) {
+	users := newFakeUsers()
+	hash, _ := utils.HashPassword("correct-horse")
+	users.InsertUser(models.User{Nickname: "gina", Email: "gina@example.com", Password: hash})
+
+	cfg := config.Default()
+	cfg.Account.LoginThrottle.FreeFailures = 2
+	cfg.Account.LoginThrottle.BackoffBase = time.Hour
+	cfg.Account.LoginThrottle.BackoffMax = time.Hour
+	attempts := newFakeAttempts()
+	login := handlers.LoginHandler(users, newFakeSessions(), newFakeTwoFactor(), attempts, &mail.MemoryMailer{}, cfg.Session, cfg.Account, "http://forum.test")
+
+	// The free failures cost nothing; the one after them starts the wait.
+	for i := 0; i < 3; i++ {
+		if status, _ := post(t, login, "/login", `{"identity":"gina","password":"wrong"}`); status != http.StatusUnauthorized {
+			t.Fatalf("failure %d status = %d, want %d", i+1, status, http.StatusUnauthorized)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(`{"identity":"gina","password":"correct-horse"}`))
+	rec := httptest.NewRecorder()
+	login.ServeHTTP(rec, req)
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("login after free failures status = %d, want %d", rec.Code, http.StatusTooManyRequests)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "3600" {
+		t.Errorf("Retry-After = %q, want %q", got, "3600")
+	}
+
+	if len(attempts.log) != 3 {
+		t.Errorf("logged %d attempts, want 3; throttled tries are not attempts", len(attempts.log))
+	}
+}
+
+func TestLoginLockout(t *testing.T) {
+	users := newFakeUsers()
+	hash, _ := utils.HashPassword("correct-horse")
+	users.InsertUser(models.User{Nickname: "hugo", Email: "hugo@example.com", Password: hash})
+
+	cfg := config.Default()
+	cfg.Account.LoginThrottle.FreeFailures = 10
+	cfg.Account.LoginThrottle.LockAfter = 3
+	attempts := newFakeAttempts()
+	mailer := &mail.MemoryMailer{}
+	login := handlers.LoginHandler(users, newFakeSessions(), newFakeTwoFactor(), attempts, mailer, cfg.Session, cfg.Account, "http://forum.test")
+	unlock := handlers.UnlockAccountHandler(attempts)
+
+	for i := 0; i < 3; i++ {
+		post(t, login, "/login", `{"identity":"hugo","password":"wrong"}`)
+	}
+
+	// Locked accounts refuse even the right password.
+	if status, _ := post(t, login, "/login", `{"identity":"hugo","password":"correct-horse"}`); status != http.StatusTooManyRequests {
+		t.Fatalf("login while locked status = %d, want %d", status, http.StatusTooManyRequests)
+	}
+
+	messages := mailer.Messages()
+	if len(messages) != 1 || messages[0].To != "hugo@example.com" {
+		t.Fatalf("expected one unlock email to hugo, got %+v", messages)
+	}
+	match := unlockLink.FindStringSubmatch(messages[0].Body)
+	if match == nil {
+		t.Fatalf("unlock email has no link: %q", messages[0].Body)
+	}
+	token, _ := url.QueryUnescape(match[1])
+
+	if status, _ := post(t, unlock, "/unlock-account", `{"token":"bogus"}`); status != http.StatusBadRequest {
+		t.Errorf("unlock with bogus token status = %d, want %d", status, http.StatusBadRequest)
+	}
+	if status, resp := post(t, unlock, "/unlock-account", `{"token":"`+token+`"}`); status != http.StatusOK {
+		t.Fatalf("unlock status = %d: %v", status, resp)
+	}
+	if status, _ := post(t, unlock, "/unlock-account", `{"token":"`+token+`"}`); status != http.StatusBadRequest {
+		t.Errorf("second unlock status = %d, want %d", status, http.StatusBadRequest)
+	}
+
+	if status, resp := post(t, login, "/login", `{"identity":"hugo","password":"correct-horse"}`); status != http.StatusOK {
+		t.Fatalf("login after unlock status = %d: %v", status, resp)
+	}
+}
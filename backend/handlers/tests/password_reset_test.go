@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nyagooh/Real-time-forum.git/backend/config"
+	"github.com/nyagooh/Real-time-forum.git/backend/database"
+	"github.com/nyagooh/Real-time-forum.git/backend/handlers"
+	"github.com/nyagooh/Real-time-forum.git/backend/mail"
+	"github.com/nyagooh/Real-time-forum.git/backend/models"
+	ws "github.com/nyagooh/Real-time-forum.git/backend/websockets"
+)
+
+// fakeTokens is an in-memory PasswordResetStore and EmailVerificationStore
+// keyed by token hash.
+type fakeTokens struct {
+	tokens   map[string]time.Time
+	password string
+	verified bool
+}
+
+func (f *fakeTokens) CreatePasswordReset(userID int, tokenHash string, expiresAt time.Time) error {
+	f.tokens[tokenHash] = expiresAt
+	return nil
+}
+
+func (f *fakeTokens) ResetPassword(tokenHash, passwordHash string) (int, []string, error) {
+	if err := f.spend(tokenHash); err != nil {
+		return 0, nil, err
+	}
+	f.password = passwordHash
+	return 1, []string{"session-1"}, nil
+}
+
+func (f *fakeTokens) CreateEmailVerification(userID int, tokenHash string, expiresAt time.Time) error {
+	f.tokens[tokenHash] = expiresAt
+	return nil
+}
+
+func (f *fakeTokens) VerifyEmail(tokenHash string) (int, error) {
+	if err := f.spend(tokenHash); err != nil {
+		return 0, err
+	}
+	f.verified = true
+	return 1, nil
+}
+
+func (f *fakeTokens) spend(tokenHash string) error {
+	expiresAt, ok := f.tokens[tokenHash]
+	if !ok || time.Now().After(expiresAt) {
+		return database.ErrInvalidToken
+	}
+	delete(f.tokens, tokenHash)
+	return nil
+}
+
+// waitForMessages returns the mailer's messages once there are at least n.
+func waitForMessages(t *testing.T, mailer *mail.MemoryMailer, n int) []mail.Message {
+	t.Helper()
+
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		messages := mailer.Messages()
+		if len(messages) >= n || time.Now().After(deadline) {
+			return messages
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+var resetLink = regexp.MustCompile(`http://forum\.test/reset-password\?token=(\S+)`)
+
+func TestPasswordResetFlow(t *testing.T) {
+	users := newFakeUsers()
+	users.InsertUser(models.User{Nickname: "alice", Email: "alice@example.com", Password: "old-hash"})
+	resets := &fakeTokens{tokens: make(map[string]time.Time)}
+	mailer := &mail.MemoryMailer{}
+
+	forgot := handlers.ForgotPasswordHandler(users, resets, mailer, config.Default().Account, "http://forum.test/")
+	hub := ws.NewHub(nil, nil, config.Default().WebSocket, config.Default().RateLimit, config.Account{})
+	reset := handlers.ResetPasswordHandler(resets, hub, config.Default().Account.Registration)
+
+	post := func(h http.Handler, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Unknown accounts get the same answer and no mail. The mail goes out
+	// after the answer, so the second request's message is waited for.
+	if rec := post(forgot, "/forgot-password", `{"identity":"nobody"}`); rec.Code != http.StatusOK {
+		t.Fatalf("forgot unknown status = %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	if rec := post(forgot, "/forgot-password", `{"identity":"alice"}`); rec.Code != http.StatusOK {
+		t.Fatalf("forgot status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	messages := waitForMessages(t, mailer, 1)
+	if len(messages) != 1 || messages[0].To != "alice@example.com" {
+		t.Fatalf("messages = %+v, want one to alice@example.com", messages)
+	}
+	match := resetLink.FindStringSubmatch(messages[0].Body)
+	if match == nil {
+		t.Fatalf("no reset link in body:\n%s", messages[0].Body)
+	}
+	token, _ := url.QueryUnescape(match[1])
+
+	if _, stored := resets.tokens[token]; stored {
+		t.Errorf("reset token stored in plain text")
+	}
+
+	body := `{"token":"` + token + `","password":"new-secret"}`
+	rec := post(reset, "/reset-password", body)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("reset status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+	if resets.password == "" || resets.password == "new-secret" {
+		t.Errorf("stored password = %q, want a bcrypt hash", resets.password)
+	}
+	if !strings.Contains(messages[0].Body, "expires in 1 hour.") {
+		t.Errorf("body does not state the lifetime:\n%s", messages[0].Body)
+	}
+	if !strings.Contains(rec.Header().Get("Set-Cookie"), "session_token=;") {
+		t.Errorf("reset did not clear the session cookie")
+	}
+
+	if rec := post(reset, "/reset-password", body); rec.Code != http.StatusBadRequest {
+		t.Errorf("reused token status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,256 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nyagooh/Real-time-forum.git/backend/config"
+	"github.com/nyagooh/Real-time-forum.git/backend/handlers"
+	"github.com/nyagooh/Real-time-forum.git/backend/middleware"
+	"github.com/nyagooh/Real-time-forum.git/backend/models"
+)
+
+// fakePosts is an in-memory PostStore holding posts by ID with their
+// authors, and comments in thread order. Edits are counted rather than kept
+// as revisions.
+type fakePosts struct {
+	posts    map[int]*models.Post
+	authors  map[int]int
+	comments []models.Comment
+	edits    int
+}
+
+func newFakePosts() *fakePosts {
+	return &fakePosts{posts: make(map[int]*models.Post), authors: make(map[int]int)}
+}
+
+func (f *fakePosts) InsertPost(userID int, post *models.Post) (int64, error) {
+	post.ID = len(f.posts) + 1
+	f.posts[post.ID], f.authors[post.ID] = post, userID
+	return int64(post.ID), nil
+}
+
+func (f *fakePosts) GetAllPosts(category string) ([]*models.Post, error) { return nil, nil }
+
+func (f *fakePosts) AddComment(postID, userID, parentID int, post *models.Post) error {
+	if _, _, err := f.GetPost(postID); err != nil {
+		return err
+	}
+	c := models.Comment{ID: len(f.comments) + 1, PostID: postID, ParentID: parentID, UserID: userID, Content: post.Content,
+		CreatedAt: time.Now().Format(time.RFC3339)}
+	c.Path = fmt.Sprintf("%010d", c.ID)
+	if parentID != 0 {
+		parent, err := f.GetComment(parentID)
+		if err != nil {
+			return err
+		}
+		c.Depth, c.Path = parent.Depth+1, parent.Path+"/"+c.Path
+	}
+	f.comments = append(f.comments, c)
+	slices.SortFunc(f.comments, func(a, b models.Comment) int { return strings.Compare(a.Path, b.Path) })
+	post.Comments = []models.Comment{c}
+	return nil
+}
+
+func (f *fakePosts) ListComments(postID, parentID, levels int) ([]models.Comment, error) {
+	prefix := ""
+	if parentID != 0 {
+		parent, err := f.GetComment(parentID)
+		if err != nil {
+			return nil, err
+		}
+		prefix = parent.Path + "/"
+	}
+	var list []models.Comment
+	for _, c := range f.comments {
+		if c.PostID == postID && strings.HasPrefix(c.Path, prefix) {
+			list = append(list, c)
+		}
+	}
+	return list, nil
+}
+
+func (f *fakePosts) GetPost(postID int) (*models.Post, int, error) {
+	post, ok := f.posts[postID]
+	if !ok || post.Deleted {
+		return nil, 0, sql.ErrNoRows
+	}
+	copied := *post
+	return &copied, f.authors[postID], nil
+}
+
+func (f *fakePosts) UpdatePost(postID, editorID int, post *models.Post) error {
+	f.edits++
+	post.Edited = true
+	f.posts[postID] = post
+	return nil
+}
+
+func (f *fakePosts) ListPostRevisions(postID int) ([]models.PostRevision, error) {
+	return nil, sql.ErrNoRows
+}
+
+func (f *fakePosts) GetComment(commentID int) (*models.Comment, error) {
+	for _, c := range f.comments {
+		if c.ID == commentID && !c.Deleted {
+			return &c, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+// comment returns the stored comment, deleted or not.
+func (f *fakePosts) comment(commentID int) *models.Comment {
+	for i := range f.comments {
+		if f.comments[i].ID == commentID {
+			return &f.comments[i]
+		}
+	}
+	return nil
+}
+
+func (f *fakePosts) UpdateComment(comment *models.Comment) error {
+	if _, err := f.GetComment(comment.ID); err != nil {
+		return err
+	}
+	comment.Edited = true
+	*f.comment(comment.ID) = *comment
+	return nil
+}
+
+func (f *fakePosts) DeletePost(postID, userID int) error {
+	if _, _, err := f.GetPost(postID); err != nil {
+		return err
+	}
+	f.posts[postID].Deleted = true
+	return nil
+}
+
+func (f *fakePosts) RestorePost(postID int) error {
+	post, ok := f.posts[postID]
+	if !ok || !post.Deleted {
+		return sql.ErrNoRows
+	}
+	post.Deleted = false
+	return nil
+}
+
+func (f *fakePosts) DeleteComment(commentID, userID int) error {
+	if _, err := f.GetComment(commentID); err != nil {
+		return err
+	}
+	f.comment(commentID).Deleted = true
+	return nil
+}
+
+func (f *fakePosts) RestoreComment(commentID int) error { return sql.ErrNoRows }
+
+func (f *fakePosts) PurgeDeleted(cutoff time.Time) (int, int, error) { return 0, 0, nil }
+
+// editPost sends a multipart edit as the given user.
+func editPost(t *testing.T, handler http.Handler, userID int, fields map[string]string) (int, map[string]any) {
+	t.Helper()
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	for name, value := range fields {
+		form.WriteField(name, value)
+	}
+	form.Close()
+
+	req := httptest.NewRequest(http.MethodPut, "/posts/edit", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req = req.WithContext(context.WithValue(req.Context(), middleware.UserIDKey, userID))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	var resp map[string]any
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	return rec.Code, resp
+}
+
+func TestEditPostHandler(t *testing.T) {
+	posts := newFakePosts()
+	posts.InsertPost(1, &models.Post{Title: "Hello", Content: "First words", Category: []string{"general"}, ImageURL: "frontend/assets/uploads/1.png"})
+	roles := fakeRoles{3: models.RoleModerator}
+	edit := handlers.EditPostHandler(posts, roles, config.Default().Uploads)
+
+	tests := []struct {
+		name       string
+		user       int
+		fields     map[string]string
+		wantStatus int
+		wantEdits  int
+	}{
+		{"Missing id", 1, map[string]string{"title": "Hi", "content": "x"}, http.StatusBadRequest, 0},
+		{"Unknown post", 1, map[string]string{"id": "9", "title": "Hi", "content": "x"}, http.StatusNotFound, 0},
+		{"Someone else's post", 2, map[string]string{"id": "1", "title": "Mine now", "content": "x"}, http.StatusForbidden, 0},
+		{"Empty content", 1, map[string]string{"id": "1", "title": "Hello", "content": "  "}, http.StatusBadRequest, 0},
+		{"No change", 1, map[string]string{"id": "1", "title": "Hello", "content": "First words", "categories": `["general"]`}, http.StatusOK, 0},
+		{"Author edit", 1, map[string]string{"id": "1", "title": "Hello", "content": "Second words", "categories": `["general"]`}, http.StatusOK, 1},
+		{"Moderator edit", 3, map[string]string{"id": "1", "title": "Hello", "content": "Tidied words", "categories": `["general"]`, "removeImage": "true"}, http.StatusOK, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if status, resp := editPost(t, edit, tt.user, tt.fields); status != tt.wantStatus {
+				t.Errorf("status = %d, want %d: %v", status, tt.wantStatus, resp)
+			}
+			if posts.edits != tt.wantEdits {
+				t.Errorf("edits = %d, want %d", posts.edits, tt.wantEdits)
+			}
+		})
+	}
+
+	post := posts.posts[1]
+	if post.Content != "Tidied words" || post.ImageURL != "" || !post.Edited {
+		t.Errorf("post after edits = %+v", post)
+	}
+}
+
+func TestDeleteAndRestorePost(t *testing.T) {
+	posts := newFakePosts()
+	posts.InsertPost(1, &models.Post{Title: "First"})
+	posts.InsertPost(1, &models.Post{Title: "Second"})
+	roles := fakeRoles{3: models.RoleModerator}
+	remove := handlers.DeletePostHandler(posts, roles)
+	restore := handlers.RestorePostHandler(posts)
+
+	tests := []struct {
+		name       string
+		handler    http.Handler
+		user       int
+		method     string
+		body       string
+		wantStatus int
+	}{
+		{"Wrong method", remove, 1, http.MethodPost, `{"id":1}`, http.StatusMethodNotAllowed},
+		{"Missing id", remove, 1, http.MethodDelete, `{}`, http.StatusBadRequest},
+		{"Someone else's post", remove, 2, http.MethodDelete, `{"id":1}`, http.StatusForbidden},
+		{"Author deletes", remove, 1, http.MethodDelete, `{"id":1}`, http.StatusOK},
+		{"Already deleted", remove, 1, http.MethodDelete, `{"id":1}`, http.StatusNotFound},
+		{"Moderator deletes", remove, 3, http.MethodDelete, `{"id":2}`, http.StatusOK},
+		{"Restore", restore, 3, http.MethodPost, `{"id":1}`, http.StatusOK},
+		{"Restore a live post", restore, 3, http.MethodPost, `{"id":1}`, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if status, resp := as(tt.handler, tt.user, tt.method, "/posts/delete", tt.body); status != tt.wantStatus {
+				t.Errorf("status = %d, want %d: %v", status, tt.wantStatus, resp)
+			}
+		})
+	}
+
+	if posts.posts[1].Deleted || !posts.posts[2].Deleted {
+		t.Errorf("deleted = %v, %v, want post 1 restored and post 2 deleted", posts.posts[1].Deleted, posts.posts[2].Deleted)
+	}
+}
@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nyagooh/Real-time-forum.git/backend/config"
+	"github.com/nyagooh/Real-time-forum.git/backend/database"
+	"github.com/nyagooh/Real-time-forum.git/backend/handlers"
+	"github.com/nyagooh/Real-time-forum.git/backend/middleware"
+	"github.com/nyagooh/Real-time-forum.git/backend/models"
+	ws "github.com/nyagooh/Real-time-forum.git/backend/websockets"
+)
+
+// fakeReports is an in-memory ReportStore. Post 1 is by user 2; there is no
+// other content.
+type fakeReports struct {
+	reports []models.Report
+	claimed map[int]int
+}
+
+func (f *fakeReports) ReportedUser(targetType string, targetID, reporterID int) (int, error) {
+	if targetType == models.ReportPost && targetID == 1 {
+		return 2, nil
+	}
+	return 0, sql.ErrNoRows
+}
+
+func (f *fakeReports) CreateReport(reporterID int, targetType string, targetID, reportedUserID int, reason, details string) (int, error) {
+	for _, report := range f.reports {
+		if report.TargetID == targetID && report.Reporter == "1" {
+			return 0, &database.DuplicateError{Field: "target_id"}
+		}
+	}
+	f.reports = append(f.reports, models.Report{
+		ID: len(f.reports) + 1, TargetType: targetType, TargetID: targetID, Reporter: "1",
+		Reason: reason, Details: details, Status: models.ReportOpen, CreatedAt: time.Now(),
+	})
+	return len(f.reports), nil
+}
+
+func (f *fakeReports) GetReport(id int) (*models.Report, error) {
+	if id < 1 || id > len(f.reports) {
+		return nil, sql.ErrNoRows
+	}
+	return &f.reports[id-1], nil
+}
+
+func (f *fakeReports) ListReports(status string, limit, offset int) ([]models.Report, error) {
+	var list []models.Report
+	for _, report := range f.reports {
+		if report.Status == status {
+			list = append(list, report)
+		}
+	}
+	return list, nil
+}
+
+func (f *fakeReports) ClaimReport(id, moderatorID int) error {
+	report, err := f.GetReport(id)
+	switch {
+	case err != nil:
+		return err
+	case report.Status != models.ReportOpen:
+		return database.ErrReportClosed
+	case f.claimed[id] != 0 && f.claimed[id] != moderatorID:
+		return database.ErrReportClaimed
+	}
+	f.claimed[id] = moderatorID
+	return nil
+}
+
+func (f *fakeReports) ResolveReport(id, moderatorID int, status, resolution string) error {
+	if err := f.ClaimReport(id, moderatorID); err != nil {
+		return err
+	}
+	f.reports[id-1].Status = status
+	f.reports[id-1].Resolution = resolution
+	return nil
+}
+
+// as sends body to handler as the given user.
+func as(handler http.Handler, userID int, method, target, body string) (int, map[string]any) {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req = req.WithContext(context.WithValue(req.Context(), middleware.UserIDKey, userID))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	var resp map[string]any
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	return rec.Code, resp
+}
+
+func TestReportFlow(t *testing.T) {
+	reports := &fakeReports{claimed: make(map[int]int)}
+	roles := fakeRoles{3: models.RoleModerator, 4: models.RoleModerator}
+	hub := ws.NewHub(nil, nil, config.Default().WebSocket, config.Default().RateLimit, config.Account{})
+
+	// A moderator's open chat connection, fed directly.
+	moderator := &ws.Client{Username: "mod", Send: make(chan []byte, 4)}
+	hub.Clients["mod"] = map[*ws.Client]bool{moderator: true}
+
+	report := handlers.ReportHandler(reports, staffRoles{roles}, hub, models.ReportPost)
+	list := handlers.ListReportsHandler(reports)
+	claim := handlers.ClaimReportHandler(reports, staffRoles{roles}, hub)
+	resolve := handlers.ResolveReportHandler(reports, staffRoles{roles}, hub)
+
+	tests := []struct {
+		name       string
+		user       int
+		body       string
+		wantStatus int
+	}{
+		{"Unknown reason", 1, `{"id":1,"reason":"boring"}`, http.StatusBadRequest},
+		{"Other without details", 1, `{"id":1,"reason":"other","details":"  "}`, http.StatusBadRequest},
+		{"Unknown post", 1, `{"id":9,"reason":"spam"}`, http.StatusNotFound},
+		{"Own post", 2, `{"id":1,"reason":"spam"}`, http.StatusBadRequest},
+		{"Valid report", 1, `{"id":1,"reason":"spam","details":"buy now links"}`, http.StatusCreated},
+		{"Reported twice", 1, `{"id":1,"reason":"hate"}`, http.StatusConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if status, resp := as(report, tt.user, http.MethodPost, "/report-post", tt.body); status != tt.wantStatus {
+				t.Errorf("status = %d, want %d: %v", status, tt.wantStatus, resp)
+			}
+		})
+	}
+
+	select {
+	case frame := <-moderator.Send:
+		if !strings.Contains(string(frame), `"type":"report"`) || !strings.Contains(string(frame), `"event":"created"`) {
+			t.Errorf("moderator frame = %s", frame)
+		}
+	default:
+		t.Error("moderator was not told about the new report")
+	}
+
+	if status, resp := as(list, 3, http.MethodGet, "/moderation/reports", ""); status != http.StatusOK || len(resp["reports"].([]any)) != 1 {
+		t.Fatalf("list status = %d: %v", status, resp)
+	}
+	if status, _ := as(list, 3, http.MethodGet, "/moderation/reports?status=pending", ""); status != http.StatusBadRequest {
+		t.Errorf("list with unknown status = %d, want %d", status, http.StatusBadRequest)
+	}
+
+	if status, resp := as(claim, 3, http.MethodPost, "/moderation/reports/claim", `{"id":1}`); status != http.StatusOK {
+		t.Fatalf("claim status = %d: %v", status, resp)
+	}
+	if status, _ := as(claim, 4, http.MethodPost, "/moderation/reports/claim", `{"id":1}`); status != http.StatusConflict {
+		t.Errorf("claim of a claimed report status = %d, want %d", status, http.StatusConflict)
+	}
+	if status, _ := as(resolve, 3, http.MethodPost, "/moderation/reports/resolve", `{"id":1,"status":"open"}`); status != http.StatusBadRequest {
+		t.Errorf("resolve to open status = %d, want %d", status, http.StatusBadRequest)
+	}
+	if status, resp := as(resolve, 3, http.MethodPost, "/moderation/reports/resolve", `{"id":1,"status":"dismissed","resolution":"not spam"}`); status != http.StatusOK {
+		t.Fatalf("resolve status = %d: %v", status, resp)
+	}
+	if status, _ := as(resolve, 3, http.MethodPost, "/moderation/reports/resolve", `{"id":1,"status":"actioned"}`); status != http.StatusConflict {
+		t.Errorf("second resolve status = %d, want %d", status, http.StatusConflict)
+	}
+	if reports.reports[0].Status != models.ReportDismissed || reports.reports[0].Resolution != "not spam" {
+		t.Errorf("report = %+v", reports.reports[0])
+	}
+}
+
+// staffRoles lists the moderators of a fakeRoles by the nickname "mod".
+type staffRoles struct {
+	fakeRoles
+}
+
+func (s staffRoles) ListStaff() ([]models.StaffMember, error) {
+	return []models.StaffMember{{ID: 3, Nickname: "mod", Role: models.RoleModerator}}, nil
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/nyagooh/Real-time-forum.git/backend/database"
+	"github.com/nyagooh/Real-time-forum.git/backend/handlers"
+	"github.com/nyagooh/Real-time-forum.git/backend/models"
+)
+
+// fakeRoles is an in-memory RoleStore keyed by user ID.
+type fakeRoles map[int]models.Role
+
+func (f fakeRoles) GetUserRole(userID int) (models.Role, error) {
+	if role, ok := f[userID]; ok {
+		return role, nil
+	}
+	return models.RoleUser, nil
+}
+
+func (f fakeRoles) SetUserRole(userID int, role models.Role) error {
+	if f[userID] == models.RoleAdmin && role != models.RoleAdmin {
+		admins := 0
+		for _, r := range f {
+			if r == models.RoleAdmin {
+				admins++
+			}
+		}
+		if admins <= 1 {
+			return database.ErrLastAdmin
+		}
+	}
+	f[userID] = role
+	return nil
+}
+
+func (f fakeRoles) BootstrapAdmin(nickname string) (int, error) { return 0, database.ErrAdminExists }
+
+func (f fakeRoles) ListStaff() ([]models.StaffMember, error) { return nil, nil }
+
+func TestSetRoleHandler(t *testing.T) {
+	users := newFakeUsers()
+	users.InsertUser(models.User{Nickname: "ada", Email: "ada@example.com"})
+	roles := fakeRoles{1: models.RoleAdmin}
+	handler := handlers.SetRoleHandler(users, roles)
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantRole   models.Role
+	}{
+		{"Unknown role", `{"nickname":"ada","role":"owner"}`, http.StatusBadRequest, models.RoleAdmin},
+		{"Unknown user", `{"nickname":"nobody","role":"moderator"}`, http.StatusNotFound, models.RoleAdmin},
+		{"Demote the last admin", `{"nickname":"ada","role":"user"}`, http.StatusConflict, models.RoleAdmin},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if status, resp := post(t, handler, "/admin/roles", tt.body); status != tt.wantStatus {
+				t.Errorf("status = %d, want %d: %v", status, tt.wantStatus, resp)
+			}
+			if roles[1] != tt.wantRole {
+				t.Errorf("role = %q, want %q", roles[1], tt.wantRole)
+			}
+		})
+	}
+
+	roles[2] = models.RoleAdmin
+	if status, resp := post(t, handler, "/admin/roles", `{"nickname":"ada","role":"moderator"}`); status != http.StatusOK {
+		t.Fatalf("demote with another admin status = %d: %v", status, resp)
+	}
+	if roles[1] != models.RoleModerator {
+		t.Errorf("role = %q, want moderator", roles[1])
+	}
+}
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nyagooh/Real-time-forum.git/backend/config"
+	"github.com/nyagooh/Real-time-forum.git/backend/handlers"
+	"github.com/nyagooh/Real-time-forum.git/backend/middleware"
+	ws "github.com/nyagooh/Real-time-forum.git/backend/websockets"
+)
+
+// asSession sends body to handler as user 1 signed in with token.
+func asSession(handler http.Handler, method, token, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "/sessions", strings.NewReader(body))
+	req.AddCookie(&http.Cookie{Name: "session_token", Value: token})
+	ctx := context.WithValue(req.Context(), middleware.UserIDKey, 1)
+	req = req.WithContext(context.WithValue(ctx, middleware.SessionTokenKey, token))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestSessionManagement(t *testing.T) {
+	sessions := newFakeSessions()
+	expires := time.Now().Add(time.Hour)
+	sessions.InsertSession(1, "laptop", expires, expires, "", "")
+	sessions.InsertSession(1, "phone", expires, expires, "", "")
+	sessions.InsertSession(1, "tablet", expires, expires, "", "")
+	sessions.InsertSession(2, "someone-else", expires, expires, "", "")
+
+	hub := ws.NewHub(nil, nil, config.Default().WebSocket, config.Default().RateLimit, config.Account{})
+	list := handlers.ListSessionsHandler(sessions)
+	revoke := handlers.RevokeSessionHandler(sessions, hub)
+	revokeOthers := handlers.RevokeOtherSessionsHandler(sessions, hub)
+
+	rec := asSession(list, http.MethodGet, "laptop", "")
+	if rec.Code != http.StatusOK || strings.Count(rec.Body.String(), `"current":true`) != 1 {
+		t.Fatalf("list status = %d, body = %s", rec.Code, rec.Body)
+	}
+
+	if rec := asSession(revoke, http.MethodPost, "laptop", `{"id":4}`); rec.Code != http.StatusNotFound {
+		t.Errorf("revoking another user's session status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+
+	rec = asSession(revoke, http.MethodPost, "laptop", `{"id":2}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("revoke status = %d: %s", rec.Code, rec.Body)
+	}
+	if _, ok := sessions.tokens["phone"]; ok {
+		t.Error("revoked session still valid")
+	}
+	if strings.Contains(rec.Header().Get("Set-Cookie"), "session_token=;") {
+		t.Error("revoking another device cleared this device's cookie")
+	}
+
+	rec = asSession(revokeOthers, http.MethodPost, "laptop", "")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"revoked":1`) {
+		t.Fatalf("revoke others status = %d, body = %s", rec.Code, rec.Body)
+	}
+	if len(sessions.tokens) != 2 || sessions.tokens["laptop"] != 1 || sessions.tokens["someone-else"] != 2 {
+		t.Errorf("sessions left = %v, want laptop and someone-else", sessions.tokens)
+	}
+
+	// Revoking the current session signs this device out.
+	rec = asSession(revoke, http.MethodPost, "laptop", `{"id":1}`)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Header().Get("Set-Cookie"), "session_token=;") {
+		t.Errorf("revoke current status = %d, cookies = %v", rec.Code, rec.Header()["Set-Cookie"])
+	}
+}
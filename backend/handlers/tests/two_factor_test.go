@@ -0,0 +1,227 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nyagooh/Real-time-forum.git/backend/config"
+	"github.com/nyagooh/Real-time-forum.git/backend/database"
+	"github.com/nyagooh/Real-time-forum.git/backend/handlers"
+	"github.com/nyagooh/Real-time-forum.git/backend/mail"
+	"github.com/nyagooh/Real-time-forum.git/backend/middleware"
+	"github.com/nyagooh/Real-time-forum.git/backend/models"
+	"github.com/nyagooh/Real-time-forum.git/backend/totp"
+	"github.com/nyagooh/Real-time-forum.git/backend/utils"
+)
+
+// fakeTwoFactor is an in-memory TwoFactorStore for a single user.
+type fakeTwoFactor struct {
+	tf       *models.TwoFactor
+	recovery map[string]bool // hash -> used
+	pending  map[string]int  // hash -> attempts
+}
+
+func newFakeTwoFactor() *fakeTwoFactor {
+	return &fakeTwoFactor{recovery: make(map[string]bool), pending: make(map[string]int)}
+}
+
+func (f *fakeTwoFactor) GetTwoFactor(userID int) (*models.TwoFactor, error) { return f.tf, nil }
+
+func (f *fakeTwoFactor) StartTwoFactor(userID int, secret string) error {
+	f.tf = &models.TwoFactor{Secret: secret}
+	return nil
+}
+
+func (f *fakeTwoFactor) EnableTwoFactor(userID int, step int64, recoveryHashes []string) error {
+	f.tf.Enabled, f.tf.LastStep = true, step
+	f.recovery = make(map[string]bool)
+	for _, hash := range recoveryHashes {
+		f.recovery[hash] = false
+	}
+	return nil
+}
+
+func (f *fakeTwoFactor) DisableTwoFactor(userID int) error {
+	f.tf, f.recovery = nil, make(map[string]bool)
+	return nil
+}
+
+func (f *fakeTwoFactor) UseTOTPStep(userID int, step int64) (bool, error) {
+	if step <= f.tf.LastStep {
+		return false, nil
+	}
+	f.tf.LastStep = step
+	return true, nil
+}
+
+func (f *fakeTwoFactor) UseRecoveryCode(userID int, codeHash string) (bool, error) {
+	used, ok := f.recovery[codeHash]
+	if !ok || used {
+		return false, nil
+	}
+	f.recovery[codeHash] = true
+	return true, nil
+}
+
+func (f *fakeTwoFactor) CountRecoveryCodes(userID int) (int, error) {
+	n := 0
+	for _, used := range f.recovery {
+		if !used {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (f *fakeTwoFactor) CreatePendingLogin(userID int, tokenHash string, expiresAt time.Time) error {
+	f.pending[tokenHash] = 0
+	return nil
+}
+
+func (f *fakeTwoFactor) CheckPendingLogin(tokenHash string, maxAttempts int) (int, error) {
+	attempts, ok := f.pending[tokenHash]
+	if !ok || attempts >= maxAttempts {
+		return 0, database.ErrInvalidToken
+	}
+	f.pending[tokenHash] = attempts + 1
+	return 1, nil
+}
+
+func (f *fakeTwoFactor) DeletePendingLogin(tokenHash string) error {
+	delete(f.pending, tokenHash)
+	return nil
+}
+
+// post sends body to handler as the signed-in user 1 and decodes the reply.
+func post(t *testing.T, handler http.Handler, path, body string) (int, map[string]any) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	req = req.WithContext(context.WithValue(req.Context(), middleware.UserIDKey, 1))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	var resp map[string]any
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	return rec.Code, resp
+}
+
+func TestTwoFactorFlow(t *testing.T) {
+	users := newFakeUsers()
+	hash, _ := utils.HashPassword("correct-horse")
+	users.InsertUser(models.User{Nickname: "erin", Email: "erin@example.com", Password: hash})
+	twoFactor := newFakeTwoFactor()
+	cfg := config.Default()
+
+	enroll := handlers.EnrollTwoFactorHandler(users, twoFactor, cfg.Account.TwoFactor)
+	confirm := handlers.ConfirmTwoFactorHandler(twoFactor)
+	disable := handlers.DisableTwoFactorHandler(twoFactor)
+	attempts := newFakeAttempts()
+	login := handlers.LoginHandler(users, newFakeSessions(), twoFactor, attempts, &mail.MemoryMailer{}, cfg.Session, cfg.Account, "http://forum.test")
+	login2fa := handlers.LoginTwoFactorHandler(users, newFakeSessions(), twoFactor, attempts, &mail.MemoryMailer{}, cfg.Session, cfg.Account, "http://forum.test")
+
+	status, resp := post(t, enroll, "/2fa/enroll", "")
+	if status != http.StatusOK {
+		t.Fatalf("enroll status = %d: %v", status, resp)
+	}
+	secret := resp["secret"].(string)
+	if uri := resp["otpauthUri"].(string); !strings.HasPrefix(uri, "otpauth://totp/") || !strings.Contains(uri, "secret="+secret) {
+		t.Errorf("otpauthUri = %q", uri)
+	}
+
+	if status, _ := post(t, confirm, "/2fa/confirm", `{"code":"000000"}`); status != http.StatusBadRequest {
+		t.Errorf("confirm with wrong code status = %d", status)
+	}
+
+	// Use the previous step so the login below can use the current one.
+	code, _ := totp.Code(secret, time.Now().Add(-totp.Period))
+	status, resp = post(t, confirm, "/2fa/confirm", `{"code":"`+code+`"}`)
+	if status != http.StatusOK || !twoFactor.tf.Enabled {
+		t.Fatalf("confirm status = %d, enabled = %v: %v", status, twoFactor.tf.Enabled, resp)
+	}
+	recoveryCodes := resp["recoveryCodes"].([]any)
+	if len(recoveryCodes) != 10 {
+		t.Fatalf("got %d recovery codes, want 10", len(recoveryCodes))
+	}
+	if _, plain := twoFactor.recovery[recoveryCodes[0].(string)]; plain {
+		t.Fatal("recovery codes stored in plain text")
+	}
+
+	if status, _ := post(t, enroll, "/2fa/enroll", ""); status != http.StatusConflict {
+		t.Errorf("enroll while enabled status = %d, want %d", status, http.StatusConflict)
+	}
+
+	// The password alone only earns a pending token.
+	rec := httptest.NewRecorder()
+	login.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/login",
+		strings.NewReader(`{"identity":"erin","password":"correct-horse"}`)))
+	if strings.Contains(rec.Header().Get("Set-Cookie"), "session_token=") {
+		t.Fatal("password login set a session cookie with 2FA enabled")
+	}
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	pending, _ := resp["pendingToken"].(string)
+	if resp["twoFactorRequired"] != true || pending == "" {
+		t.Fatalf("login response = %v, want twoFactorRequired and pendingToken", resp)
+	}
+	if len(attempts.log) != 0 {
+		t.Errorf("password step logged %+v, want nothing until the second factor", attempts.log)
+	}
+
+	if status, _ := post(t, login2fa, "/login/2fa", `{"pendingToken":"`+pending+`","code":"000000"}`); status != http.StatusUnauthorized {
+		t.Errorf("wrong code status = %d, want %d", status, http.StatusUnauthorized)
+	}
+	if failures, _ := attempts.AccountFailures(1); failures.Count != 1 {
+		t.Errorf("account failures after a wrong code = %d, want 1", failures.Count)
+	}
+
+	code, _ = totp.Code(secret, time.Now())
+	rec = httptest.NewRecorder()
+	login2fa.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/login/2fa",
+		strings.NewReader(`{"pendingToken":"`+pending+`","code":"`+code+`"}`)))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Header().Get("Set-Cookie"), "session_token=") {
+		t.Fatalf("second step status = %d, cookie = %q", rec.Code, rec.Header().Get("Set-Cookie"))
+	}
+	if n := len(attempts.log); n != 2 || !attempts.log[n-1].Success {
+		t.Errorf("login attempts = %+v, want the failed code then the success", attempts.log)
+	}
+
+	if status, _ := post(t, login2fa, "/login/2fa", `{"pendingToken":"`+pending+`","code":"`+code+`"}`); status != http.StatusUnauthorized {
+		t.Errorf("reused pending token status = %d, want %d", status, http.StatusUnauthorized)
+	}
+
+	// A code that was already accepted cannot disable 2FA; a recovery code can.
+	if status, _ := post(t, disable, "/2fa/disable", `{"code":"`+code+`"}`); status != http.StatusBadRequest {
+		t.Errorf("disable with replayed code status = %d, want %d", status, http.StatusBadRequest)
+	}
+	recovery := strings.ToUpper(recoveryCodes[0].(string))
+	if status, resp := post(t, disable, "/2fa/disable", `{"recoveryCode":"`+recovery+`"}`); status != http.StatusOK || twoFactor.tf != nil {
+		t.Errorf("disable with recovery code status = %d: %v", status, resp)
+	}
+}
+
+func TestLoginTwoFactorAttemptLimit(t *testing.T) {
+	users := newFakeUsers()
+	users.InsertUser(models.User{Nickname: "finn", Email: "finn@example.com"})
+	twoFactor := newFakeTwoFactor()
+	secret, _ := totp.GenerateSecret()
+	twoFactor.tf = &models.TwoFactor{Secret: secret, Enabled: true}
+	twoFactor.CreatePendingLogin(1, utils.HashToken("pending"), time.Now().Add(time.Minute))
+
+	cfg := config.Default()
+	cfg.Account.TwoFactor.MaxAttempts = 2
+	login2fa := handlers.LoginTwoFactorHandler(users, newFakeSessions(), twoFactor, newFakeAttempts(), &mail.MemoryMailer{}, cfg.Session, cfg.Account, "http://forum.test")
+
+	for range 2 {
+		post(t, login2fa, "/login/2fa", `{"pendingToken":"pending","code":"000000"}`)
+	}
+
+	code, _ := totp.Code(secret, time.Now())
+	status, resp := post(t, login2fa, "/login/2fa", `{"pendingToken":"pending","code":"`+code+`"}`)
+	if status != http.StatusUnauthorized || resp["message"] != "login expired, sign in again" {
+		t.Errorf("status = %d, resp = %v; want the pending login used up", status, resp)
+	}
+}
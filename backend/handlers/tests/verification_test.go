@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nyagooh/Real-time-forum.git/backend/config"
+	"github.com/nyagooh/Real-time-forum.git/backend/handlers"
+	"github.com/nyagooh/Real-time-forum.git/backend/mail"
+	"github.com/nyagooh/Real-time-forum.git/backend/middleware"
+	"github.com/nyagooh/Real-time-forum.git/backend/models"
+)
+
+var verifyLink = regexp.MustCompile(`http://forum\.test/verify-email\?token=(\S+)`)
+
+func TestEmailVerificationFlow(t *testing.T) {
+	users := newFakeUsers()
+	users.InsertUser(models.User{Nickname: "dana", Email: "dana@example.com"})
+	tokens := &fakeTokens{tokens: make(map[string]time.Time)}
+	mailer := &mail.MemoryMailer{}
+
+	resend := handlers.ResendVerificationHandler(users, tokens, mailer, config.Default().Account, "http://forum.test")
+	verify := handlers.VerifyEmailHandler(tokens)
+
+	req := httptest.NewRequest(http.MethodPost, "/verify-email/resend", nil)
+	req = req.WithContext(context.WithValue(req.Context(), middleware.UserIDKey, 1))
+	rec := httptest.NewRecorder()
+	resend.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("resend status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+
+	messages := mailer.Messages()
+	if len(messages) != 1 || messages[0].To != "dana@example.com" {
+		t.Fatalf("messages = %+v, want one to dana@example.com", messages)
+	}
+	match := verifyLink.FindStringSubmatch(messages[0].Body)
+	if match == nil {
+		t.Fatalf("no verification link in body:\n%s", messages[0].Body)
+	}
+	token, _ := url.QueryUnescape(match[1])
+
+	body := `{"token":"` + token + `"}`
+	rec = httptest.NewRecorder()
+	verify.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/verify-email", strings.NewReader(body)))
+	if rec.Code != http.StatusOK || !tokens.verified {
+		t.Fatalf("verify status = %d, verified = %v", rec.Code, tokens.verified)
+	}
+
+	rec = httptest.NewRecorder()
+	verify.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/verify-email", strings.NewReader(body)))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("reused token status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+
+	// Verified accounts have nothing to resend.
+	users.verified = true
+	rec = httptest.NewRecorder()
+	resend.ServeHTTP(rec, req)
+	if rec.Code != http.StatusConflict {
+		t.Errorf("resend when verified status = %d, want %d", rec.Code, http.StatusConflict)
+	}
+}
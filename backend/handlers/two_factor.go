@@ -0,0 +1,326 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/nyagooh/Real-time-forum.git/backend/config"
+	"github.com/nyagooh/Real-time-forum.git/backend/database"
+	"github.com/nyagooh/Real-time-forum.git/backend/mail"
+	"github.com/nyagooh/Real-time-forum.git/backend/middleware"
+	"github.com/nyagooh/Real-time-forum.git/backend/models"
+	"github.com/nyagooh/Real-time-forum.git/backend/totp"
+	"github.com/nyagooh/Real-time-forum.git/backend/utils"
+)
+
+// recoveryCodeCount is how many recovery codes confirming 2FA hands out.
+const recoveryCodeCount = 10
+
+// secondFactor is a TOTP code or, when the authenticator is lost, one of the
+// recovery codes.
+type secondFactor struct {
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recoveryCode"`
+}
+
+// LoginTwoFactorHandler finishes a login that LoginHandler left pending,
+// trading the pending token and a second factor for the session cookie.
+// Wrong codes count as failed logins towards the account's lockout; the
+// login is only recorded as a success here.
+func LoginTwoFactorHandler(users database.UserStore, sessions database.SessionStore, twoFactor database.TwoFactorStore, attempts database.LoginAttemptStore, mailer mail.Mailer, sessionCfg config.Session, account config.Account, publicURL string) http.HandlerFunc {
+	throttle := account.LoginThrottle
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			handleError(w, fmt.Errorf("method not allowed"), http.StatusMethodNotAllowed)
+			return
+		}
+
+		var req struct {
+			PendingToken string `json:"pendingToken"`
+			Remember     bool   `json:"remember"`
+			secondFactor
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.PendingToken == "" {
+			slog.WarnContext(r.Context(), "Invalid two-factor login request", "err", err)
+			handleError(w, fmt.Errorf("pendingToken and a code are required"), http.StatusBadRequest)
+			return
+		}
+
+		if err := ipThrottle(r, attempts, throttle); err != nil {
+			handleThrottleError(w, r, err)
+			return
+		}
+
+		pendingHash := utils.HashToken(req.PendingToken)
+		id, err := twoFactor.CheckPendingLogin(pendingHash, account.TwoFactor.MaxAttempts)
+		if errors.Is(err, database.ErrInvalidToken) {
+			slog.WarnContext(r.Context(), "Two-factor login with unusable pending token")
+			handleError(w, fmt.Errorf("login expired, sign in again"), http.StatusUnauthorized)
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to check pending login", "err", err)
+			handleError(w, fmt.Errorf("server error"), http.StatusInternalServerError)
+			return
+		}
+
+		// A lock from failed codes holds here just as at the password step.
+		if err := accountThrottle(attempts, throttle, id); err != nil {
+			handleThrottleError(w, r, err)
+			return
+		}
+
+		ok, err := checkSecondFactor(twoFactor, id, req.secondFactor)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to check second factor", "user_id", id, "err", err)
+			handleError(w, fmt.Errorf("server error"), http.StatusInternalServerError)
+			return
+		}
+		if !ok {
+			slog.WarnContext(r.Context(), "Login failed: wrong second factor", "user_id", id)
+			recordLoginFailure(r, users, attempts, mailer, throttle, publicURL, id)
+			handleError(w, fmt.Errorf("invalid code"), http.StatusUnauthorized)
+			return
+		}
+
+		if err := twoFactor.DeletePendingLogin(pendingHash); err != nil {
+			slog.ErrorContext(r.Context(), "Failed to delete pending login", "user_id", id, "err", err)
+		}
+
+		nickname, err := users.GetUserByID(id)
+		var user models.UserIdentity
+		if err == nil {
+			user, _, err = users.GetUser(models.Credentials{Identity: nickname})
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to load user", "user_id", id, "err", err)
+			handleError(w, fmt.Errorf("server error"), http.StatusInternalServerError)
+			return
+		}
+
+		if startSession(w, r, sessions, sessionCfg, id, user, req.Remember) {
+			recordLoginSuccess(r, attempts, id)
+		}
+	}
+}
+
+// TwoFactorStatusHandler reports whether the signed-in user has 2FA on and
+// how many recovery codes are left.
+func TwoFactorStatusHandler(twoFactor database.TwoFactorStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			handleError(w, fmt.Errorf("method not allowed"), http.StatusMethodNotAllowed)
+			return
+		}
+
+		userID := r.Context().Value(middleware.UserIDKey).(int)
+
+		tf, err := twoFactor.GetTwoFactor(userID)
+		var left int
+		if err == nil && tf != nil && tf.Enabled {
+			left, err = twoFactor.CountRecoveryCodes(userID)
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to load two-factor settings", "user_id", userID, "err", err)
+			handleError(w, fmt.Errorf("server error"), http.StatusInternalServerError)
+			return
+		}
+
+		sendSuccessResponse(w, http.StatusOK, map[string]any{
+			"success":           true,
+			"enabled":           tf != nil && tf.Enabled,
+			"recoveryCodesLeft": left,
+		})
+	}
+}
+
+// EnrollTwoFactorHandler creates a new TOTP secret for the signed-in user and
+// returns it with the otpauth URI to scan. 2FA stays off until a code from
+// the app is confirmed.
+func EnrollTwoFactorHandler(users database.UserStore, twoFactor database.TwoFactorStore, twoFactorCfg config.TwoFactor) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			handleError(w, fmt.Errorf("method not allowed"), http.StatusMethodNotAllowed)
+			return
+		}
+
+		userID := r.Context().Value(middleware.UserIDKey).(int)
+
+		tf, err := twoFactor.GetTwoFactor(userID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to load two-factor settings", "user_id", userID, "err", err)
+			handleError(w, fmt.Errorf("server error"), http.StatusInternalServerError)
+			return
+		}
+		if tf != nil && tf.Enabled {
+			slog.WarnContext(r.Context(), "Two-factor enrollment while enabled", "user_id", userID)
+			handleError(w, fmt.Errorf("two-factor authentication is already enabled"), http.StatusConflict)
+			return
+		}
+
+		nickname, err := users.GetUserByID(userID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to load user", "user_id", userID, "err", err)
+			handleError(w, fmt.Errorf("server error"), http.StatusInternalServerError)
+			return
+		}
+
+		secret, err := totp.GenerateSecret()
+		if err == nil {
+			err = twoFactor.StartTwoFactor(userID, secret)
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to start two-factor enrollment", "user_id", userID, "err", err)
+			handleError(w, fmt.Errorf("server error"), http.StatusInternalServerError)
+			return
+		}
+
+		sendSuccessResponse(w, http.StatusOK, map[string]any{
+			"success":    true,
+			"secret":     secret,
+			"otpauthUri": totp.URI(twoFactorCfg.Issuer, nickname, secret),
+		})
+	}
+}
+
+// ConfirmTwoFactorHandler turns 2FA on once the user proves their app
+// produces codes for the enrolled secret, and returns the recovery codes.
+// They are shown this once; only their hashes are kept.
+func ConfirmTwoFactorHandler(twoFactor database.TwoFactorStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			handleError(w, fmt.Errorf("method not allowed"), http.StatusMethodNotAllowed)
+			return
+		}
+
+		userID := r.Context().Value(middleware.UserIDKey).(int)
+
+		var req struct {
+			Code string `json:"code"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Code == "" {
+			slog.WarnContext(r.Context(), "Invalid two-factor confirm request", "err", err)
+			handleError(w, fmt.Errorf("code is required"), http.StatusBadRequest)
+			return
+		}
+
+		tf, err := twoFactor.GetTwoFactor(userID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to load two-factor settings", "user_id", userID, "err", err)
+			handleError(w, fmt.Errorf("server error"), http.StatusInternalServerError)
+			return
+		}
+		if tf == nil || tf.Enabled {
+			slog.WarnContext(r.Context(), "Two-factor confirm without enrollment", "user_id", userID)
+			handleError(w, fmt.Errorf("no two-factor enrollment to confirm"), http.StatusConflict)
+			return
+		}
+
+		step, ok := totp.Verify(tf.Secret, req.Code, time.Now())
+		if !ok {
+			slog.WarnContext(r.Context(), "Two-factor confirm with wrong code", "user_id", userID)
+			handleError(w, fmt.Errorf("invalid code"), http.StatusBadRequest)
+			return
+		}
+
+		codes := make([]string, recoveryCodeCount)
+		hashes := make([]string, recoveryCodeCount)
+		for i := range codes {
+			if codes[i], err = utils.GenerateRecoveryCode(); err != nil {
+				slog.ErrorContext(r.Context(), "Failed to generate recovery code", "err", err)
+				handleError(w, fmt.Errorf("server error"), http.StatusInternalServerError)
+				return
+			}
+			hashes[i] = utils.HashToken(utils.NormalizeRecoveryCode(codes[i]))
+		}
+
+		if err := twoFactor.EnableTwoFactor(userID, step, hashes); err != nil {
+			slog.ErrorContext(r.Context(), "Failed to enable two-factor authentication", "user_id", userID, "err", err)
+			handleError(w, fmt.Errorf("server error"), http.StatusInternalServerError)
+			return
+		}
+
+		slog.InfoContext(r.Context(), "Two-factor authentication enabled", "user_id", userID)
+		sendSuccessResponse(w, http.StatusOK, map[string]any{
+			"success":       true,
+			"recoveryCodes": codes,
+		})
+	}
+}
+
+// DisableTwoFactorHandler turns 2FA off; it takes a current code or a
+// recovery code, so a hijacked session alone cannot do it.
+func DisableTwoFactorHandler(twoFactor database.TwoFactorStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			handleError(w, fmt.Errorf("method not allowed"), http.StatusMethodNotAllowed)
+			return
+		}
+
+		userID := r.Context().Value(middleware.UserIDKey).(int)
+
+		var req secondFactor
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			slog.WarnContext(r.Context(), "Invalid two-factor disable request", "err", err)
+			handleError(w, fmt.Errorf("failed to decode JSON: %v", err), http.StatusBadRequest)
+			return
+		}
+
+		tf, err := twoFactor.GetTwoFactor(userID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to load two-factor settings", "user_id", userID, "err", err)
+			handleError(w, fmt.Errorf("server error"), http.StatusInternalServerError)
+			return
+		}
+		if tf == nil || !tf.Enabled {
+			slog.WarnContext(r.Context(), "Two-factor disable while off", "user_id", userID)
+			handleError(w, fmt.Errorf("two-factor authentication is not enabled"), http.StatusConflict)
+			return
+		}
+
+		ok, err := checkSecondFactor(twoFactor, userID, req)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to check second factor", "user_id", userID, "err", err)
+			handleError(w, fmt.Errorf("server error"), http.StatusInternalServerError)
+			return
+		}
+		if !ok {
+			slog.WarnContext(r.Context(), "Two-factor disable with wrong code", "user_id", userID)
+			handleError(w, fmt.Errorf("invalid code"), http.StatusBadRequest)
+			return
+		}
+
+		if err := twoFactor.DisableTwoFactor(userID); err != nil {
+			slog.ErrorContext(r.Context(), "Failed to disable two-factor authentication", "user_id", userID, "err", err)
+			handleError(w, fmt.Errorf("server error"), http.StatusInternalServerError)
+			return
+		}
+
+		slog.InfoContext(r.Context(), "Two-factor authentication disabled", "user_id", userID)
+		sendSuccessResponse(w, http.StatusOK, map[string]bool{"success": true})
+	}
+}
+
+// checkSecondFactor accepts a TOTP code that has not been used before or an
+// unused recovery code, spending it.
+func checkSecondFactor(twoFactor database.TwoFactorStore, userID int, factor secondFactor) (bool, error) {
+	if factor.RecoveryCode != "" {
+		return twoFactor.UseRecoveryCode(userID, utils.HashToken(utils.NormalizeRecoveryCode(factor.RecoveryCode)))
+	}
+
+	tf, err := twoFactor.GetTwoFactor(userID)
+	if err != nil || tf == nil || !tf.Enabled {
+		return false, err
+	}
+
+	step, ok := totp.Verify(tf.Secret, factor.Code, time.Now())
+	if !ok {
+		return false, nil
+	}
+	return twoFactor.UseTOTPStep(userID, step)
+}
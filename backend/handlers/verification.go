@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/nyagooh/Real-time-forum.git/backend/config"
+	"github.com/nyagooh/Real-time-forum.git/backend/database"
+	"github.com/nyagooh/Real-time-forum.git/backend/mail"
+	"github.com/nyagooh/Real-time-forum.git/backend/middleware"
+	"github.com/nyagooh/Real-time-forum.git/backend/utils"
+)
+
+// VerifyEmailHandler confirms an email address from the token in a
+// verification link.
+func VerifyEmailHandler(verifications database.EmailVerificationStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+
+		case http.MethodGet:
+			http.ServeFile(w, r, filepath.Join("frontend", "index.html"))
+
+		case http.MethodPost:
+			var req struct {
+				Token string `json:"token"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Token == "" {
+				slog.WarnContext(r.Context(), "Invalid verify email request", "err", err)
+				handleError(w, fmt.Errorf("token is required"), http.StatusBadRequest)
+				return
+			}
+
+			userID, err := verifications.VerifyEmail(utils.HashToken(req.Token))
+			if errors.Is(err, database.ErrInvalidToken) {
+				slog.WarnContext(r.Context(), "Email verification with unusable token")
+				handleError(w, fmt.Errorf("invalid or expired verification link"), http.StatusBadRequest)
+				return
+			}
+			if err != nil {
+				slog.ErrorContext(r.Context(), "Failed to verify email", "err", err)
+				handleError(w, fmt.Errorf("server error"), http.StatusInternalServerError)
+				return
+			}
+
+			slog.InfoContext(r.Context(), "Email verified", "user_id", userID)
+			sendSuccessResponse(w, http.StatusOK, map[string]bool{"success": true})
+
+		default:
+			handleError(w, fmt.Errorf("method not allowed"), http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// ResendVerificationHandler emails a fresh verification link to the signed-in
+// user, whose earlier links stay valid until they expire.
+func ResendVerificationHandler(users database.UserStore, verifications database.EmailVerificationStore, mailer mail.Mailer, cfg config.Account, publicURL string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			handleError(w, fmt.Errorf("method not allowed"), http.StatusMethodNotAllowed)
+			return
+		}
+
+		userID := r.Context().Value(middleware.UserIDKey).(int)
+
+		verified, err := users.IsVerified(userID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to check email verification", "user_id", userID, "err", err)
+			handleError(w, fmt.Errorf("server error"), http.StatusInternalServerError)
+			return
+		}
+		if verified {
+			slog.WarnContext(r.Context(), "Verification resend for verified account", "user_id", userID)
+			handleError(w, fmt.Errorf("email address already verified"), http.StatusConflict)
+			return
+		}
+
+		email, err := users.GetUserEmail(userID)
+		if err == nil {
+			err = sendVerificationEmail(r, verifications, mailer, cfg, publicURL, userID, email)
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to send verification email", "user_id", userID, "err", err)
+			handleError(w, fmt.Errorf("failed to send verification email"), http.StatusInternalServerError)
+			return
+		}
+
+		sendSuccessResponse(w, http.StatusOK, map[string]bool{"success": true})
+	}
+}
+
+func sendVerificationEmail(r *http.Request, verifications database.EmailVerificationStore, mailer mail.Mailer, cfg config.Account, publicURL string, userID int, email string) error {
+	token, hash, err := utils.GenerateOneTimeToken()
+	if err != nil {
+		return err
+	}
+
+	if err := verifications.CreateEmailVerification(userID, hash, time.Now().Add(cfg.VerifyTokenLifetime)); err != nil {
+		return err
+	}
+
+	link := strings.TrimSuffix(publicURL, "/") + "/verify-email?token=" + url.QueryEscape(token)
+	msg := mail.Message{
+		To:      email,
+		Subject: "Confirm your forum email address",
+		Body: "Welcome to the forum!\n\n" +
+			"Open this link to confirm your email address:\n\n" + link + "\n\n" +
+			fmt.Sprintf("The link expires in %s. ", formatLifetime(cfg.VerifyTokenLifetime)) +
+			"If you did not sign up, you can ignore this email.\n",
+	}
+	if err := mailer.Send(r.Context(), msg); err != nil {
+		return err
+	}
+
+	slog.InfoContext(r.Context(), "Verification email sent", "user_id", userID)
+	return nil
+}
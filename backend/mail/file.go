@@ -0,0 +1,39 @@
+package mail
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// FileMailer writes each message to its own .eml file in Dir, for
+// development setups without a mail server.
+type FileMailer struct {
+	Dir  string
+	From string
+}
+
+func (m *FileMailer) Send(_ context.Context, msg Message) error {
+	now := time.Now()
+	data, err := Format(m.From, msg, now)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(m.Dir, 0755); err != nil {
+		return fmt.Errorf("failed to create mail directory: %v", err)
+	}
+
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	name := now.UTC().Format("20060102T150405.000") + "-" + hex.EncodeToString(suffix) + ".eml"
+
+	if err := os.WriteFile(filepath.Join(m.Dir, name), data, 0600); err != nil {
+		return fmt.Errorf("failed to write mail file: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,182 @@
+package mail
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nyagooh/Real-time-forum.git/backend/config"
+	"github.com/nyagooh/Real-time-forum.git/backend/mail"
+)
+
+// envelope is what the stand-in SMTP server received for one message.
+type envelope struct {
+	from, to string
+	data     string
+}
+
+// startSMTPServer runs a minimal SMTP server that accepts every message and
+// reports it on the returned channel.
+func startSMTPServer(t *testing.T) (string, <-chan envelope) {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	received := make(chan envelope, 1)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serveSMTP(conn, received)
+		}
+	}()
+
+	return ln.Addr().String(), received
+}
+
+func serveSMTP(conn net.Conn, received chan<- envelope) {
+	defer conn.Close()
+	tp := textproto.NewConn(conn)
+
+	var env envelope
+	tp.PrintfLine("220 localhost ready")
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		verb := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+		switch verb {
+		case "EHLO", "HELO":
+			tp.PrintfLine("250 localhost")
+		case "MAIL":
+			env.from = strings.TrimPrefix(line, "MAIL FROM:")
+			tp.PrintfLine("250 OK")
+		case "RCPT":
+			env.to = strings.TrimPrefix(line, "RCPT TO:")
+			tp.PrintfLine("250 OK")
+		case "DATA":
+			tp.PrintfLine("354 go ahead")
+			data, err := tp.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			env.data = string(data)
+			tp.PrintfLine("250 queued")
+			received <- env
+		case "QUIT":
+			tp.PrintfLine("221 bye")
+			return
+		default:
+			tp.PrintfLine("502 not implemented")
+		}
+	}
+}
+
+var testMessage = mail.Message{
+	To:      "alice@example.com",
+	Subject: "Reset your password",
+	Body:    "Hello Alice,\n.leading dot survives\n",
+}
+
+func TestSMTPMailer(t *testing.T) {
+	addr, received := startSMTPServer(t)
+
+	mailer, err := mail.New(config.Mail{
+		Transport: "smtp",
+		From:      "forum@example.com",
+		Timeout:   5 * time.Second,
+		SMTP:      config.SMTP{Addr: addr},
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	if err := mailer.Send(context.Background(), testMessage); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	select {
+	case env := <-received:
+		if env.from != "<forum@example.com>" || env.to != "<alice@example.com>" {
+			t.Errorf("envelope = %s -> %s", env.from, env.to)
+		}
+		for _, want := range []string{"Subject: Reset your password", "To: alice@example.com", "Hello Alice,", ".leading dot survives"} {
+			if !strings.Contains(env.data, want) {
+				t.Errorf("message missing %q:\n%s", want, env.data)
+			}
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("server received no message")
+	}
+}
+
+func TestSMTPMailerUnreachable(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	mailer := &mail.SMTPMailer{Addr: addr, From: "forum@example.com", Timeout: time.Second}
+	if err := mailer.Send(context.Background(), testMessage); err == nil {
+		t.Error("expected Send() to fail without a server")
+	}
+}
+
+func TestFileMailer(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "outbox")
+	mailer := &mail.FileMailer{Dir: dir, From: "forum@example.com"}
+
+	if err := mailer.Send(context.Background(), testMessage); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("expected one .eml file, got %v (%v)", files, err)
+	}
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatalf("failed to read message: %v", err)
+	}
+
+	msg, err := textproto.NewReader(bufio.NewReader(strings.NewReader(string(data)))).ReadMIMEHeader()
+	if err != nil {
+		t.Fatalf("message headers do not parse: %v", err)
+	}
+	if msg.Get("From") != "forum@example.com" || msg.Get("To") != "alice@example.com" {
+		t.Errorf("headers = %v", msg)
+	}
+}
+
+func TestMemoryMailer(t *testing.T) {
+	mailer := &mail.MemoryMailer{}
+	mailer.Send(context.Background(), testMessage)
+
+	if got := mailer.Messages(); len(got) != 1 || got[0] != testMessage {
+		t.Errorf("Messages() = %+v", got)
+	}
+}
+
+func TestFormatRejectsHeaderInjection(t *testing.T) {
+	msg := testMessage
+	msg.Subject = "Hi\r\nBcc: victim@example.com"
+
+	if _, err := mail.Format("forum@example.com", msg, time.Now()); err == nil {
+		t.Error("expected a subject with a line break to be rejected")
+	}
+}
@@ -1,44 +1,205 @@
 package main
 
 import (
+	"context"
+	"database/sql"
 	"flag"
 	"fmt"
+	"log/slog"
 	"net/http"
-	"time"
+	"os"
+	"os/signal"
+	"syscall"
 
+	"github.com/nyagooh/Real-time-forum.git/backend/config"
 	"github.com/nyagooh/Real-time-forum.git/backend/database"
 	"github.com/nyagooh/Real-time-forum.git/backend/errLog"
+	"github.com/nyagooh/Real-time-forum.git/backend/mail"
+	"github.com/nyagooh/Real-time-forum.git/backend/metrics"
 	"github.com/nyagooh/Real-time-forum.git/backend/routes"
+	ws "github.com/nyagooh/Real-time-forum.git/backend/websockets"
 )
 
 func main() {
-	errLog.InitLoggers()
+	configPath := flag.String("config", os.Getenv(config.EnvPrefix+"_CONFIG"), "path to a YAML config file")
+	migrate := flag.String("migrate", "", "run schema migrations and exit: \"up\" or \"down\" (rolls back one)")
+	bootstrapAdmin := flag.String("bootstrap-admin", "", "make the user with this nickname the first admin and exit")
+	overrides := config.BindFlags(flag.CommandLine)
+
+	flag.Parse()
+
+	cfg, err := config.Load(*configPath)
+	if err == nil {
+		err = overrides.Apply(&cfg)
+	}
+	if err == nil {
+		err = cfg.Validate()
+	}
+	if err == nil {
+		err = errLog.InitLoggers(cfg.Log)
+	}
+	if err != nil {
+		slog.Error("Invalid configuration", "err", err)
+		os.Exit(1)
+	}
 	defer errLog.CloseLoggers()
 
-	db, err := database.InitDB()
+	db, dialect, err := database.InitDB(cfg.Database.URL)
 	if err != nil {
-		errLog.Error.Printf("Failed to connect to database: %v\n", err)
+		slog.Error("Failed to connect to database", "err", err)
+		os.Exit(1)
+	}
+	defer db.Close()
+	slog.Info("Database initialized", "dialect", dialect)
+
+	// Startup failures exit non-zero so supervisors, deploy scripts and CI
+	// do not take them for a clean stop.
+	switch *migrate {
+	case "":
+	case "up":
+		if err := database.Migrate(db, dialect); err != nil {
+			slog.Error("Migration failed", "err", err)
+			os.Exit(1)
+		}
+		return
+	case "down":
+		if err := database.Rollback(db, dialect, 1); err != nil {
+			slog.Error("Rollback failed", "err", err)
+			os.Exit(1)
+		}
 		return
+	default:
+		slog.Error("Unknown migrate command", "command", *migrate)
+		os.Exit(1)
 	}
-	errLog.Info.Println("database initialized successfully")
 
-	addr := flag.String("addr", ":8080", "HTTP network address")
+	if cfg.Database.AutoMigrate {
+		if err := database.Migrate(db, dialect); err != nil {
+			slog.Error("Migration failed", "err", err)
+			os.Exit(1)
+		}
+	}
 
-	go database.StartSessionCleanup(time.Hour)
+	if err := database.CheckSchema(db, dialect); err != nil {
+		slog.Error("Refusing to start", "err", err)
+		os.Exit(1)
+	}
 
-	flag.Parse()
+	store := database.NewStore(db, dialect)
+
+	if *bootstrapAdmin != "" {
+		userID, err := store.BootstrapAdmin(*bootstrapAdmin)
+		if err != nil {
+			slog.Error("Failed to appoint the first admin", "nickname", *bootstrapAdmin, "err", err)
+			fmt.Printf("Failed to appoint the first admin: %v\n", err)
+			os.Exit(1)
+		}
+		slog.Info("First admin appointed", "nickname", *bootstrapAdmin, "user_id", userID)
+		fmt.Printf("%s is now an admin\n", *bootstrapAdmin)
+		return
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	go database.StartSessionCleanup(ctx, store, cfg.Session.CleanupInterval)
+	go database.StartLoginAttemptCleanup(ctx, store, cfg.Account.LoginThrottle.AttemptRetention, cfg.Session.CleanupInterval)
+	go database.StartContentPurge(ctx, store, cfg.Content.DeletedRetention, cfg.Content.PurgeInterval)
+	go reopenLogsOnHangup(ctx)
+
+	mailer, err := mail.New(cfg.Mail)
+	if err != nil {
+		slog.Error("Failed to set up mail", "err", err)
+		os.Exit(1)
+	}
 
-	mux := routes.Routes(db)
+	mux := routes.Routes(cfg, store, mailer)
 
 	srv := &http.Server{
-		Addr:     *addr,
-		ErrorLog: errLog.Error,
+		Addr:     cfg.Server.Addr,
+		ErrorLog: errLog.ErrorLog(),
 		Handler:  mux,
 	}
+	servers := []*http.Server{srv}
+
+	if cfg.Metrics.Addr != "" {
+		metrics.RegisterHub(metrics.Default, ws.GlobalHub)
+		metrics.RegisterDBStats(metrics.Default, db)
+
+		metricsMux := http.NewServeMux()
+		metricsMux.Handle("/metrics", metrics.Default.Handler())
+		servers = append(servers, &http.Server{
+			Addr:     cfg.Metrics.Addr,
+			ErrorLog: errLog.ErrorLog(),
+			Handler:  metricsMux,
+		})
+		slog.Info("Metrics listening", "addr", cfg.Metrics.Addr)
+	}
+
+	slog.Info("Server starting", "addr", cfg.Server.Addr)
+	fmt.Printf("Server starting on port http://localhost%s/\n", cfg.Server.Addr)
+
+	serveErr := make(chan error, len(servers))
+	for _, s := range servers {
+		go func() { serveErr <- s.ListenAndServe() }()
+	}
+
+	select {
+	case err := <-serveErr:
+		slog.Error("Server stopped", "err", err)
+	case <-ctx.Done():
+		slog.Info("Shutdown signal received, draining connections")
+	}
+	stop()
+
+	shutdown(cfg, servers, db)
+}
 
-	errLog.Info.Printf("Server starting on port http://localhost%s/\n", *addr)
-	fmt.Printf("Server starting on port http://localhost%s/\n", *addr)
+// reopenLogsOnHangup reopens the log files on SIGHUP, so an external logrotate
+// can move them aside, until ctx is done.
+func reopenLogsOnHangup(ctx context.Context) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			errLog.Reopen()
+		}
+	}
+}
+
+// shutdown stops accepting requests, closes every websocket with a
+// "server restarting" frame and closes the database, all within the
+// configured shutdown timeout.
+func shutdown(cfg config.Config, servers []*http.Server, db *sql.DB) {
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+
+	const reason = "server restarting"
+	ws.ClosePresenceConnections(reason, cfg.WebSocket.WriteWait)
+
+	// Hijacked websocket connections are not tracked by http.Server, so the
+	// hub drains its clients alongside the HTTP shutdown.
+	hubDone := make(chan struct{})
+	go func() {
+		ws.GlobalHub.Shutdown(ctx, reason)
+		close(hubDone)
+	}()
+
+	for _, srv := range servers {
+		if err := srv.Shutdown(ctx); err != nil {
+			slog.Error("HTTP shutdown failed", "addr", srv.Addr, "err", err)
+		}
+	}
+	<-hubDone
+
+	if err := database.CloseDB(ctx, db); err != nil {
+		slog.Error("Database close failed", "err", err)
+	}
 
-	err = srv.ListenAndServe()
-	errLog.Error.Fatal(err)
+	slog.Info("Server stopped")
 }
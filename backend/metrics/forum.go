@@ -0,0 +1,71 @@
+package metrics
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Default holds the forum's metrics and is what /metrics serves.
+var Default = NewRegistry()
+
+var (
+	HTTPRequestDuration = Default.NewHistogram("forum_http_request_duration_seconds",
+		"Time taken to serve HTTP requests, by route pattern.", DefaultBuckets, "method", "route", "status")
+
+	WebSocketDroppedSends = Default.NewCounter("forum_websocket_dropped_sends_total",
+		"Messages dropped because a client's send queue was full; the client is disconnected.")
+
+	MessagesPersisted = Default.NewCounter("forum_messages_persisted_total",
+		"Private messages saved to the database.")
+
+	GetAllPostsDuration = Default.NewHistogram("forum_get_all_posts_duration_seconds",
+		"Time taken to load the post feed from the database.", DefaultBuckets)
+)
+
+// Since returns the seconds elapsed since start, for Observe.
+func Since(start time.Time) float64 {
+	return time.Since(start).Seconds()
+}
+
+// Hub is the part of the websocket hub that metrics reads on every scrape.
+type Hub interface {
+	ClientCount() int
+	QueueDepth() int
+}
+
+// RegisterHub exposes the hub's connected clients and queued messages.
+func RegisterHub(r *Registry, hub Hub) {
+	r.NewGaugeFunc("forum_websocket_clients",
+		"Chat websocket clients connected to the hub.",
+		func() float64 { return float64(hub.ClientCount()) })
+	r.NewGaugeFunc("forum_websocket_send_queue_depth",
+		"Messages waiting in client send queues, summed over all clients.",
+		func() float64 { return float64(hub.QueueDepth()) })
+}
+
+// RegisterDBStats exposes the database/sql connection pool statistics.
+func RegisterDBStats(r *Registry, db *sql.DB) {
+	gauge := func(name, help string, fn func(sql.DBStats) float64) {
+		r.NewGaugeFunc(name, help, func() float64 { return fn(db.Stats()) })
+	}
+	counter := func(name, help string, fn func(sql.DBStats) float64) {
+		r.NewCounterFunc(name, help, func() float64 { return fn(db.Stats()) })
+	}
+
+	gauge("forum_db_max_open_connections", "Maximum number of open connections to the database.",
+		func(s sql.DBStats) float64 { return float64(s.MaxOpenConnections) })
+	gauge("forum_db_open_connections", "Established connections, both in use and idle.",
+		func(s sql.DBStats) float64 { return float64(s.OpenConnections) })
+	gauge("forum_db_in_use_connections", "Connections currently in use.",
+		func(s sql.DBStats) float64 { return float64(s.InUse) })
+	gauge("forum_db_idle_connections", "Idle connections.",
+		func(s sql.DBStats) float64 { return float64(s.Idle) })
+	counter("forum_db_wait_count_total", "Connections waited for.",
+		func(s sql.DBStats) float64 { return float64(s.WaitCount) })
+	counter("forum_db_wait_duration_seconds_total", "Time spent waiting for a connection.",
+		func(s sql.DBStats) float64 { return s.WaitDuration.Seconds() })
+	counter("forum_db_max_idle_closed_total", "Connections closed due to the idle limit.",
+		func(s sql.DBStats) float64 { return float64(s.MaxIdleClosed) })
+	counter("forum_db_max_lifetime_closed_total", "Connections closed due to the lifetime limit.",
+		func(s sql.DBStats) float64 { return float64(s.MaxLifetimeClosed) })
+}
@@ -0,0 +1,63 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/nyagooh/Real-time-forum.git/backend/metrics"
+)
+
+func TestTextExposition(t *testing.T) {
+	reg := metrics.NewRegistry()
+
+	requests := reg.NewCounter("test_requests_total", "Requests.", "route")
+	requests.Inc("/posts")
+	requests.Add(2, `/a"b`)
+
+	reg.NewCounter("test_dropped_total", "Dropped.")
+	reg.NewGaugeFunc("test_clients", "Clients.", func() float64 { return 3 })
+
+	latency := reg.NewHistogram("test_latency_seconds", "Latency.", []float64{0.1, 1}, "route")
+	latency.Observe(0.05, "/posts")
+	latency.Observe(0.5, "/posts")
+	latency.Observe(5, "/posts")
+
+	rec := httptest.NewRecorder()
+	reg.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type = %q", ct)
+	}
+
+	body := rec.Body.String()
+	for _, want := range []string{
+		"# TYPE test_requests_total counter\n",
+		`test_requests_total{route="/posts"} 1` + "\n",
+		`test_requests_total{route="/a\"b"} 2` + "\n",
+		"test_dropped_total 0\n",
+		"# TYPE test_clients gauge\ntest_clients 3\n",
+		"# TYPE test_latency_seconds histogram\n",
+		`test_latency_seconds_bucket{route="/posts",le="0.1"} 1` + "\n",
+		`test_latency_seconds_bucket{route="/posts",le="1"} 2` + "\n",
+		`test_latency_seconds_bucket{route="/posts",le="+Inf"} 3` + "\n",
+		`test_latency_seconds_sum{route="/posts"} 5.55` + "\n",
+		`test_latency_seconds_count{route="/posts"} 3` + "\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("output is missing %q:\n%s", want, body)
+		}
+	}
+}
+
+func TestDuplicateMetricPanics(t *testing.T) {
+	reg := metrics.NewRegistry()
+	reg.NewCounter("test_total", "Test.")
+
+	defer func() {
+		if recover() == nil {
+			t.Error("registering a metric twice did not panic")
+		}
+	}()
+	reg.NewGauge("test_total", "Test.")
+}
@@ -19,7 +19,7 @@ func SetCookie(w http.ResponseWriter, token string, expiresAt time.Time) {
 func SetCSRFCookie(w http.ResponseWriter, token string) {
 	http.SetCookie(w, &http.Cookie{
 		Path:     "/",
-		Name:     "csrf_token",
+		Name:     CSRFCookieName,
 		Value:    token,
 		HttpOnly: true,
 		SameSite: http.SameSiteStrictMode,
@@ -34,4 +34,12 @@ func DeleteCookie(w http.ResponseWriter) {
 		Value: "",
 		Expires:  time.Now(),
 	})
+	// Delete CSRF cookie
+	http.SetCookie(w, &http.Cookie{
+		Path:    "/",
+		Name:    CSRFCookieName,
+		MaxAge:  -1,
+		Value:   "",
+		Expires: time.Now(),
+	})
 }
\ No newline at end of file
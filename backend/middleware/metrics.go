@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/nyagooh/Real-time-forum.git/backend/metrics"
+)
+
+// Metrics records how long each request took, labelled by the ServeMux
+// pattern that matched it so the number of series stays bounded. It must wrap
+// the mux without replacing the request, since the mux stores the pattern on
+// the request it is given.
+func Metrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := newResponseRecorder(w)
+
+		next.ServeHTTP(rec, r)
+
+		route := r.Pattern
+		if route == "" {
+			route = "unmatched"
+		}
+		metrics.HTTPRequestDuration.Observe(metrics.Since(start), r.Method, route, strconv.Itoa(rec.Status()))
+	})
+}
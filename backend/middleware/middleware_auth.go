@@ -2,33 +2,63 @@ package middleware
 
 import (
 	"context"
-	"database/sql"
 	"encoding/json"
+	"fmt"
+	"log/slog"
 	"net/http"
 
+	"github.com/nyagooh/Real-time-forum.git/backend/config"
 	"github.com/nyagooh/Real-time-forum.git/backend/database"
-	"github.com/nyagooh/Real-time-forum.git/backend/errLog"
 )
 
 type contextKey string
 
 const UserIDKey contextKey = "userID"
 
-func AuthMiddleware(db *sql.DB, next http.Handler) http.Handler {
+// SessionTokenKey holds the token of the session AuthMiddleware let the
+// request through with.
+const SessionTokenKey contextKey = "sessionToken"
+
+// SessionToken returns the token of the request's session, and false if
+// the request did not come through AuthMiddleware.
+func SessionToken(r *http.Request) (string, bool) {
+	token, ok := r.Context().Value(SessionTokenKey).(string)
+	return token, ok && token != ""
+}
+
+// AuthMiddleware lets requests with a live session through, with the user's
+// ID and session token in the context. Sessions in use past half their lifetime are renewed
+// and their cookie re-set, up to the session's cap.
+func AuthMiddleware(sessions database.SessionStore, cfg config.Session, next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		userID, err := database.GetUserIDFromSession(db, r)
+		sessionCookie, err := r.Cookie("session_token")
+		if err != nil {
+			handleError(w, r, fmt.Errorf("session token not found: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		userID, err := sessions.GetUserIDFromSession(sessionCookie.Value)
 		if err != nil {
-			handleError(w, err, http.StatusInternalServerError)
+			handleError(w, r, err, http.StatusInternalServerError)
 			return
 		}
 
+		expiresAt, err := sessions.TouchSession(sessionCookie.Value, cfg.Lifetime)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to update session", "user_id", userID, "err", err)
+		} else if !expiresAt.IsZero() {
+			SetCookie(w, sessionCookie.Value, expiresAt)
+		}
+
+		setRequestUser(r.Context(), userID)
 		ctx := context.WithValue(r.Context(), UserIDKey, userID)
+		ctx = context.WithValue(ctx, SessionTokenKey, sessionCookie.Value)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
-func handleError(w http.ResponseWriter, err error, statusCode int) {
-	errLog.Error.Println(err.Error())
+func handleError(w http.ResponseWriter, r *http.Request, err error, statusCode int) {
+	slog.WarnContext(r.Context(), "Request rejected", "path", r.URL.Path, "status", statusCode, "err", err)
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(statusCode)
 	json.NewEncoder(w).Encode(map[string]any{
@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/nyagooh/Real-time-forum.git/backend/ratelimit"
+)
+
+// KeyFunc picks the bucket a request draws tokens from.
+type KeyFunc func(r *http.Request) string
+
+// ClientIP returns the address the request came from, without the port.
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// ByIP keys requests by client IP.
+func ByIP(r *http.Request) string {
+	return "ip:" + ClientIP(r)
+}
+
+// ByUser keys requests by the authenticated user, falling back to the client
+// IP when AuthMiddleware has not run.
+func ByUser(r *http.Request) string {
+	if userID, ok := r.Context().Value(UserIDKey).(int); ok {
+		return "user:" + strconv.Itoa(userID)
+	}
+	return ByIP(r)
+}
+
+// RateLimit answers 429 with a Retry-After header once key's bucket in
+// limiter is empty. Only state-changing requests are limited; GET and HEAD
+// pass straight through. A nil limiter disables the check.
+func RateLimit(limiter *ratelimit.Limiter, key KeyFunc, next http.Handler) http.Handler {
+	if limiter == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if ok, wait := limiter.Allow(key(r)); !ok {
+			seconds := int(math.Ceil(wait.Seconds()))
+			w.Header().Set("Retry-After", strconv.Itoa(seconds))
+			handleError(w, r, fmt.Errorf("too many requests, try again in %d seconds", seconds), http.StatusTooManyRequests)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
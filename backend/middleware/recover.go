@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+)
+
+// Recover turns a panicking handler into a logged stack trace and a 500 JSON
+// response in the same shape as every other error.
+func Recover(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := newResponseRecorder(w)
+
+		defer func() {
+			p := recover()
+			if p == nil {
+				return
+			}
+			// Deliberate aborts are how net/http cancels a response; let it.
+			if p == http.ErrAbortHandler {
+				panic(p)
+			}
+
+			slog.ErrorContext(r.Context(), "Handler panicked",
+				"method", r.Method,
+				"path", r.URL.Path,
+				"panic", p,
+				"stack", string(debug.Stack()),
+			)
+
+			// Once the status line is out there is nothing left to fix up.
+			if rec.Written() {
+				return
+			}
+			rec.Header().Set("Content-Type", "application/json")
+			rec.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(rec).Encode(map[string]any{
+				"success": false,
+				"message": "internal server error",
+			})
+		}()
+
+		next.ServeHTTP(rec, r)
+	})
+}
@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/nyagooh/Real-time-forum.git/backend/database"
+	"github.com/nyagooh/Real-time-forum.git/backend/models"
+)
+
+// HasPermission reports whether the signed-in user's role grants perm, for
+// handlers that let staff do more than the owner of a post or comment. The
+// request must have passed AuthMiddleware.
+func HasPermission(r *http.Request, roles database.RoleStore, perm models.Permission) (bool, error) {
+	userID, _ := r.Context().Value(UserIDKey).(int)
+	role, err := roles.GetUserRole(userID)
+	if err != nil {
+		return false, err
+	}
+	return role.Can(perm), nil
+}
+
+// RequirePermission refuses requests from users whose role does not grant
+// perm. It goes inside AuthMiddleware.
+func RequirePermission(roles database.RoleStore, perm models.Permission, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ok, err := HasPermission(r, roles, perm)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to check permission", "permission", perm, "err", err)
+			handleError(w, r, fmt.Errorf("server error"), http.StatusInternalServerError)
+			return
+		}
+		if !ok {
+			handleError(w, r, errors.New("you do not have permission to do that"), http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,94 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/nyagooh/Real-time-forum.git/backend/middleware"
+)
+
+// captureLogs points the default logger at a buffer for the test's duration.
+func captureLogs(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, nil)))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+	return &buf
+}
+
+func TestRecoverReturnsJSONError(t *testing.T) {
+	logs := captureLogs(t)
+
+	handler := middleware.Recover(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.Context().Value(middleware.UserIDKey).(int)
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/likes", nil))
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want 500", rec.Code)
+	}
+	var body map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("body is not JSON: %q", rec.Body.String())
+	}
+	if body["success"] != false || body["message"] != "internal server error" {
+		t.Errorf("body = %v", body)
+	}
+
+	var record map[string]any
+	if err := json.Unmarshal(logs.Bytes(), &record); err != nil {
+		t.Fatalf("log is not one JSON record: %q", logs.String())
+	}
+	if record["msg"] != "Handler panicked" || record["stack"] == "" {
+		t.Errorf("log record = %v", record)
+	}
+}
+
+func TestAccessLogRecordsResponse(t *testing.T) {
+	logs := captureLogs(t)
+
+	handler := middleware.AccessLog(middleware.Recover(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("hello"))
+	})))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/posts", nil))
+
+	var record map[string]any
+	if err := json.Unmarshal(logs.Bytes(), &record); err != nil {
+		t.Fatalf("log is not one JSON record: %q", logs.String())
+	}
+	if record["method"] != "POST" || record["path"] != "/posts" ||
+		record["status"] != float64(http.StatusCreated) || record["bytes"] != float64(5) {
+		t.Errorf("log record = %v", record)
+	}
+	if _, ok := record["duration"]; !ok {
+		t.Errorf("log record has no duration: %v", record)
+	}
+}
+
+func TestAccessLogKeepsHijacker(t *testing.T) {
+	captureLogs(t)
+
+	handler := middleware.AccessLog(middleware.Recover(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := w.(http.Hijacker); !ok {
+			t.Error("wrapped response writer does not implement http.Hijacker")
+		}
+	})))
+
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+}
@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nyagooh/Real-time-forum.git/backend/config"
+	"github.com/nyagooh/Real-time-forum.git/backend/database"
+	"github.com/nyagooh/Real-time-forum.git/backend/middleware"
+)
+
+// renewingSessions knows one session, "token", and renews it to renewTo;
+// the rest of SessionStore is unused.
+type renewingSessions struct {
+	database.SessionStore
+	renewTo time.Time
+}
+
+func (s renewingSessions) GetUserIDFromSession(token string) (int, error) {
+	if token != "token" {
+		return 0, database.ErrInvalidToken
+	}
+	return 7, nil
+}
+
+func (s renewingSessions) TouchSession(token string, lifetime time.Duration) (time.Time, error) {
+	return s.renewTo, nil
+}
+
+func TestAuthMiddlewareRenewsSession(t *testing.T) {
+	captureLogs(t)
+
+	tests := []struct {
+		name       string
+		renewTo    time.Time
+		wantCookie bool
+	}{
+		{"Fresh session", time.Time{}, false},
+		{"Renewed session", time.Now().Add(24 * time.Hour), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotUser any
+			handler := middleware.AuthMiddleware(renewingSessions{renewTo: tt.renewTo}, config.Default().Session,
+				http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					gotUser = r.Context().Value(middleware.UserIDKey)
+				}))
+
+			req := httptest.NewRequest(http.MethodGet, "/posts", nil)
+			req.AddCookie(&http.Cookie{Name: "session_token", Value: "token"})
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if gotUser != 7 {
+				t.Fatalf("user in context = %v, want 7", gotUser)
+			}
+			cookie := rec.Header().Get("Set-Cookie")
+			if got := strings.Contains(cookie, "session_token=token"); got != tt.wantCookie {
+				t.Errorf("cookie re-set = %v, want %v (%q)", got, tt.wantCookie, cookie)
+			}
+			if tt.wantCookie && !strings.Contains(cookie, tt.renewTo.UTC().Format(http.TimeFormat)) {
+				t.Errorf("cookie %q does not expire at %v", cookie, tt.renewTo)
+			}
+		})
+	}
+}
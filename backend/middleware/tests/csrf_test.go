@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/nyagooh/Real-time-forum.git/backend/middleware"
+)
+
+func TestCSRF(t *testing.T) {
+	captureLogs(t)
+
+	handler := middleware.CSRF(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), "/login")
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		cookie string
+		header string
+		want   int
+	}{
+		{"GET passes", http.MethodGet, "/posts", "", "", http.StatusOK},
+		{"Matching token", http.MethodPost, "/likes", "tok", "tok", http.StatusOK},
+		{"Missing header", http.MethodPost, "/likes", "tok", "", http.StatusForbidden},
+		{"Missing cookie", http.MethodPost, "/likes", "", "tok", http.StatusForbidden},
+		{"Mismatch", http.MethodDelete, "/likes", "tok", "other", http.StatusForbidden},
+		{"Exempt path", http.MethodPost, "/login", "", "", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: middleware.CSRFCookieName, Value: tt.cookie})
+			}
+			if tt.header != "" {
+				req.Header.Set(middleware.CSRFHeader, tt.header)
+			}
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
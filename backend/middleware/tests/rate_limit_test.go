@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/nyagooh/Real-time-forum.git/backend/middleware"
+	"github.com/nyagooh/Real-time-forum.git/backend/ratelimit"
+)
+
+func TestRateLimit(t *testing.T) {
+	captureLogs(t)
+
+	limiter := ratelimit.New(time.Minute, 1, time.Hour)
+	handler := middleware.RateLimit(limiter, middleware.ByIP, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	serve := func(method, remote string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/login", nil)
+		req.RemoteAddr = remote
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := serve(http.MethodPost, "10.0.0.1:1234"); rec.Code != http.StatusOK {
+		t.Fatalf("first POST: status %d, want 200", rec.Code)
+	}
+
+	rec := serve(http.MethodPost, "10.0.0.1:5678")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("second POST: status %d, want 429", rec.Code)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "60" {
+		t.Errorf("Retry-After = %q, want 60", got)
+	}
+
+	if rec := serve(http.MethodGet, "10.0.0.1:1234"); rec.Code != http.StatusOK {
+		t.Errorf("GET: status %d, want 200; reads are not limited", rec.Code)
+	}
+	if rec := serve(http.MethodPost, "10.0.0.2:1234"); rec.Code != http.StatusOK {
+		t.Errorf("POST from another IP: status %d, want 200", rec.Code)
+	}
+}
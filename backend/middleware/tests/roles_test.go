@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/nyagooh/Real-time-forum.git/backend/database"
+	"github.com/nyagooh/Real-time-forum.git/backend/middleware"
+	"github.com/nyagooh/Real-time-forum.git/backend/models"
+)
+
+// roleOf answers GetUserRole; the rest of RoleStore is unused.
+type roleOf struct {
+	database.RoleStore
+	role models.Role
+}
+
+func (r roleOf) GetUserRole(userID int) (models.Role, error) { return r.role, nil }
+
+func TestRequirePermission(t *testing.T) {
+	captureLogs(t)
+
+	tests := []struct {
+		name string
+		role models.Role
+		perm models.Permission
+		want int
+	}{
+		{"User reviewing reports", models.RoleUser, models.PermReviewReports, http.StatusForbidden},
+		{"Moderator reviewing reports", models.RoleModerator, models.PermReviewReports, http.StatusOK},
+		{"Moderator managing roles", models.RoleModerator, models.PermManageRoles, http.StatusForbidden},
+		{"Admin managing roles", models.RoleAdmin, models.PermManageRoles, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := middleware.RequirePermission(roleOf{role: tt.role}, tt.perm,
+				http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+			req := httptest.NewRequest(http.MethodGet, "/admin/staff", nil)
+			req = req.WithContext(context.WithValue(req.Context(), middleware.UserIDKey, 1))
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
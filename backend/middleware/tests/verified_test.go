@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/nyagooh/Real-time-forum.git/backend/database"
+	"github.com/nyagooh/Real-time-forum.git/backend/middleware"
+)
+
+// verifiedUsers answers IsVerified; the rest of UserStore is unused.
+type verifiedUsers struct {
+	database.UserStore
+	verified bool
+}
+
+func (v verifiedUsers) IsVerified(id int) (bool, error) { return v.verified, nil }
+
+func TestRequireVerified(t *testing.T) {
+	captureLogs(t)
+
+	tests := []struct {
+		name     string
+		method   string
+		verified bool
+		want     int
+	}{
+		{"Verified post", http.MethodPost, true, http.StatusOK},
+		{"Unverified post", http.MethodPost, false, http.StatusForbidden},
+		{"Unverified read", http.MethodGet, false, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := middleware.RequireVerified(verifiedUsers{verified: tt.verified},
+				http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+			req := httptest.NewRequest(tt.method, "/posts", nil)
+			req = req.WithContext(context.WithValue(req.Context(), middleware.UserIDKey, 1))
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/nyagooh/Real-time-forum.git/backend/database"
+)
+
+// RequireVerified refuses state-changing requests from accounts that have not
+// confirmed their email address yet. Reads still pass, so the user can look
+// around while the email is on its way. It goes inside AuthMiddleware.
+func RequireVerified(users database.UserStore, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		userID, _ := r.Context().Value(UserIDKey).(int)
+		verified, err := users.IsVerified(userID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to check email verification", "user_id", userID, "err", err)
+			handleError(w, r, fmt.Errorf("server error"), http.StatusInternalServerError)
+			return
+		}
+		if !verified {
+			handleError(w, r, errors.New("verify your email address first"), http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
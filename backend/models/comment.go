@@ -1,13 +1,28 @@
 package models
 
+// Comment is a comment on a post or a reply to another comment. Path lists
+// the IDs from the top-level comment down to this one; Replies counts the
+// direct replies, whether or not Children holds them. Edited is set once
+// the author has changed the content, last at UpdatedAt.
 type Comment struct {
-    ID        int    `json:"id"`
-    UserID    int    `json:"user_id"`
-    Username  string `json:"username"`
-    Content   string `json:"content"`
-    CreatedAt string `json:"createdAt"`
-    Likes     int    `json:"likes"`
-	Dislikes  int    `json:"dislikes"`
-    LikedBy   []string `json:"likedBy"`
-    DislikedBy []string `json:"dislikedBy"`
-}
\ No newline at end of file
+	ID         int       `json:"id"`
+	PostID     int       `json:"postId"`
+	ParentID   int       `json:"parentId,omitempty"`
+	Depth      int       `json:"depth"`
+	Path       string    `json:"path"`
+	Replies    int       `json:"replies"`
+	UserID     int       `json:"user_id"`
+	Username   string    `json:"username"`
+	Content    string    `json:"content"`
+	CreatedAt  string    `json:"createdAt"`
+	Edited     bool      `json:"edited"`
+	UpdatedAt  string    `json:"updatedAt,omitempty"`
+	Likes      int       `json:"likes"`
+	Dislikes   int       `json:"dislikes"`
+	LikedBy    []string  `json:"likedBy"`
+	DislikedBy []string  `json:"dislikedBy"`
+	Deleted    bool      `json:"deleted"`
+	DeletedAt  string    `json:"deletedAt,omitempty"`
+	DeletedBy  string    `json:"deletedBy,omitempty"` // DeletedByAuthor or DeletedByModerator
+	Children   []Comment `json:"children,omitempty"`
+}
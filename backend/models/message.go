@@ -3,6 +3,7 @@ package models
 import "time"
 
 type Message struct {
+	ID         int       `json:"id"`
 	Type       string    `json:"type"`
 	SenderID   int       `json:"sender_id"`
 	Sender     string    `json:"sender"`
@@ -1,16 +1,27 @@
 package models
 
+// Who deleted a post or comment, as shown on its tombstone.
+const (
+	DeletedByAuthor    = "author"
+	DeletedByModerator = "moderator"
+)
+
 type Post struct {
-	ID int			  `json:"id"`
-	Username string   `json:"username"`
-	Title    string   `json:"title"`
-	Content  string   `json:"content"`
-	Category []string `json:"categories"`
-	ImageURL string   `json:"imageURL"`
-	Likes    int      `json:"likes"`
-	Dislikes int      `json:"dislikes"`
-	CreatedAt string  `json:"createdAt"`
-	Comments  []Comment `json:"comments"`
-	LikedBy []string  `json:"likedBy"`
-	DislikedBy []string `json:"dislikedBy"`
+	ID         int       `json:"id"`
+	Username   string    `json:"username"`
+	Title      string    `json:"title"`
+	Content    string    `json:"content"`
+	Category   []string  `json:"categories"`
+	ImageURL   string    `json:"imageURL"`
+	Likes      int       `json:"likes"`
+	Dislikes   int       `json:"dislikes"`
+	CreatedAt  string    `json:"createdAt"`
+	Edited     bool      `json:"edited"`
+	UpdatedAt  string    `json:"updatedAt,omitempty"`
+	Deleted    bool      `json:"deleted"`
+	DeletedAt  string    `json:"deletedAt,omitempty"`
+	DeletedBy  string    `json:"deletedBy,omitempty"` // DeletedByAuthor or DeletedByModerator
+	Comments   []Comment `json:"comments"`
+	LikedBy    []string  `json:"likedBy"`
+	DislikedBy []string  `json:"dislikedBy"`
 }
@@ -0,0 +1,14 @@
+package models
+
+// PostRevision is one version of a post. Revisions are numbered from 1 for
+// the original; the highest number is the post as it reads now. Editor
+// wrote this version at EditedAt.
+type PostRevision struct {
+	Revision int      `json:"revision"`
+	Title    string   `json:"title"`
+	Content  string   `json:"content"`
+	Category []string `json:"categories"`
+	ImageURL string   `json:"imageURL"`
+	Editor   string   `json:"editor"`
+	EditedAt string   `json:"editedAt"`
+}
@@ -0,0 +1,41 @@
+package models
+
+import "time"
+
+// What can be reported.
+const (
+	ReportPost    = "post"
+	ReportComment = "comment"
+	ReportMessage = "message"
+)
+
+// Report statuses. Open reports wait for a moderator; the others are final.
+const (
+	ReportOpen      = "open"
+	ReportActioned  = "actioned"
+	ReportDismissed = "dismissed"
+)
+
+// ReportReasons are the categories a reporter picks from.
+var ReportReasons = []string{"spam", "harassment", "hate", "sexual", "violence", "misinformation", "other"}
+
+// Report is a user's complaint about a post, comment or private message, as
+// shown to moderators. Excerpt is the start of the reported content, empty
+// once the content is gone.
+type Report struct {
+	ID           int        `json:"id"`
+	TargetType   string     `json:"targetType"`
+	TargetID     int        `json:"targetId"`
+	Excerpt      string     `json:"excerpt"`
+	Reporter     string     `json:"reporter"`
+	ReportedUser string     `json:"reportedUser"`
+	Reason       string     `json:"reason"`
+	Details      string     `json:"details"`
+	Status       string     `json:"status"`
+	ClaimedBy    string     `json:"claimedBy,omitempty"`
+	ClaimedAt    *time.Time `json:"claimedAt,omitempty"`
+	ResolvedBy   string     `json:"resolvedBy,omitempty"`
+	ResolvedAt   *time.Time `json:"resolvedAt,omitempty"`
+	Resolution   string     `json:"resolution,omitempty"` // the moderator's note
+	CreatedAt    time.Time  `json:"createdAt"`
+}
@@ -0,0 +1,50 @@
+package models
+
+// Role is what an account may do beyond using the forum.
+type Role string
+
+const (
+	RoleUser      Role = "user"
+	RoleModerator Role = "moderator"
+	RoleAdmin     Role = "admin"
+)
+
+// Permission is one thing a role may be allowed to do.
+type Permission string
+
+const (
+	// PermModerateContent allows editing, removing and restoring other
+	// users' posts and comments.
+	PermModerateContent Permission = "moderate_content"
+	// PermReviewReports allows working through reported content.
+	PermReviewReports Permission = "review_reports"
+	// PermManageRoles allows making other accounts moderators or admins.
+	PermManageRoles Permission = "manage_roles"
+)
+
+var rolePermissions = map[Role][]Permission{
+	RoleModerator: {PermModerateContent, PermReviewReports},
+	RoleAdmin:     {PermModerateContent, PermReviewReports, PermManageRoles},
+}
+
+// Valid reports whether r is a known role.
+func (r Role) Valid() bool {
+	return r == RoleUser || r == RoleModerator || r == RoleAdmin
+}
+
+// Can reports whether the role grants p.
+func (r Role) Can(p Permission) bool {
+	for _, granted := range rolePermissions[r] {
+		if granted == p {
+			return true
+		}
+	}
+	return false
+}
+
+// StaffMember is an account with a role above user, as listed to admins.
+type StaffMember struct {
+	ID       int    `json:"id"`
+	Nickname string `json:"nickname"`
+	Role     Role   `json:"role"`
+}
@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// Session is one signed-in device, as shown to its owner.
+type Session struct {
+	ID         int       `json:"id"`
+	UserAgent  string    `json:"userAgent"`
+	IP         string    `json:"ip"`
+	CreatedAt  time.Time `json:"createdAt"`
+	LastSeenAt time.Time `json:"lastSeenAt"`
+	ExpiresAt  time.Time `json:"expiresAt"`
+	Current    bool      `json:"current"` // the session making the request
+}
+
+// LoginAttempt is one sign-in try, as shown to the account's owner.
+type LoginAttempt struct {
+	Success   bool      `json:"success"`
+	IP        string    `json:"ip"`
+	UserAgent string    `json:"userAgent"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// LoginFailures sums up the failed logins of an account or an IP.
+type LoginFailures struct {
+	Count       int
+	Last        time.Time // zero without failures
+	LockedUntil time.Time // accounts only; zero when not locked
+}
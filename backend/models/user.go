@@ -13,10 +13,29 @@ type User struct {
 type Credentials struct {
 	Identity string `json:"identity"`
 	Password string `json:"password"`
+	Remember bool   `json:"remember"` // keep the session up to session.remember_lifetime
 }
 
 type UserIdentity struct {
 	ID       string `json:"id"`
 	Nickname string `json:"nickname"`
 	Email    string `json:"email"`
+	Verified bool   `json:"verified"` // email address confirmed
+	Role     Role   `json:"role"`
+}
+
+// TwoFactor is a user's TOTP enrollment. It is Enabled once the first code
+// has been confirmed.
+type TwoFactor struct {
+	Secret   string
+	Enabled  bool
+	LastStep int64
+}
+
+// ChatUser is an entry in the chat sidebar user list.
+type ChatUser struct {
+	ID       int    `json:"id"`
+	Username string `json:"username"`
+	Online   bool   `json:"online"`
+	Lasttime string `json:"lasttime"`
 }
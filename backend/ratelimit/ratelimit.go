@@ -0,0 +1,115 @@
+// Package ratelimit implements in-memory token buckets keyed by client,
+// such as an IP address or a user ID.
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Limiter hands out one token every Every, holding at most Burst tokens per
+// key. Buckets that have not been used for the idle TTL are forgotten.
+type Limiter struct {
+	every time.Duration
+	burst float64
+	ttl   time.Duration
+	now   func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// New returns a limiter refilling one token every every, up to burst. A zero
+// every or burst returns nil, and a nil limiter allows everything.
+func New(every time.Duration, burst int, ttl time.Duration) *Limiter {
+	if every <= 0 || burst <= 0 {
+		return nil
+	}
+	// Only full buckets may be forgotten, or expiry would hand out tokens.
+	if full := every * time.Duration(burst); ttl < full {
+		ttl = full
+	}
+	return &Limiter{
+		every:   every,
+		burst:   float64(burst),
+		ttl:     ttl,
+		now:     time.Now,
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// SetClock replaces the limiter's clock; tests use it to move time forward.
+func (l *Limiter) SetClock(now func() time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.now = now
+	l.lastSweep = now()
+}
+
+// Allow takes a token from key's bucket. When the bucket is empty it returns
+// false and how long until the next token is available.
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	if l == nil {
+		return true, 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+
+	elapsed := now.Sub(b.last)
+	b.tokens = math.Min(l.burst, b.tokens+float64(elapsed)/float64(l.every))
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+
+	wait := time.Duration((1 - b.tokens) * float64(l.every))
+	return false, wait
+}
+
+// Len returns the number of buckets currently held.
+func (l *Limiter) Len() int {
+	if l == nil {
+		return 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return len(l.buckets)
+}
+
+// sweep drops idle buckets at most once per TTL. A bucket idle that long has
+// refilled (New keeps the TTL above the refill time), so forgetting it changes
+// nothing for its key.
+func (l *Limiter) sweep(now time.Time) {
+	if l.ttl <= 0 || now.Sub(l.lastSweep) < l.ttl {
+		return
+	}
+	l.lastSweep = now
+
+	for key, b := range l.buckets {
+		if now.Sub(b.last) >= l.ttl {
+			delete(l.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nyagooh/Real-time-forum.git/backend/ratelimit"
+)
+
+// clock is a manually advanced time source.
+type clock struct{ t time.Time }
+
+func (c *clock) now() time.Time          { return c.t }
+func (c *clock) advance(d time.Duration) { c.t = c.t.Add(d) }
+func newClock() *clock                   { return &clock{t: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)} }
+
+func TestBurstThenRefill(t *testing.T) {
+	c := newClock()
+	l := ratelimit.New(time.Second, 3, time.Minute)
+	l.SetClock(c.now)
+
+	for i := 0; i < 3; i++ {
+		if ok, _ := l.Allow("ip:1"); !ok {
+			t.Fatalf("request %d within burst was refused", i+1)
+		}
+	}
+
+	ok, wait := l.Allow("ip:1")
+	if ok {
+		t.Fatal("request beyond burst was allowed")
+	}
+	if wait != time.Second {
+		t.Errorf("retry after %v, want 1s", wait)
+	}
+
+	if ok, _ := l.Allow("ip:2"); !ok {
+		t.Error("another key shares the empty bucket")
+	}
+
+	c.advance(time.Second)
+	if ok, _ := l.Allow("ip:1"); !ok {
+		t.Error("bucket did not refill after one interval")
+	}
+}
+
+func TestIdleBucketsExpire(t *testing.T) {
+	c := newClock()
+	l := ratelimit.New(time.Second, 2, time.Minute)
+	l.SetClock(c.now)
+
+	l.Allow("a")
+	l.Allow("b")
+	if l.Len() != 2 {
+		t.Fatalf("Len = %d, want 2", l.Len())
+	}
+
+	c.advance(time.Minute)
+	l.Allow("c")
+	if l.Len() != 1 {
+		t.Errorf("Len = %d after expiry, want 1", l.Len())
+	}
+}
+
+func TestDisabledLimiterAllowsEverything(t *testing.T) {
+	l := ratelimit.New(time.Second, 0, time.Minute)
+	if l != nil {
+		t.Fatal("zero burst should disable the limiter")
+	}
+	for i := 0; i < 100; i++ {
+		if ok, _ := l.Allow("x"); !ok {
+			t.Fatal("nil limiter refused a request")
+		}
+	}
+}
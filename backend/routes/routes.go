@@ -1,18 +1,37 @@
 package routes
 
 import (
-	"database/sql"
+	"context"
+	"errors"
 	"net/http"
 	"path/filepath"
 	"strings"
 
+	"github.com/nyagooh/Real-time-forum.git/backend/config"
+	"github.com/nyagooh/Real-time-forum.git/backend/database"
 	"github.com/nyagooh/Real-time-forum.git/backend/handlers"
+	"github.com/nyagooh/Real-time-forum.git/backend/mail"
 	"github.com/nyagooh/Real-time-forum.git/backend/middleware"
+	"github.com/nyagooh/Real-time-forum.git/backend/models"
+	"github.com/nyagooh/Real-time-forum.git/backend/ratelimit"
 	ws "github.com/nyagooh/Real-time-forum.git/backend/websockets"
 )
 
-func Routes(db *sql.DB) http.Handler {
-	ws.Initialize()
+func Routes(cfg config.Config, store *database.Store, mailer mail.Mailer) http.Handler {
+	hub := ws.Initialize(store, store, cfg.WebSocket, cfg.RateLimit, cfg.Account)
+
+	limits := cfg.RateLimit
+	authLimit := ratelimit.New(limits.Auth.Every, limits.Auth.Burst, limits.IdleTTL)
+	writeLimit := ratelimit.New(limits.Write.Every, limits.Write.Burst, limits.IdleTTL)
+	reactLimit := ratelimit.New(limits.React.Every, limits.React.Burst, limits.IdleTTL)
+
+	// verified applies the account.require_verified policy for action.
+	verified := func(action string, next http.Handler) http.Handler {
+		if !cfg.Account.Requires(action) {
+			return next
+		}
+		return middleware.RequireVerified(store, next)
+	}
 
 	mux := http.NewServeMux()
 
@@ -20,48 +39,190 @@ func Routes(db *sql.DB) http.Handler {
 	mux.Handle("/assets/", http.StripPrefix("/assets/", fs))
 
 	// Authentication Routes
-	mux.HandleFunc("/register", handlers.RegisterHandler(db))
-	mux.HandleFunc("/login", handlers.LoginHandler(db))
-	mux.HandleFunc("/logout", handlers.LogoutHandler(db))
+	mux.Handle("/register", middleware.RateLimit(authLimit, middleware.ByIP,
+		handlers.RegisterHandler(store, store, mailer, cfg.Account, cfg.Server.PublicURL)),
+	)
+	mux.Handle("/login", middleware.RateLimit(authLimit, middleware.ByIP,
+		handlers.LoginHandler(store, store, store, store, mailer, cfg.Session, cfg.Account, cfg.Server.PublicURL)),
+	)
+	mux.Handle("/login/2fa", middleware.RateLimit(authLimit, middleware.ByIP,
+		handlers.LoginTwoFactorHandler(store, store, store, store, mailer, cfg.Session, cfg.Account, cfg.Server.PublicURL)),
+	)
+	mux.HandleFunc("/logout", handlers.LogoutHandler(store))
+	mux.Handle("/forgot-password", middleware.RateLimit(authLimit, middleware.ByIP,
+		handlers.ForgotPasswordHandler(store, store, mailer, cfg.Account, cfg.Server.PublicURL)),
+	)
+	mux.Handle("/reset-password", middleware.RateLimit(authLimit, middleware.ByIP,
+		handlers.ResetPasswordHandler(store, hub, cfg.Account.Registration)),
+	)
+	mux.HandleFunc("/verify-email", handlers.VerifyEmailHandler(store))
+	mux.Handle("/unlock-account", middleware.RateLimit(authLimit, middleware.ByIP,
+		handlers.UnlockAccountHandler(store)),
+	)
+	mux.Handle("/verify-email/resend", middleware.AuthMiddleware(store, cfg.Session,
+		middleware.RateLimit(authLimit, middleware.ByUser,
+			handlers.ResendVerificationHandler(store, store, mailer, cfg.Account, cfg.Server.PublicURL))),
+	)
+
+	// Two-factor authentication
+	mux.Handle("/2fa", middleware.AuthMiddleware(store, cfg.Session,
+		handlers.TwoFactorStatusHandler(store)),
+	)
+	mux.Handle("/2fa/enroll", middleware.AuthMiddleware(store, cfg.Session,
+		middleware.RateLimit(authLimit, middleware.ByUser,
+			handlers.EnrollTwoFactorHandler(store, store, cfg.Account.TwoFactor))),
+	)
+	mux.Handle("/2fa/confirm", middleware.AuthMiddleware(store, cfg.Session,
+		middleware.RateLimit(authLimit, middleware.ByUser,
+			handlers.ConfirmTwoFactorHandler(store))),
+	)
+	mux.Handle("/2fa/disable", middleware.AuthMiddleware(store, cfg.Session,
+		middleware.RateLimit(authLimit, middleware.ByUser,
+			handlers.DisableTwoFactorHandler(store))),
+	)
+
+	// Signed-in devices
+	mux.Handle("/sessions", middleware.AuthMiddleware(store, cfg.Session,
+		handlers.ListSessionsHandler(store)),
+	)
+	mux.Handle("/sessions/revoke", middleware.AuthMiddleware(store, cfg.Session,
+		handlers.RevokeSessionHandler(store, hub)),
+	)
+	mux.Handle("/sessions/revoke-others", middleware.AuthMiddleware(store, cfg.Session,
+		handlers.RevokeOtherSessionsHandler(store, hub)),
+	)
+	mux.Handle("/login-history", middleware.AuthMiddleware(store, cfg.Session,
+		handlers.LoginHistoryHandler(store)),
+	)
+
+	// Reports and the moderation queue
+	mux.Handle("/report-post", middleware.AuthMiddleware(store, cfg.Session,
+		middleware.RateLimit(writeLimit, middleware.ByUser,
+			handlers.ReportHandler(store, store, hub, models.ReportPost))),
+	)
+	mux.Handle("/report-comment", middleware.AuthMiddleware(store, cfg.Session,
+		middleware.RateLimit(writeLimit, middleware.ByUser,
+			handlers.ReportHandler(store, store, hub, models.ReportComment))),
+	)
+	mux.Handle("/report-message", middleware.AuthMiddleware(store, cfg.Session,
+		middleware.RateLimit(writeLimit, middleware.ByUser,
+			handlers.ReportHandler(store, store, hub, models.ReportMessage))),
+	)
+	mux.Handle("/moderation/reports", middleware.AuthMiddleware(store, cfg.Session,
+		middleware.RequirePermission(store, models.PermReviewReports,
+			handlers.ListReportsHandler(store))),
+	)
+	mux.Handle("/moderation/reports/claim", middleware.AuthMiddleware(store, cfg.Session,
+		middleware.RequirePermission(store, models.PermReviewReports,
+			handlers.ClaimReportHandler(store, store, hub))),
+	)
+	mux.Handle("/moderation/reports/resolve", middleware.AuthMiddleware(store, cfg.Session,
+		middleware.RequirePermission(store, models.PermReviewReports,
+			handlers.ResolveReportHandler(store, store, hub))),
+	)
+
+	// Administration
+	mux.Handle("/admin/staff", middleware.AuthMiddleware(store, cfg.Session,
+		middleware.RequirePermission(store, models.PermManageRoles,
+			handlers.ListStaffHandler(store))),
+	)
+	mux.Handle("/admin/roles", middleware.AuthMiddleware(store, cfg.Session,
+		middleware.RequirePermission(store, models.PermManageRoles,
+			middleware.RateLimit(writeLimit, middleware.ByUser,
+				handlers.SetRoleHandler(store, store)))),
+	)
 
 	// Web Socket Routes
-	mux.Handle("/ws", middleware.AuthMiddleware(db,
-		handlers.ServeWs(db)),
+	mux.Handle("/ws", middleware.AuthMiddleware(store, cfg.Session,
+		handlers.ServeWs(store, hub)),
 	)
-	mux.Handle("/users", middleware.AuthMiddleware(db,
-		ws.GetOnlineUsers(db)),
+	mux.Handle("/users", middleware.AuthMiddleware(store, cfg.Session,
+		ws.GetOnlineUsers(store)),
 	)
 
-	mux.Handle("/render-users", middleware.AuthMiddleware(db,
-		ws.RenderUsers(db)),
+	mux.Handle("/render-users", middleware.AuthMiddleware(store, cfg.Session,
+		ws.RenderUsers(store)),
 	)
 
 	// Fetch messages
-	mux.Handle("/messages", middleware.AuthMiddleware(db,
-		http.HandlerFunc(handlers.GetMessages(db))),
+	mux.Handle("/messages", middleware.AuthMiddleware(store, cfg.Session,
+		handlers.GetMessages(store)),
 	)
 
+	// Health checks for the supervisor and load balancer
+	mux.HandleFunc("/healthz", handlers.HealthHandler())
+	mux.HandleFunc("/readyz", handlers.ReadyHandler(
+		handlers.Check{Name: "database", Run: store.Ping},
+		handlers.Check{Name: "migrations", Run: store.CheckSchema},
+		handlers.Check{Name: "hub", Run: func(context.Context) error {
+			if !hub.Running() {
+				return errors.New("websocket hub is not running")
+			}
+			return nil
+		}},
+		handlers.Check{Name: "uploads", Run: handlers.CheckUploadsWritable},
+	))
+
 	// Validate session
-	mux.HandleFunc("/auth/status", handlers.ValidateSession(db))
+	mux.HandleFunc("/auth/status", handlers.ValidateSession(store))
 
 	// Implement middleware
-	mux.Handle("/posts", middleware.AuthMiddleware(db,
-		http.HandlerFunc(handlers.CreatePostHandler)),
+	mux.Handle("/posts", middleware.AuthMiddleware(store, cfg.Session,
+		middleware.RateLimit(writeLimit, middleware.ByUser,
+			verified("post", handlers.CreatePostHandler(store, cfg.Uploads)))),
 	)
-	mux.Handle("/likes", middleware.AuthMiddleware(db,
-		http.HandlerFunc(handlers.LikePostHandler)),
+	mux.Handle("/posts/edit", middleware.AuthMiddleware(store, cfg.Session,
+		middleware.RateLimit(writeLimit, middleware.ByUser,
+			verified("post", handlers.EditPostHandler(store, store, cfg.Uploads)))),
 	)
-	mux.Handle("/dislikes", middleware.AuthMiddleware(db,
-		http.HandlerFunc(handlers.DislikePostHandler)),
+	mux.Handle("/posts/delete", middleware.AuthMiddleware(store, cfg.Session,
+		middleware.RateLimit(writeLimit, middleware.ByUser,
+			handlers.DeletePostHandler(store, store))),
 	)
-	mux.Handle("/comments", middleware.AuthMiddleware(db,
-		http.HandlerFunc(handlers.AddCommentHandler)),
+	mux.Handle("/posts/restore", middleware.AuthMiddleware(store, cfg.Session,
+		middleware.RequirePermission(store, models.PermModerateContent,
+			handlers.RestorePostHandler(store))),
 	)
-	mux.Handle("/like-comment", middleware.AuthMiddleware(db,
-		http.HandlerFunc(handlers.LikeCommentHandler)),
+	mux.Handle("/posts/revisions", middleware.AuthMiddleware(store, cfg.Session,
+		handlers.ListPostRevisionsHandler(store)),
 	)
-	mux.Handle("/dislike-comment", middleware.AuthMiddleware(db,
-		http.HandlerFunc(handlers.DislikeCommentHandler)),
+	mux.Handle("/posts/revisions/diff", middleware.AuthMiddleware(store, cfg.Session,
+		handlers.DiffPostRevisionsHandler(store)),
+	)
+	mux.Handle("/likes", middleware.AuthMiddleware(store, cfg.Session,
+		middleware.RateLimit(reactLimit, middleware.ByUser,
+			handlers.LikePostHandler(store))),
+	)
+	mux.Handle("/dislikes", middleware.AuthMiddleware(store, cfg.Session,
+		middleware.RateLimit(reactLimit, middleware.ByUser,
+			handlers.DislikePostHandler(store))),
+	)
+	mux.Handle("/comments", middleware.AuthMiddleware(store, cfg.Session,
+		middleware.RateLimit(writeLimit, middleware.ByUser,
+			verified("post", handlers.AddCommentHandler(store, cfg.Content)))),
+	)
+	mux.Handle("/comments/thread", middleware.AuthMiddleware(store, cfg.Session,
+		handlers.ListCommentsHandler(store)),
+	)
+	mux.Handle("/comments/edit", middleware.AuthMiddleware(store, cfg.Session,
+		middleware.RateLimit(writeLimit, middleware.ByUser,
+			verified("post", handlers.EditCommentHandler(store, cfg.Content)))),
+	)
+	mux.Handle("/comments/delete", middleware.AuthMiddleware(store, cfg.Session,
+		middleware.RateLimit(writeLimit, middleware.ByUser,
+			handlers.DeleteCommentHandler(store, store))),
+	)
+	mux.Handle("/comments/restore", middleware.AuthMiddleware(store, cfg.Session,
+		middleware.RequirePermission(store, models.PermModerateContent,
+			handlers.RestoreCommentHandler(store))),
+	)
+	mux.Handle("/like-comment", middleware.AuthMiddleware(store, cfg.Session,
+		middleware.RateLimit(reactLimit, middleware.ByUser,
+			handlers.LikeCommentHandler(store))),
+	)
+	mux.Handle("/dislike-comment", middleware.AuthMiddleware(store, cfg.Session,
+		middleware.RateLimit(reactLimit, middleware.ByUser,
+			handlers.DislikeCommentHandler(store))),
 	)
 	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		if strings.HasPrefix(r.URL.Path, "/api/") || strings.HasPrefix(r.URL.Path, "/assets/") {
@@ -72,5 +233,9 @@ func Routes(db *sql.DB) http.Handler {
 		http.ServeFile(w, r, filepath.Join("frontend", "index.html"))
 	})
 
-	return middleware.SecureHeaders(mux)
+	// These happen before there is a session, and so a token, to check.
+	handler := middleware.CSRF(middleware.SecureHeaders(mux),
+		"/login", "/login/2fa", "/register", "/forgot-password", "/reset-password", "/verify-email", "/unlock-account")
+
+	return middleware.RequestID(middleware.AccessLog(middleware.Metrics(middleware.Recover(handler))))
 }
@@ -0,0 +1,87 @@
+package totp
+
+import (
+	"encoding/base32"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nyagooh/Real-time-forum.git/backend/totp"
+)
+
+// The SHA-1 test vectors of RFC 6238 appendix B, cut to six digits.
+var rfcSecret = base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+func TestCodeMatchesRFC6238(t *testing.T) {
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+
+	for _, tt := range tests {
+		got, err := totp.Code(rfcSecret, time.Unix(tt.unix, 0))
+		if err != nil {
+			t.Fatalf("Code() error = %v", err)
+		}
+		if got != tt.want {
+			t.Errorf("Code(%d) = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestVerify(t *testing.T) {
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		t.Fatalf("GenerateSecret() error = %v", err)
+	}
+	now := time.Unix(1700000000, 0)
+
+	code, _ := totp.Code(secret, now)
+	step, ok := totp.Verify(secret, code, now)
+	if !ok || step != totp.Step(now) {
+		t.Fatalf("Verify() = %d, %v, want %d, true", step, ok, totp.Step(now))
+	}
+
+	// A code from the previous period still counts; older ones do not.
+	previous, _ := totp.Code(secret, now.Add(-totp.Period))
+	if _, ok := totp.Verify(secret, previous, now); !ok {
+		t.Errorf("Verify() rejected a code from the previous period")
+	}
+	stale, _ := totp.Code(secret, now.Add(-3*totp.Period))
+	if _, ok := totp.Verify(secret, stale, now); ok {
+		t.Errorf("Verify() accepted a code three periods old")
+	}
+
+	for _, bad := range []string{"", "12345", "abcdef", "1234567"} {
+		if _, ok := totp.Verify(secret, bad, now); ok {
+			t.Errorf("Verify(%q) = true", bad)
+		}
+	}
+}
+
+func TestURI(t *testing.T) {
+	uri := totp.URI("Real-time Forum", "alice", "JBSWY3DPEHPK3PXP")
+
+	u, err := url.Parse(uri)
+	if err != nil {
+		t.Fatalf("URI() is not a URL: %v", err)
+	}
+	if u.Scheme != "otpauth" || u.Host != "totp" {
+		t.Errorf("URI() = %s, want otpauth://totp/...", uri)
+	}
+	if !strings.HasPrefix(u.Path, "/Real-time Forum:alice") {
+		t.Errorf("URI() label = %q", u.Path)
+	}
+	q := u.Query()
+	if q.Get("secret") != "JBSWY3DPEHPK3PXP" || q.Get("issuer") != "Real-time Forum" || q.Get("digits") != "6" {
+		t.Errorf("URI() query = %v", q)
+	}
+}
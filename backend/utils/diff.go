@@ -0,0 +1,84 @@
+package utils
+
+import "strings"
+
+// Diff operations.
+const (
+	DiffEqual  = "equal"
+	DiffInsert = "insert"
+	DiffDelete = "delete"
+)
+
+// maxDiffCells bounds the work DiffLines does, as the product of the two
+// line counts. Past it the texts are shown as wholly replaced.
+const maxDiffCells = 1 << 20
+
+// DiffLine is one line of a diff: kept, added or removed.
+type DiffLine struct {
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
+
+// DiffLines returns the line-by-line changes that turn a into b, keeping
+// the longest run of common lines.
+func DiffLines(a, b string) []DiffLine {
+	x, y := splitLines(a), splitLines(b)
+	diff := []DiffLine{}
+
+	if len(x)*len(y) > maxDiffCells {
+		for _, line := range x {
+			diff = append(diff, DiffLine{DiffDelete, line})
+		}
+		for _, line := range y {
+			diff = append(diff, DiffLine{DiffInsert, line})
+		}
+		return diff
+	}
+
+	// common[i][j] is the length of the longest common subsequence of
+	// x[i:] and y[j:].
+	common := make([][]int, len(x)+1)
+	for i := range common {
+		common[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				common[i][j] = common[i+1][j+1] + 1
+			} else {
+				common[i][j] = max(common[i+1][j], common[i][j+1])
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(x) && j < len(y) {
+		switch {
+		case x[i] == y[j]:
+			diff = append(diff, DiffLine{DiffEqual, x[i]})
+			i++
+			j++
+		case common[i+1][j] >= common[i][j+1]:
+			diff = append(diff, DiffLine{DiffDelete, x[i]})
+			i++
+		default:
+			diff = append(diff, DiffLine{DiffInsert, y[j]})
+			j++
+		}
+	}
+	for ; i < len(x); i++ {
+		diff = append(diff, DiffLine{DiffDelete, x[i]})
+	}
+	for ; j < len(y); j++ {
+		diff = append(diff, DiffLine{DiffInsert, y[j]})
+	}
+	return diff
+}
+
+// splitLines splits s into lines; the empty string has none.
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
@@ -0,0 +1,51 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/nyagooh/Real-time-forum.git/backend/utils"
+)
+
+func TestDiffLines(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want []utils.DiffLine
+	}{
+		{"Both empty", "", "", []utils.DiffLine{}},
+		{"Added text", "", "hello", []utils.DiffLine{{Op: utils.DiffInsert, Text: "hello"}}},
+		{"Removed text", "hello", "", []utils.DiffLine{{Op: utils.DiffDelete, Text: "hello"}}},
+		{"Unchanged", "a\nb", "a\nb", []utils.DiffLine{{Op: utils.DiffEqual, Text: "a"}, {Op: utils.DiffEqual, Text: "b"}}},
+		{
+			"Changed middle line",
+			"one\ntwo\nthree",
+			"one\n2\nthree",
+			[]utils.DiffLine{
+				{Op: utils.DiffEqual, Text: "one"},
+				{Op: utils.DiffDelete, Text: "two"},
+				{Op: utils.DiffInsert, Text: "2"},
+				{Op: utils.DiffEqual, Text: "three"},
+			},
+		},
+		{
+			"Lines moved around",
+			"a\nb\nc\nd",
+			"b\nc\na\nd",
+			[]utils.DiffLine{
+				{Op: utils.DiffDelete, Text: "a"},
+				{Op: utils.DiffEqual, Text: "b"},
+				{Op: utils.DiffEqual, Text: "c"},
+				{Op: utils.DiffInsert, Text: "a"},
+				{Op: utils.DiffEqual, Text: "d"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := utils.DiffLines(tt.a, tt.b); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DiffLines(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/nyagooh/Real-time-forum.git/backend/utils"
@@ -74,3 +75,80 @@ func TestValidateEmail(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateNickname(t *testing.T) {
+	reserved := []string{"admin"}
+	tests := []struct {
+		name     string
+		nickname string
+		wantCode string
+	}{
+		{name: "Valid nickname", nickname: "jane.doe-2"},
+		{name: "Empty", nickname: "", wantCode: "required"},
+		{name: "Too short", nickname: "jo", wantCode: "too_short"},
+		{name: "Too long", nickname: strings.Repeat("a", utils.NicknameMaxLength+1), wantCode: "too_long"},
+		{name: "Looks like an email", nickname: "jane@example.com", wantCode: "invalid_chars"},
+		{name: "Starts with punctuation", nickname: "_jane", wantCode: "invalid_chars"},
+		{name: "Non-ASCII letters", nickname: "jöhn", wantCode: "invalid_chars"},
+		{name: "Reserved in another case", nickname: "ADMIN", wantCode: "reserved"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := code(utils.ValidateNickname(tt.nickname, reserved)); got != tt.wantCode {
+				t.Errorf("ValidateNickname(%q) code = %q, want %q", tt.nickname, got, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestValidatePassword(t *testing.T) {
+	tests := []struct {
+		name     string
+		password string
+		wantCode string
+	}{
+		{name: "Valid password", password: "correct-horse"},
+		{name: "Empty", password: "", wantCode: "required"},
+		{name: "Too short", password: "ab1", wantCode: "too_short"},
+		{name: "Past bcrypt's limit", password: strings.Repeat("a1", 37), wantCode: "too_long"},
+		{name: "Letters only", password: "correcthorse", wantCode: "weak"},
+		{name: "Digits only", password: "1234567890", wantCode: "weak"},
+		{name: "Contains the nickname", password: "Jane-1234", wantCode: "contains_nickname"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := code(utils.ValidatePassword(tt.password, "jane", 8)); got != tt.wantCode {
+				t.Errorf("ValidatePassword(%q) code = %q, want %q", tt.password, got, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestValidateAge(t *testing.T) {
+	tests := []struct {
+		age      string
+		want     int
+		wantCode string
+	}{
+		{age: "30", want: 30},
+		{age: " 13 ", want: 13},
+		{age: "", wantCode: "required"},
+		{age: "12", wantCode: "out_of_range"},
+		{age: "121", wantCode: "out_of_range"},
+		{age: "30.5", wantCode: "invalid"},
+		{age: "thirty", wantCode: "invalid"},
+	}
+	for _, tt := range tests {
+		got, fe := utils.ValidateAge(tt.age, 13, 120)
+		if code(fe) != tt.wantCode || got != tt.want {
+			t.Errorf("ValidateAge(%q) = %d, %q, want %d, %q", tt.age, got, code(fe), tt.want, tt.wantCode)
+		}
+	}
+}
+
+func code(fe *utils.FieldError) string {
+	if fe == nil {
+		return ""
+	}
+	return fe.Code
+}
@@ -2,9 +2,62 @@ package utils
 
 import (
 	"errors"
+	"fmt"
 	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
+// Nickname and name limits, in characters.
+const (
+	NicknameMinLength = 3
+	NicknameMaxLength = 20
+	NameMaxLength     = 50
+)
+
+// PasswordMaxLength is in bytes; bcrypt ignores anything past it.
+const PasswordMaxLength = 72
+
+// Nicknames are plain ASCII so they read the same everywhere, and never
+// contain "@" so they cannot be mistaken for an email at login.
+var rxNickname = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+// FieldError is a validation failure of one request field. Code is stable
+// for clients to match on; Message is meant for people.
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *FieldError) Error() string {
+	return e.Message
+}
+
+func fieldError(field, code, format string, args ...any) *FieldError {
+	return &FieldError{Field: field, Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// ValidationError collects the failures of one request, in field order.
+type ValidationError []FieldError
+
+func (e ValidationError) Error() string {
+	messages := make([]string, len(e))
+	for i, fe := range e {
+		messages[i] = fe.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Add appends fe unless it is nil.
+func (e *ValidationError) Add(fe *FieldError) {
+	if fe != nil {
+		*e = append(*e, *fe)
+	}
+}
+
 func ValidateEmail(email string) error {
 	rxEmail := regexp.MustCompile("^[a-zA-Z0-9.!#$%&'*+\\/=?^_`{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*$")
 
@@ -18,3 +71,98 @@ func ValidateEmail(email string) error {
 
 	return nil
 }
+
+// ValidateNickname checks the nickname's length and characters and that it
+// is not one of reserved, ignoring case.
+func ValidateNickname(nickname string, reserved []string) *FieldError {
+	n := utf8.RuneCountInString(nickname)
+	switch {
+	case n == 0:
+		return fieldError("nickname", "required", "nickname is required")
+	case n < NicknameMinLength:
+		return fieldError("nickname", "too_short", "nickname must be at least %d characters", NicknameMinLength)
+	case n > NicknameMaxLength:
+		return fieldError("nickname", "too_long", "nickname must be at most %d characters", NicknameMaxLength)
+	case !rxNickname.MatchString(nickname):
+		return fieldError("nickname", "invalid_chars", "nickname may only use letters, digits, '_', '.' and '-', and must start with a letter or digit")
+	}
+
+	for _, name := range reserved {
+		if strings.EqualFold(nickname, name) {
+			return fieldError("nickname", "reserved", "nickname %q is reserved", nickname)
+		}
+	}
+	return nil
+}
+
+// ValidatePassword checks that the password is long enough, mixes letters
+// with digits or symbols and does not contain the nickname.
+func ValidatePassword(password, nickname string, minLength int) *FieldError {
+	switch {
+	case password == "":
+		return fieldError("password", "required", "password is required")
+	case utf8.RuneCountInString(password) < minLength:
+		return fieldError("password", "too_short", "password must be at least %d characters", minLength)
+	case len(password) > PasswordMaxLength:
+		return fieldError("password", "too_long", "password must be at most %d bytes", PasswordMaxLength)
+	}
+
+	var letter, other bool
+	for _, r := range password {
+		if unicode.IsLetter(r) {
+			letter = true
+		} else if !unicode.IsSpace(r) {
+			other = true
+		}
+	}
+	if !letter || !other {
+		return fieldError("password", "weak", "password must mix letters with digits or symbols")
+	}
+
+	if nickname != "" && strings.Contains(strings.ToLower(password), strings.ToLower(nickname)) {
+		return fieldError("password", "contains_nickname", "password must not contain the nickname")
+	}
+	return nil
+}
+
+// ValidateAge parses age, which must be a whole number from min to max.
+func ValidateAge(age string, min, max int) (int, *FieldError) {
+	age = strings.TrimSpace(age)
+	if age == "" {
+		return 0, fieldError("age", "required", "age is required")
+	}
+
+	n, err := strconv.Atoi(age)
+	if err != nil {
+		return 0, fieldError("age", "invalid", "age must be a whole number")
+	}
+	if n < min || n > max {
+		return 0, fieldError("age", "out_of_range", "age must be between %d and %d", min, max)
+	}
+	return n, nil
+}
+
+// ValidateChoice checks that value is one of allowed.
+func ValidateChoice(field, value string, allowed []string) *FieldError {
+	if value == "" {
+		return fieldError(field, "required", "%s is required", field)
+	}
+	for _, a := range allowed {
+		if value == a {
+			return nil
+		}
+	}
+	return fieldError(field, "invalid_choice", "%s must be one of %s", field, strings.Join(allowed, ", "))
+}
+
+// ValidateName checks a required free-text name such as a first name.
+func ValidateName(field, name string) *FieldError {
+	n := utf8.RuneCountInString(name)
+	switch {
+	case n == 0:
+		return fieldError(field, "required", "%s is required", field)
+	case n > NameMaxLength:
+		return fieldError(field, "too_long", "%s must be at most %d characters", field, NameMaxLength)
+	}
+	return nil
+}
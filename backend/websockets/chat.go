@@ -1,70 +1,139 @@
 package websockets
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"log/slog"
+	"math"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/nyagooh/Real-time-forum.git/backend/config"
 	"github.com/nyagooh/Real-time-forum.git/backend/database"
 	"github.com/nyagooh/Real-time-forum.git/backend/errLog"
+	"github.com/nyagooh/Real-time-forum.git/backend/metrics"
 	"github.com/nyagooh/Real-time-forum.git/backend/models"
+	"github.com/nyagooh/Real-time-forum.git/backend/ratelimit"
 
 	"github.com/gorilla/websocket"
 )
 
 type Client struct {
 	ID       string
+	Hub      *Hub
 	Conn     *websocket.Conn
 	Send     chan []byte
 	UserID   string
 	Username string
+	session  string // token of the session that opened the connection
+	log      *slog.Logger
 }
 
-// Hub maintains the set of active clients and broadcasts messages
+// Hub maintains the set of active clients and broadcasts messages. A user
+// signed in on several devices has one client per connection.
 type Hub struct {
-	Clients    map[string]*Client
+	Clients    map[string]map[*Client]bool // by username
 	Register   chan *Client
 	Unregister chan *Client
 	Broadcast  chan []byte
 	mutex      *sync.Mutex
+	users      database.UserStore
+	messages   database.MessageStore
+	cfg        config.WebSocket
+	limiters   map[string]*ratelimit.Limiter // by message type, keyed per user
+	verifyDMs  bool                          // only verified accounts may send messages
+	done       chan struct{}
+	stopOnce   sync.Once
+	running    atomic.Bool
 }
 
-func NewHub() *Hub {
+func NewHub(users database.UserStore, messages database.MessageStore, cfg config.WebSocket, limits config.RateLimit, account config.Account) *Hub {
 	return &Hub{
-		Clients:    make(map[string]*Client),
+		Clients:    make(map[string]map[*Client]bool),
 		Register:   make(chan *Client),
 		Unregister: make(chan *Client),
 		Broadcast:  make(chan []byte),
 		mutex:      &sync.Mutex{},
+		users:      users,
+		messages:   messages,
+		cfg:        cfg,
+		limiters: map[string]*ratelimit.Limiter{
+			"message": ratelimit.New(limits.WSMessage.Every, limits.WSMessage.Burst, limits.IdleTTL),
+			"typing":  ratelimit.New(limits.WSTyping.Every, limits.WSTyping.Burst, limits.IdleTTL),
+		},
+		verifyDMs: account.Requires("message"),
+		done:      make(chan struct{}),
 	}
 }
 
+// NewClient wraps an upgraded connection in a client attached to the hub.
+// ctx is the upgrade request's context; its request ID is kept on the
+// client's logger so every line about the connection can be traced back.
+// session is the token the connection was authenticated with, so
+// CloseSessions can find it when that session is revoked.
+func (h *Hub) NewClient(ctx context.Context, conn *websocket.Conn, userID int, username, session string) *Client {
+	return &Client{
+		ID:       strconv.Itoa(userID),
+		Hub:      h,
+		Conn:     conn,
+		Send:     make(chan []byte, h.cfg.SendBuffer),
+		UserID:   strconv.Itoa(userID),
+		Username: username,
+		session:  session,
+		log:      slog.With("request_id", errLog.RequestID(ctx), "user_id", userID),
+	}
+}
+
+// remove drops client from the hub and closes its send queue. The caller
+// holds h.mutex.
+func (h *Hub) remove(client *Client) {
+	clients := h.Clients[client.Username]
+	if !clients[client] {
+		return
+	}
+	delete(clients, client)
+	if len(clients) == 0 {
+		delete(h.Clients, client.Username)
+	}
+	close(client.Send)
+}
+
 func (h *Hub) Run() {
+	h.running.Store(true)
+	defer h.running.Store(false)
+
 	for {
 		select {
+		case <-h.done:
+			return
+
 		case client := <-h.Register:
 			h.mutex.Lock()
-			h.Clients[client.Username] = client
+			if h.Clients[client.Username] == nil {
+				h.Clients[client.Username] = make(map[*Client]bool)
+			}
+			h.Clients[client.Username][client] = true
 			h.mutex.Unlock()
 
 		case client := <-h.Unregister:
 			h.mutex.Lock()
-			if _, exists := h.Clients[client.Username]; exists {
-				delete(h.Clients, client.Username)
-				close(client.Send)
-			}
+			h.remove(client)
 			h.mutex.Unlock()
 
 		case message := <-h.Broadcast:
 			h.mutex.Lock()
 
-			for _, client := range h.Clients {
-				select {
-				case client.Send <- message:
-				default:
-					close(client.Send)
-					delete(h.Clients, client.Username)
+			for _, clients := range h.Clients {
+				for client := range clients {
+					select {
+					case client.Send <- message:
+					default:
+						metrics.WebSocketDroppedSends.Inc()
+						h.remove(client)
+					}
 				}
 			}
 			h.mutex.Unlock()
@@ -72,20 +141,153 @@ func (h *Hub) Run() {
 	}
 }
 
+// Running reports whether the Run loop is serving registrations and messages.
+func (h *Hub) Running() bool {
+	return h.running.Load()
+}
+
+// Done is closed once the hub has been shut down.
+func (h *Hub) Done() <-chan struct{} {
+	return h.done
+}
+
+// Shutdown sends every client a close frame carrying reason, waits for them
+// to disconnect until ctx expires, closes whatever is left and stops Run.
+func (h *Hub) Shutdown(ctx context.Context, reason string) {
+	h.mutex.Lock()
+	for _, clients := range h.Clients {
+		for client := range clients {
+			sendClose(client.Conn, websocket.CloseServiceRestart, reason, h.cfg.WriteWait)
+		}
+	}
+	h.mutex.Unlock()
+
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+
+wait:
+	for h.ClientCount() > 0 {
+		select {
+		case <-ctx.Done():
+			break wait
+		case <-ticker.C:
+		}
+	}
+
+	h.mutex.Lock()
+	for _, clients := range h.Clients {
+		for client := range clients {
+			client.Conn.Close()
+		}
+	}
+	h.mutex.Unlock()
+
+	h.stopOnce.Do(func() { close(h.done) })
+}
+
+// CloseSessions disconnects every client, and every /users presence
+// connection, opened with one of the given session tokens, for when those
+// sessions are revoked. The connections are closed right after the close
+// frame so a client that ignores it cannot keep using the hub.
+func (h *Hub) CloseSessions(reason string, sessions ...string) {
+	revoked := make(map[string]bool, len(sessions))
+	for _, session := range sessions {
+		revoked[session] = true
+	}
+	closePresenceSessions(reason, revoked, h.cfg.WriteWait)
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	for _, clients := range h.Clients {
+		for client := range clients {
+			if revoked[client.session] {
+				sendClose(client.Conn, websocket.ClosePolicyViolation, reason, h.cfg.WriteWait)
+				client.Conn.Close()
+				client.log.Info("Websocket closed for revoked session")
+			}
+		}
+	}
+}
+
+// QueueDepth returns the number of messages waiting in client send queues.
+func (h *Hub) QueueDepth() int {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	depth := 0
+	for _, clients := range h.Clients {
+		for client := range clients {
+			depth += len(client.Send)
+		}
+	}
+	return depth
+}
+
+// ClientCount returns the number of connected chat clients.
+func (h *Hub) ClientCount() int {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	n := 0
+	for _, clients := range h.Clients {
+		n += len(clients)
+	}
+	return n
+}
+
+// SendMessage delivers message to every connection of username.
 func (h *Hub) SendMessage(username string, message []byte) {
 	h.mutex.Lock()
 	defer h.mutex.Unlock()
 
-	if client, exists := h.Clients[username]; exists {
+	clients := h.Clients[username]
+	if len(clients) == 0 {
+		slog.Debug("Receiver not connected", "receiver", username)
+		return
+	}
+
+	for client := range clients {
 		select {
 		case client.Send <- message:
-			errLog.Info.Println("Message sent to client")
+			slog.Debug("Message sent to client", "receiver", username)
 		default:
-			close(client.Send)
-			delete(h.Clients, username)
+			metrics.WebSocketDroppedSends.Inc()
+			slog.Warn("Send queue full, dropping client", "receiver", username)
+			h.remove(client)
 		}
-	} else if !exists {
-		errLog.Info.Println("Client not found")
+	}
+}
+
+// sendError queues an error frame about one of the client's own messages of
+// type messageType. The frame is dropped if the client's queue is full; it is
+// only advice.
+func (c *Client) sendError(messageType, code, message string, retryAfter time.Duration) {
+	frame := map[string]any{
+		"type":        "error",
+		"messageType": messageType,
+		"code":        code,
+		"message":     message,
+	}
+	if retryAfter > 0 {
+		frame["retryAfter"] = int(math.Ceil(retryAfter.Seconds()))
+	}
+
+	data, err := json.Marshal(frame)
+	if err != nil {
+		return
+	}
+
+	c.Hub.mutex.Lock()
+	defer c.Hub.mutex.Unlock()
+
+	// The hub closes Send when it drops the client; only send while registered.
+	if !c.Hub.Clients[c.Username][c] {
+		return
+	}
+	select {
+	case c.Send <- data:
+	default:
 	}
 }
 
@@ -101,6 +303,11 @@ func (h *Hub) ReceiveMessage(message []byte, sender *Client) error {
 		return fmt.Errorf("error unmarshaling message: %v", err)
 	}
 
+	if ok, wait := h.limiters[genericMsg.Type].Allow(sender.UserID); !ok {
+		sender.sendError(genericMsg.Type, "rate_limited", "too many messages, slow down", wait)
+		return nil
+	}
+
 	// Handle different message types
 	switch genericMsg.Type {
 	case "message":
@@ -110,12 +317,23 @@ func (h *Hub) ReceiveMessage(message []byte, sender *Client) error {
 		}
 
 		msg.Sender = sender.Username
-		msg.SenderID, err = database.GetUserID(msg.Sender)
+		msg.SenderID, err = h.users.GetUserID(msg.Sender)
 		if err != nil {
 			return err
 		}
 
-		msg.ReceiverID, err = database.GetUserID(msg.Receiver)
+		if h.verifyDMs {
+			verified, err := h.users.IsVerified(msg.SenderID)
+			if err != nil {
+				return err
+			}
+			if !verified {
+				sender.sendError(genericMsg.Type, "email_unverified", "verify your email address to send messages", 0)
+				return nil
+			}
+		}
+
+		msg.ReceiverID, err = h.users.GetUserID(msg.Receiver)
 		if err != nil {
 			return err
 		}
@@ -124,10 +342,11 @@ func (h *Hub) ReceiveMessage(message []byte, sender *Client) error {
 			msg.Timestamp = time.Now()
 		}
 
-		err = database.SaveMessage(&msg)
+		err = h.messages.SaveMessage(&msg)
 		if err != nil {
 			return fmt.Errorf("failed to save message: %v", err)
 		}
+		metrics.MessagesPersisted.Inc()
 
 		var data []byte
 		data, err = json.Marshal(msg)
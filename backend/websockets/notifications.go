@@ -1,78 +1,97 @@
 package websockets
 
 import (
-	"database/sql"
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"net/http"
+	"net/url"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/nyagooh/Real-time-forum.git/backend/config"
 	"github.com/nyagooh/Real-time-forum.git/backend/database"
-	"github.com/nyagooh/Real-time-forum.git/backend/errLog"
 	"github.com/nyagooh/Real-time-forum.git/backend/middleware"
+	"github.com/nyagooh/Real-time-forum.git/backend/models"
 
 	"github.com/gorilla/websocket"
 )
 
-const (
-	// Time allowed to write a message to the peer
-	writeWait = 10 * time.Second
-
-	// Time allowed to read the next pong message from the peer
-	pongWait = 60 * time.Second
-
-	// Send pings to peer with this period (must be less than pongWait)
-	pingPeriod = (pongWait * 9) / 10
-
-	// Maximum message size allowed from peer
-	// maxMessageSize = 512
-)
-
 var Upgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
-	// Allow all origins for development
-	CheckOrigin: func(r *http.Request) bool {
+	CheckOrigin:     checkOrigin,
+}
+
+// Extra origins allowed to open websockets, set by Initialize
+var allowedOrigins = map[string]bool{}
+
+// checkOrigin only lets pages served by this host, or an allowed origin,
+// open a websocket, so another site cannot ride on the visitor's session
+// cookie. Requests without an Origin header do not come from browsers.
+func checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
 		return true
-	},
+	}
+
+	u, err := url.Parse(origin)
+	if err == nil && strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	if allowedOrigins[strings.ToLower(origin)] {
+		return true
+	}
+
+	slog.WarnContext(r.Context(), "Websocket origin rejected", "origin", origin, "host", r.Host)
+	return false
 }
 
-// Global hub instance
-var GlobalHub = NewHub()
+// Global hub instance, created by Initialize
+var GlobalHub *Hub
 
 // readPump pumps messages from the websocket connection to the hub
 func (c *Client) ReadPump() {
 	defer func() {
-		GlobalHub.Unregister <- c
+		select {
+		case c.Hub.Unregister <- c:
+		case <-c.Hub.done:
+		}
 		c.Conn.Close()
 	}()
 
-	// c.Conn.SetReadLimit(maxMessageSize)
-	c.Conn.SetReadDeadline(time.Now().Add(pongWait))
+	cfg := c.Hub.cfg
+	if cfg.MaxMessageSize > 0 {
+		c.Conn.SetReadLimit(cfg.MaxMessageSize)
+	}
+	c.Conn.SetReadDeadline(time.Now().Add(cfg.PongWait))
 	c.Conn.SetPongHandler(func(string) error {
-		c.Conn.SetReadDeadline(time.Now().Add(pongWait))
+		c.Conn.SetReadDeadline(time.Now().Add(cfg.PongWait))
 		return nil
 	})
 
 	for {
 		_, message, err := c.Conn.ReadMessage()
 		if err != nil {
-			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-				errLog.Error.Printf("Error reading message: %v\n", err)
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure, websocket.CloseServiceRestart) {
+				c.log.Error("Error reading websocket message", "err", err)
 			}
 			break
 		}
 
 		// Process the message
-		GlobalHub.ReceiveMessage(message, c)
-		errLog.Info.Println("Message recieved")
+		if err := c.Hub.ReceiveMessage(message, c); err != nil {
+			c.log.Warn("Failed to handle websocket message", "err", err)
+		}
+		c.log.Debug("Message received")
 	}
 }
 
 // writePump pumps messages from the hub to the websocket connection
 func (c *Client) WritePump() {
-	ticker := time.NewTicker(pingPeriod)
+	cfg := c.Hub.cfg
+	ticker := time.NewTicker(cfg.PingPeriod)
 	defer func() {
 		ticker.Stop()
 		c.Conn.Close()
@@ -81,7 +100,7 @@ func (c *Client) WritePump() {
 	for {
 		select {
 		case message, ok := <-c.Send:
-			c.Conn.SetWriteDeadline(time.Now().Add(writeWait))
+			c.Conn.SetWriteDeadline(time.Now().Add(cfg.WriteWait))
 			if !ok {
 				// The hub closed the channel
 				c.Conn.WriteMessage(websocket.CloseMessage, []byte{})
@@ -104,7 +123,7 @@ func (c *Client) WritePump() {
 				return
 			}
 		case <-ticker.C:
-			c.Conn.SetWriteDeadline(time.Now().Add(writeWait))
+			c.Conn.SetWriteDeadline(time.Now().Add(cfg.WriteWait))
 			if err := c.Conn.WriteMessage(websocket.PingMessage, nil); err != nil {
 				return
 			}
@@ -112,100 +131,51 @@ func (c *Client) WritePump() {
 	}
 }
 
-// Initialize starts the hub
-func Initialize() {
-	go GlobalHub.Run()
+// sendClose asks the peer to disconnect with a close frame carrying code and
+// reason. WriteControl is safe to call alongside the connection's writer
+// goroutine.
+func sendClose(conn *websocket.Conn, code int, reason string, wait time.Duration) {
+	msg := websocket.FormatCloseMessage(code, reason)
+	if err := conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(wait)); err != nil {
+		conn.Close()
+	}
 }
 
-type User struct {
-	ID       int    `json:"id"`
-	Username string `json:"username"`
-	Online   bool   `json:"online"`
-	Lasttime string `json:"lasttime"`
+// Initialize creates the global hub and starts it
+func Initialize(users database.UserStore, messages database.MessageStore, cfg config.WebSocket, limits config.RateLimit, account config.Account) *Hub {
+	for _, origin := range cfg.AllowedOrigins {
+		allowedOrigins[strings.ToLower(strings.TrimSuffix(origin, "/"))] = true
+	}
+
+	GlobalHub = NewHub(users, messages, cfg, limits, account)
+	go GlobalHub.Run()
+	return GlobalHub
 }
 
 var (
-	clients     = make(map[*websocket.Conn]bool) // Track active WebSocket clients
-	onlineUsers = make(map[int]bool)             // Track online users by user ID
-	mu          sync.Mutex                       // Protect shared resources
+	clients     = make(map[*websocket.Conn]string) // Active /users connections and the session token each was opened with
+	onlineUsers = make(map[int]int)                // Open /users connections per user ID; a user is online while above 0
+	mu          sync.Mutex                         // Protect shared resources
 )
 
 // **Fetch all users from the database**
-func fetchUsersByInteraction(db *sql.DB, userID int) ([]User, error) {
-	// In notifications.go, replace the existing query in fetchUsersByInteraction
-	query := `
-       WITH last_messages AS (
-			SELECT
-        		u.id AS id,
-        		u.nickname,
-        		COALESCE(
-            		(SELECT strftime('%Y-%m-%dT%H:%M:%SZ', MAX(m.timestamp))
-             		FROM messages m
-             		WHERE (m.sender_id = u.id AND m.receiver_id = ?)
-                		OR (m.sender_id = ? AND m.receiver_id = u.id)
-            		), 
-            		''
-        		) AS sort_time
-    		FROM 
-       			users u
-    		WHERE 
-        		u.id != ?
-		)
-		SELECT 
-    		id AS id,
-    		nickname,
-    		sort_time
-		FROM 
-    		last_messages
-		ORDER BY 
-    		CASE WHEN sort_time = '' THEN 1 ELSE 0 END,
-    		sort_time DESC,
-    		nickname ASC;
-    `
-
-	rows, err := db.Query(query, userID, userID, userID)
+func fetchUsersByInteraction(users database.UserStore, userID int) ([]models.ChatUser, error) {
+	list, err := users.GetUsersByInteraction(userID)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
-
-	var users []User
-	for rows.Next() {
-		var user User
-		var lastMessageTime sql.NullString
-
-		err := rows.Scan(&user.ID, &user.Username, &lastMessageTime)
-		if err != nil {
-			return nil, err
-		}
-
-		if lastMessageTime.Valid {
-			// Parse the timestamp and format it
-			if t, err := time.Parse("2006-01-02 15:04:05", lastMessageTime.String); err == nil {
-				user.Lasttime = t.Format(time.RFC3339) // Convert to ISO8601 format
-			} else {
-				user.Lasttime = "" // If parsing fails, set empty string
-			}
-		} else {
-			user.Lasttime = "" // Set empty string if no interaction
-		}
-
-		mu.Lock()
-		user.Online = onlineUsers[user.ID]
-		mu.Unlock()
-
-		users = append(users, user)
-	}
 
-	if err := rows.Err(); err != nil {
-		return nil, err
+	mu.Lock()
+	for i := range list {
+		list[i].Online = onlineUsers[list[i].ID] > 0
 	}
+	mu.Unlock()
 
-	return users, nil
+	return list, nil
 }
 
 // **Broadcast updates to all WebSocket clients**
-func broadcastUpdate(users []User) {
+func broadcastUpdate(users []models.ChatUser) {
 	mu.Lock()
 	defer mu.Unlock()
 
@@ -218,7 +188,7 @@ func broadcastUpdate(users []User) {
 	for client := range clients {
 		err := client.WriteJSON(message)
 		if err != nil {
-			errLog.Error.Println("Error sending update:", err)
+			slog.Error("Error sending user update", "err", err)
 			client.Close()
 			delete(clients, client)
 		}
@@ -226,25 +196,25 @@ func broadcastUpdate(users []User) {
 }
 
 // function to render initial site users
-func RenderUsers(db *sql.DB) http.HandlerFunc {
+func RenderUsers(userStore database.UserStore) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		userIDval := r.Context().Value(middleware.UserIDKey)
 		if userIDval == nil {
-			errLog.Error.Println("Invalid userID value")
+			slog.WarnContext(r.Context(), "Missing user ID in request context")
 			handleError(w, fmt.Errorf("unauthorized"), http.StatusUnauthorized)
 			return
 		}
 
 		userID, ok := userIDval.(int)
 		if !ok {
-			errLog.Error.Println("error")
+			slog.ErrorContext(r.Context(), "Unexpected user ID type in request context")
 			handleError(w, fmt.Errorf("internal server error"), http.StatusInternalServerError)
 			return
 		}
 
-		users, err := fetchUsersByInteraction(db, userID)
+		users, err := fetchUsersByInteraction(userStore, userID)
 		if err != nil {
-			errLog.Error.Println("Error fetching users:", err)
+			slog.ErrorContext(r.Context(), "Failed to fetch users", "user_id", userID, "err", err)
 			return
 		}
 
@@ -254,82 +224,118 @@ func RenderUsers(db *sql.DB) http.HandlerFunc {
 }
 
 // **Handle WebSocket connections**
-func GetOnlineUsers(db *sql.DB) http.HandlerFunc {
+func GetOnlineUsers(userStore database.UserStore) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		session, ok := middleware.SessionToken(r)
+		if !ok {
+			slog.WarnContext(r.Context(), "Missing session token in request context")
+			handleError(w, fmt.Errorf("unauthorized"), http.StatusUnauthorized)
+			return
+		}
+
 		conn, err := Upgrader.Upgrade(w, r, nil)
 		if err != nil {
-			errLog.Error.Println("WebSocket upgrade error:", err)
+			slog.ErrorContext(r.Context(), "WebSocket upgrade failed", "err", err)
 			handleError(w, fmt.Errorf("failed to upgrade to WebSocket: %v", err), http.StatusInternalServerError)
 			return
 		}
 		defer conn.Close()
 
-		clients[conn] = true
-
 		userIDval := r.Context().Value(middleware.UserIDKey)
 		if userIDval == nil {
-			errLog.Error.Println("Invalid userID value")
+			slog.WarnContext(r.Context(), "Missing user ID in request context")
 			handleError(w, fmt.Errorf("unauthorized"), http.StatusUnauthorized)
 			return
 		}
 
 		userID, ok := userIDval.(int)
 		if !ok {
-			errLog.Error.Println("error")
+			slog.ErrorContext(r.Context(), "Unexpected user ID type in request context")
 			handleError(w, fmt.Errorf("internal server error"), http.StatusInternalServerError)
 			return
 		}
 
-		currentUser, err := database.GetUserByID(userID)
+		currentUser, err := userStore.GetUserByID(userID)
 		if err != nil {
-			errLog.Error.Println("Error fetching user:", err)
+			slog.ErrorContext(r.Context(), "Failed to fetch user", "user_id", userID, "err", err)
 			return
 		}
 
 		mu.Lock()
-		onlineUsers[userID] = true
+		clients[conn] = session
+		onlineUsers[userID]++
 		mu.Unlock()
 
-		userStruct := User{
+		userStruct := models.ChatUser{
 			ID:       userID,
 			Username: currentUser,
-			Online:   onlineUsers[userID],
+			Online:   true,
 			Lasttime: "",
 		}
 
 		// Update clients when a user comes online
-		users, err := fetchUsersByInteraction(db, userID)
+		users, err := fetchUsersByInteraction(userStore, userID)
 		if err != nil {
-			errLog.Error.Println("Error fetching users:", err)
+			slog.ErrorContext(r.Context(), "Failed to fetch users", "user_id", userID, "err", err)
 			return
 		}
 
 		users = append(users, userStruct)
 		broadcastUpdate(users)
-		errLog.Info.Println(users)
+		slog.DebugContext(r.Context(), "User came online", "user_id", userID, "users", len(users))
 
 		// Keep connection alive, listen for disconnects
 		for {
 			_, _, err := conn.ReadMessage()
 			if err != nil {
-				errLog.Error.Println("user list WebSocket disconnected:", err)
+				slog.InfoContext(r.Context(), "User list websocket disconnected", "user_id", userID, "err", err)
 				break
 			}
 		}
 
-		// Mark user as offline when they disconnect
+		// The user stays online while any of their other devices is
+		// connected
 		mu.Lock()
-		delete(onlineUsers, userID)
+		delete(clients, conn)
+		onlineUsers[userID]--
+		offline := onlineUsers[userID] <= 0
+		if offline {
+			delete(onlineUsers, userID)
+		}
 		mu.Unlock()
 
-		// Notify clients of the update
-		users, _ = fetchUsersByInteraction(db, userID)
+		if offline {
+			users, _ = fetchUsersByInteraction(userStore, userID)
 
-		userStruct.Online = onlineUsers[userID]
-		users = append(users, userStruct)
-		broadcastUpdate(users)
+			userStruct.Online = false
+			users = append(users, userStruct)
+			broadcastUpdate(users)
+		}
+	}
+}
 
-		delete(clients, conn)
+// closePresenceSessions closes the /users connections opened with one of
+// the revoked session tokens.
+func closePresenceSessions(reason string, revoked map[string]bool, wait time.Duration) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	for conn, session := range clients {
+		if revoked[session] {
+			sendClose(conn, websocket.ClosePolicyViolation, reason, wait)
+			conn.Close()
+		}
+	}
+}
+
+// ClosePresenceConnections sends a close frame carrying reason to every
+// /users connection so browsers can reconnect once the server is back.
+func ClosePresenceConnections(reason string, wait time.Duration) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	for conn := range clients {
+		sendClose(conn, websocket.CloseServiceRestart, reason, wait)
 	}
 }
 
@@ -0,0 +1,191 @@
+package websockets
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/nyagooh/Real-time-forum.git/backend/config"
+	ws "github.com/nyagooh/Real-time-forum.git/backend/websockets"
+)
+
+// connect serves hub over a test server and dials it as user 1, "alice",
+// signed in with session, returning once the hub has registered the client.
+func connect(t *testing.T, hub *ws.Hub, session string) *websocket.Conn {
+	t.Helper()
+	before := hub.ClientCount()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := ws.Upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("upgrade failed: %v", err)
+			return
+		}
+		client := hub.NewClient(r.Context(), conn, 1, "alice", session)
+		hub.Register <- client
+		go client.WritePump()
+		go client.ReadPump()
+	}))
+	t.Cleanup(server.Close)
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	deadline := time.Now().Add(time.Second)
+	for hub.ClientCount() == before && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	return conn
+}
+
+func TestHubShutdownSendsCloseFrame(t *testing.T) {
+	hub := ws.NewHub(nil, nil, config.Default().WebSocket, config.Default().RateLimit, config.Account{})
+	go hub.Run()
+
+	conn := connect(t, hub, "session-1")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	shutdownDone := make(chan struct{})
+	go func() {
+		hub.Shutdown(ctx, "server restarting")
+		close(shutdownDone)
+	}()
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, _, err := conn.ReadMessage()
+
+	var closeErr *websocket.CloseError
+	if !errors.As(err, &closeErr) {
+		t.Fatalf("expected a close frame, got %v", err)
+	}
+	if closeErr.Code != websocket.CloseServiceRestart || closeErr.Text != "server restarting" {
+		t.Errorf("close frame = %d %q, want %d %q", closeErr.Code, closeErr.Text, websocket.CloseServiceRestart, "server restarting")
+	}
+
+	select {
+	case <-shutdownDone:
+	case <-time.After(3 * time.Second):
+		t.Fatalf("Shutdown did not return")
+	}
+
+	select {
+	case <-hub.Done():
+	default:
+		t.Errorf("expected hub to be stopped after Shutdown")
+	}
+}
+
+func TestHubRateLimitsMessageTypes(t *testing.T) {
+	limits := config.Default().RateLimit
+	limits.WSTyping = config.Limit{Every: time.Minute, Burst: 1}
+
+	hub := ws.NewHub(nil, nil, config.Default().WebSocket, limits, config.Account{})
+	go hub.Run()
+
+	conn := connect(t, hub, "session-1")
+	defer func() {
+		conn.Close()
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		hub.Shutdown(ctx, "test over")
+	}()
+
+	typing := map[string]any{"type": "typing", "receiver": "bob", "isTyping": true}
+	for i := 0; i < 2; i++ {
+		if err := conn.WriteJSON(typing); err != nil {
+			t.Fatalf("write failed: %v", err)
+		}
+	}
+
+	var frame struct {
+		Type        string `json:"type"`
+		MessageType string `json:"messageType"`
+		Code        string `json:"code"`
+		RetryAfter  int    `json:"retryAfter"`
+	}
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if err := conn.ReadJSON(&frame); err != nil {
+		t.Fatalf("expected an error frame, got %v", err)
+	}
+	if frame.Type != "error" || frame.MessageType != "typing" || frame.Code != "rate_limited" || frame.RetryAfter != 60 {
+		t.Errorf("frame = %+v", frame)
+	}
+}
+
+func TestHubClosesRevokedSessions(t *testing.T) {
+	hub := ws.NewHub(nil, nil, config.Default().WebSocket, config.Default().RateLimit, config.Account{})
+	go hub.Run()
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		hub.Shutdown(ctx, "test over")
+	}()
+
+	// One user signed in on two devices.
+	laptop := connect(t, hub, "laptop")
+	phone := connect(t, hub, "phone")
+	if got := hub.ClientCount(); got != 2 {
+		t.Fatalf("ClientCount() = %d, want 2", got)
+	}
+
+	hub.CloseSessions("session revoked", "phone")
+
+	phone.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, _, err := phone.ReadMessage()
+	var closeErr *websocket.CloseError
+	if !errors.As(err, &closeErr) || closeErr.Code != websocket.ClosePolicyViolation {
+		t.Fatalf("revoked connection got %v, want a policy violation close frame", err)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for hub.ClientCount() != 1 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := hub.ClientCount(); got != 1 {
+		t.Fatalf("ClientCount() after revoke = %d, want 1", got)
+	}
+
+	// The other device still gets messages sent to the user.
+	hub.SendMessage("alice", []byte(`{"type":"ping"}`))
+	laptop.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, msg, err := laptop.ReadMessage(); err != nil || string(msg) != `{"type":"ping"}` {
+		t.Errorf("remaining connection read %q, %v", msg, err)
+	}
+}
+
+func TestUpgraderRejectsForeignOrigin(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := ws.Upgrader.Upgrade(w, r, nil)
+		if err == nil {
+			conn.Close()
+		}
+	}))
+	defer server.Close()
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	header := http.Header{"Origin": {"https://evil.example"}}
+	if _, resp, err := websocket.DefaultDialer.Dial(url, header); err == nil {
+		t.Error("handshake from a foreign origin succeeded")
+	} else if resp == nil || resp.StatusCode != http.StatusForbidden {
+		t.Errorf("foreign origin: got %v, want 403", err)
+	}
+
+	header = http.Header{"Origin": {server.URL}}
+	conn, _, err := websocket.DefaultDialer.Dial(url, header)
+	if err != nil {
+		t.Fatalf("same-origin handshake failed: %v", err)
+	}
+	conn.Close()
+}
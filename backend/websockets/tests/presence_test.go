@@ -0,0 +1,120 @@
+package websockets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/nyagooh/Real-time-forum.git/backend/config"
+	"github.com/nyagooh/Real-time-forum.git/backend/middleware"
+	"github.com/nyagooh/Real-time-forum.git/backend/models"
+	ws "github.com/nyagooh/Real-time-forum.git/backend/websockets"
+)
+
+// presenceUsers is a UserStore knowing users 1 and 2, who have talked.
+type presenceUsers struct{}
+
+func (presenceUsers) InsertUser(user models.User) error { return nil }
+
+func (presenceUsers) GetUser(credential models.Credentials) (models.UserIdentity, string, error) {
+	return models.UserIdentity{}, "", errors.New("not found")
+}
+
+func (presenceUsers) GetUsers() ([]string, error) { return nil, nil }
+
+func (presenceUsers) GetUserID(identity string) (int, error) { return 0, errors.New("not found") }
+
+func (presenceUsers) GetUserByID(id int) (string, error) { return "user" + strconv.Itoa(id), nil }
+
+func (presenceUsers) GetUserEmail(id int) (string, error) { return "", nil }
+
+func (presenceUsers) IsVerified(id int) (bool, error) { return true, nil }
+
+func (presenceUsers) GetUsersByInteraction(userID int) ([]models.ChatUser, error) {
+	return []models.ChatUser{{ID: 1, Username: "user1"}, {ID: 2, Username: "user2"}}, nil
+}
+
+// withUser serves handler as the user given by the "user" query parameter,
+// signed in with the session given by "session", as AuthMiddleware would.
+func withUser(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, _ := strconv.Atoi(r.URL.Query().Get("user"))
+		ctx := context.WithValue(r.Context(), middleware.UserIDKey, userID)
+		if session := r.URL.Query().Get("session"); session != "" {
+			ctx = context.WithValue(ctx, middleware.SessionTokenKey, session)
+		}
+		handler.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func TestPresenceAcrossSessions(t *testing.T) {
+	presence := httptest.NewServer(withUser(ws.GetOnlineUsers(presenceUsers{})))
+	defer presence.Close()
+	list := withUser(ws.RenderUsers(presenceUsers{}))
+
+	dial := func(user int, session string) *websocket.Conn {
+		t.Helper()
+		url := "ws" + strings.TrimPrefix(presence.URL, "http") + "?user=" + strconv.Itoa(user) + "&session=" + session
+		conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+		if err != nil {
+			t.Fatalf("dial failed: %v", err)
+		}
+		t.Cleanup(func() { conn.Close() })
+		return conn
+	}
+	// waitOnline polls the user list until user 1 shows as want.
+	waitOnline := func(want bool) {
+		t.Helper()
+		var online bool
+		for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+			rec := httptest.NewRecorder()
+			list.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/users?user=2", nil))
+			var resp struct{ Users []models.ChatUser }
+			json.Unmarshal(rec.Body.Bytes(), &resp)
+			if online = resp.Users[0].Online; online == want {
+				return
+			}
+		}
+		t.Fatalf("user 1 online = %v, want %v", online, want)
+	}
+
+	// Without a session in the context the upgrade is refused.
+	rec := httptest.NewRecorder()
+	withUser(ws.GetOnlineUsers(presenceUsers{})).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/users?user=1", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("presence without a session status = %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+
+	laptop := dial(1, "laptop")
+	phone := dial(1, "phone")
+	waitOnline(true)
+
+	// Closing one device leaves the user online on the other.
+	laptop.Close()
+	time.Sleep(100 * time.Millisecond)
+	waitOnline(true)
+
+	// Revoking the other session closes its presence socket too.
+	hub := ws.NewHub(nil, nil, config.Default().WebSocket, config.Default().RateLimit, config.Account{})
+	hub.CloseSessions("session revoked", "phone")
+
+	phone.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var closeErr *websocket.CloseError
+	for {
+		if _, _, err := phone.ReadMessage(); err != nil {
+			if !errors.As(err, &closeErr) || closeErr.Code != websocket.ClosePolicyViolation {
+				t.Fatalf("revoked presence connection got %v, want a policy violation close frame", err)
+			}
+			break
+		}
+	}
+	waitOnline(false)
+}
@@ -1,3 +1,5 @@
+import { setCSRFToken, csrfHeaders } from '../state/csrf.js';
+
 export class AuthManager {
   constructor(stateManager) {
     this.state = stateManager;
@@ -10,13 +12,30 @@ export class AuthManager {
       this.handleLogout();
     });
 
+    document.addEventListener('route:verify-email', () => {
+      this.handleVerifyEmail();
+    });
+
+    document.addEventListener('route:unlock-account', () => {
+      this.handleUnlockAccount();
+    });
+
     document.addEventListener('submit', (e) => {
       if (e.target.id === 'loginForm') {
         e.preventDefault();
         this.handleLogin(e);
+      } else if (e.target.id === 'twoFactorForm') {
+        e.preventDefault();
+        this.handleTwoFactor(e);
       } else if (e.target.id === 'registerForm') {
         e.preventDefault();
         this.handleRegister(e);
+      } else if (e.target.id === 'forgotPasswordForm') {
+        e.preventDefault();
+        this.handleForgotPassword(e);
+      } else if (e.target.id === 'resetPasswordForm') {
+        e.preventDefault();
+        this.handleResetPassword(e);
       }
     });
 
@@ -32,6 +51,9 @@ export class AuthManager {
         e.preventDefault();
         window.history.pushState(null, '', '/login');
         document.dispatchEvent(new CustomEvent('route:login'));
+      } else if (e.target.id === 'resendVerificationBtn') {
+        e.preventDefault();
+        this.handleResendVerification();
       } else if (e.target.id === 'signUpBtn') {
         e.preventDefault();
         window.history.pushState(null, '', '/register');
@@ -50,6 +72,7 @@ export class AuthManager {
     registerBtn.classList.remove('active');
     loginForm.classList.remove('hidden');
     registerForm.classList.add('hidden');
+    this.hideRecoveryForms();
 
     window.history.replaceState(null, '', '/login');
   }
@@ -64,14 +87,22 @@ export class AuthManager {
     loginBtn.classList.remove('active');
     loginForm.classList.add('hidden');
     registerForm.classList.remove('hidden');
+    this.hideRecoveryForms();
 
     window.history.replaceState(null, '', '/register');
   }
 
+  hideRecoveryForms() {
+    document.getElementById('forgotPasswordForm')?.classList.add('hidden');
+    document.getElementById('resetPasswordForm')?.classList.add('hidden');
+    document.getElementById('twoFactorForm')?.classList.add('hidden');
+  }
+
   async handleLogin(e) {
     e.preventDefault();
     const identity = document.getElementById('loginUsername').value;
     const password = document.getElementById('loginPassword').value;
+    const remember = document.getElementById('loginRemember').checked;
     
     try {
       const response = await fetch('/login', {
@@ -79,7 +110,7 @@ export class AuthManager {
         headers: {
           'Content-Type': 'application/json'
         },
-        body: JSON.stringify({ identity, password })
+        body: JSON.stringify({ identity, password, remember })
       });
 
       if (!response.ok) {
@@ -87,22 +118,67 @@ export class AuthManager {
       }
 
       const data = await response.json();
-      this.state.setState({ currentUser: data.user });
-      document.dispatchEvent(new CustomEvent('auth:login'));
       this.clearForm(e.target);
 
-      window.history.pushState(null, '', '/dashboard');
-      document.dispatchEvent(new CustomEvent('route:dashboard'));
-
-      if (!this.hasFetchedPosts) {
-        document.dispatchEvent(new CustomEvent('posts:fetch'));
-        hasFetchedPosts = true;
+      // The password was right but the account wants a second factor.
+      if (data.twoFactorRequired) {
+        this.pendingToken = data.pendingToken;
+        this.remember = remember;
+        e.target.classList.add('hidden');
+        document.getElementById('twoFactorForm').classList.remove('hidden');
+        document.getElementById('twoFactorCode').focus();
+        return;
       }
+
+      this.completeLogin(data);
     } catch (error) {
       this.showError(e.target, 'Invalid username or password');
     }
   }
 
+  async handleTwoFactor(e) {
+    const code = document.getElementById('twoFactorCode').value.trim();
+    // Authenticator codes are all digits; anything else is a recovery code.
+    const factor = /^[0-9 ]+$/.test(code) ? { code } : { recoveryCode: code };
+
+    try {
+      const response = await fetch('/login/2fa', {
+        method: 'POST',
+        headers: {
+          'Content-Type': 'application/json'
+        },
+        body: JSON.stringify({ pendingToken: this.pendingToken, remember: this.remember, ...factor })
+      });
+
+      const data = await response.json();
+      if (!response.ok) {
+        throw new Error(data.message || 'Verification failed');
+      }
+
+      this.pendingToken = null;
+      this.clearForm(e.target);
+      e.target.classList.add('hidden');
+      document.getElementById('loginForm').classList.remove('hidden');
+      this.completeLogin(data);
+    } catch (error) {
+      this.showError(e.target, error.message);
+    }
+  }
+
+  completeLogin(data) {
+    setCSRFToken(data.csrfToken);
+    this.state.setState({ currentUser: data.user });
+    document.dispatchEvent(new CustomEvent('auth:login'));
+
+    window.history.pushState(null, '', '/dashboard');
+    document.dispatchEvent(new CustomEvent('route:dashboard'));
+
+    if (!this.hasFetchedPosts) {
+      document.dispatchEvent(new CustomEvent('posts:fetch'));
+      hasFetchedPosts = true;
+    }
+  }
+
   async handleRegister(e) {
     e.preventDefault();
     const nickname = document.getElementById('registerUsername').value;
@@ -140,6 +216,10 @@ export class AuthManager {
 
       if (!response.ok) {
         const error = await response.json();
+        // Validation failures list every field that needs fixing.
+        if (error.errors) {
+          throw new Error(error.errors.map((fieldError) => fieldError.message).join('; '));
+        }
         throw new Error(error.message || 'Registration failed');
       }
 
@@ -154,10 +234,140 @@ export class AuthManager {
     }
   }
 
+  async handleForgotPassword(e) {
+    const identity = document.getElementById('forgotIdentity').value;
+
+    try {
+      const response = await fetch('/forgot-password', {
+        method: 'POST',
+        headers: {
+          'Content-Type': 'application/json'
+        },
+        body: JSON.stringify({ identity })
+      });
+
+      const data = await response.json();
+      if (!response.ok) {
+        throw new Error(data.message || 'Request failed');
+      }
+
+      this.clearForm(e.target);
+      this.showSuccess(e.target, 'If the account exists, a reset link is on its way to your email.');
+    } catch (error) {
+      this.showError(e.target, error.message);
+    }
+  }
+
+  async handleResetPassword(e) {
+    const password = document.getElementById('resetPassword').value;
+    const confirmPassword = document.getElementById('resetConfirmPassword').value;
+    const token = new URLSearchParams(window.location.search).get('token');
+
+    if (password !== confirmPassword) {
+      this.showError(e.target, 'Passwords do not match');
+      return;
+    }
+
+    try {
+      const response = await fetch('/reset-password', {
+        method: 'POST',
+        headers: {
+          'Content-Type': 'application/json'
+        },
+        body: JSON.stringify({ token, password })
+      });
+
+      const data = await response.json();
+      if (!response.ok) {
+        throw new Error(data.message || 'Password reset failed');
+      }
+
+      this.clearForm(e.target);
+      this.showSuccess(e.target, 'Password changed! Please login.');
+      setTimeout(() => {
+        window.history.pushState(null, '', '/login');
+        document.dispatchEvent(new CustomEvent('auth:showLogin'));
+      }, 1000);
+    } catch (error) {
+      this.showError(e.target, error.message);
+    }
+  }
+
+  async handleVerifyEmail() {
+    const token = new URLSearchParams(window.location.search).get('token');
+
+    try {
+      const response = await fetch('/verify-email', {
+        method: 'POST',
+        headers: {
+          'Content-Type': 'application/json'
+        },
+        body: JSON.stringify({ token })
+      });
+
+      const data = await response.json();
+      if (!response.ok) {
+        throw new Error(data.message || 'Verification failed');
+      }
+
+      alert('Your email address is confirmed.');
+    } catch (error) {
+      alert(error.message);
+    }
+
+    window.history.replaceState(null, '', '/');
+    this.checkLoginStatus();
+  }
+
+  async handleUnlockAccount() {
+    const token = new URLSearchParams(window.location.search).get('token');
+
+    try {
+      const response = await fetch('/unlock-account', {
+        method: 'POST',
+        headers: {
+          'Content-Type': 'application/json'
+        },
+        body: JSON.stringify({ token })
+      });
+
+      const data = await response.json();
+      if (!response.ok) {
+        throw new Error(data.message || 'Unlock failed');
+      }
+
+      alert('Your account is unlocked. You can sign in again.');
+    } catch (error) {
+      alert(error.message);
+    }
+
+    window.history.replaceState(null, '', '/login');
+    this.checkLoginStatus();
+  }
+
+  async handleResendVerification() {
+    try {
+      const response = await fetch('/verify-email/resend', {
+        method: 'POST',
+        headers: csrfHeaders()
+      });
+
+      const data = await response.json();
+      if (!response.ok) {
+        throw new Error(data.message || 'Failed to resend email');
+      }
+
+      alert('A new confirmation link is on its way to your email.');
+    } catch (error) {
+      alert(error.message);
+    }
+  }
+
   handleLogout() {
     try {
       const response = fetch('/logout', {
-        method: 'POST'
+        method: 'POST',
+        headers: csrfHeaders()
       });
 
       if (response.ok) {
@@ -190,14 +400,19 @@ export class AuthManager {
       const data = await response.json();
 
       if (data.isLoggedIn) {
+      setCSRFToken(data.csrfToken);
       this.state.setState({ currentUser: data.user });
-      if (window.location.pathname === '/login' || window.location.pathname === '/register' || window.location.pathname === '/') {
+      if (['/login', '/register', '/verify-email', '/'].includes(window.location.pathname)) {
         window.history.replaceState(null, '', '/dashboard');
       }
       document.dispatchEvent(new CustomEvent('auth:login'));
       } else {
       this.state.setState({ currentUser: null });
       const path = window.location.pathname;
+      if (path === '/forgot-password' || path === '/reset-password') {
+        document.dispatchEvent(new CustomEvent('route:' + path.slice(1)));
+        return;
+      }
       if (path !== '/login' && path !== '/register') {
         window.history.replaceState(null, '', '/login');
       }
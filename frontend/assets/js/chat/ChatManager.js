@@ -229,6 +229,21 @@ export class ChatManager {
   }
 
   handleIncomingMessage(message) {
+    // Errors about our own messages, e.g. when sending too fast
+    if (message.type === "error") {
+      console.warn(`Chat error (${message.code}): ${message.message}`);
+      if (message.code === "rate_limited" && message.messageType === "message") {
+        alert(`You are sending messages too fast. Try again in ${message.retryAfter || 1}s.`);
+      }
+      return;
+    }
+
+    // New and updated reports, sent to moderators only
+    if (message.type === "report") {
+      document.dispatchEvent(new CustomEvent("moderation:report", { detail: message }));
+      return;
+    }
+
     // Handle typing status messages
     if (message.type === "typing") {
       this.handleTypingNotification(message);
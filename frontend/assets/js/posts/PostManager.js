@@ -1,4 +1,5 @@
 import { PostUI } from "../ui/components/PostUI.js";
+import { csrfHeaders } from "../state/csrf.js";
 
 export class PostManager {
   constructor(stateManager) {
@@ -70,6 +71,8 @@ export class PostManager {
         const postId = post.dataset.postId;
         const commentId = comment.dataset.commentId;
         this.handleDislikeComment(parseInt(postId), parseInt(commentId));
+      } else if (e.target.closest(".comment-reply")) {
+        this.startReply(e.target.closest(".comment-reply"));
       } else if (e.target.id === "createPostBtn") {
         this.showCreatePostForm();
       } else if (e.target.id === "cancelPost") {
@@ -223,6 +226,7 @@ export class PostManager {
     try {
       const response = await fetch("/posts", {
         method: "POST",
+        headers: csrfHeaders(),
         body: formData,
       });
 
@@ -298,6 +302,13 @@ export class PostManager {
         if (post.Comments && post.Comments.length > 0) {
           post.Comments = post.Comments.map((comment) => ({
             ID: comment.id,
+            parentId: comment.parentId || 0,
+            depth: comment.depth || 0,
+            path: comment.path,
+            deleted: comment.deleted,
+            deletedBy: comment.deletedBy,
+            edited: comment.edited,
+            updatedAt: comment.updatedAt,
             content: comment.content,
             username: comment.username,
             createdAt: comment.createdAt,
@@ -326,9 +337,9 @@ export class PostManager {
     try {
       const response = await fetch(`/likes?id=${postId}`, {
         method: "POST",
-        headers: {
+        headers: csrfHeaders({
           "Content-Type": "application/json",
-        },
+        }),
       });
 
       if (!response.ok) {
@@ -391,9 +402,9 @@ export class PostManager {
     try {
       const response = await fetch(`/dislikes?id=${postId}`, {
         method: "POST",
-        headers: {
+        headers: csrfHeaders({
           "Content-Type": "application/json",
-        },
+        }),
       });
 
       if (!response.ok) {
@@ -462,9 +473,19 @@ export class PostManager {
     }
   }
 
+  // Points the post's comment form at the comment being replied to
+  startReply(button) {
+    const form = button.closest(".post").querySelector(".comment-form");
+    const input = form.querySelector(".comment-input");
+    form.dataset.parentId = button.closest(".comment").dataset.commentId;
+    input.placeholder = `Reply to ${button.dataset.username}...`;
+    input.focus();
+  }
+
   async handleAddComment(postId, e) {
     const commentInput = e.target.querySelector(".comment-input");
     const commentText = commentInput.value.trim();
+    const parentId = parseInt(e.target.dataset.parentId) || 0;
     const state = this.state.getState();
 
     if (!commentText || !state.currentUser) return;
@@ -472,10 +493,10 @@ export class PostManager {
     try {
       const response = await fetch(`/comments?id=${postId}`, {
         method: "POST",
-        headers: {
+        headers: csrfHeaders({
           "Content-Type": "application/json",
-        },
-        body: JSON.stringify({ text: commentText }),
+        }),
+        body: JSON.stringify({ text: commentText, parentId }),
       });
 
       if (!response.ok) {
@@ -492,6 +513,9 @@ export class PostManager {
 
             post.Comments.push({
               ID: data.comment.id,
+              parentId: data.comment.parentId || 0,
+              depth: data.comment.depth || 0,
+              path: data.comment.path,
               content: data.comment.content,
               username: data.comment.username,
               createdAt: data.comment.createdAt,
@@ -501,6 +525,8 @@ export class PostManager {
               likedBy: [],
               dislikedBy: [],
             });
+            // Keep thread order so the reply lands under its parent
+            post.Comments.sort((a, b) => (a.path < b.path ? -1 : a.path > b.path ? 1 : 0));
           }
           return post;
         });
@@ -524,6 +550,8 @@ export class PostManager {
       }
 
       commentInput.value = "";
+      commentInput.placeholder = "Write a comment...";
+      delete e.target.dataset.parentId;
     } catch (error) {
       console.error("Error commenting on post:", error);
     }
@@ -538,9 +566,9 @@ export class PostManager {
         `/like-comment?postId=${postId}&commentId=${commentId}`,
         {
           method: "POST",
-          headers: {
+          headers: csrfHeaders({
             "Content-Type": "application/json",
-          },
+          }),
         },
       );
 
@@ -618,9 +646,9 @@ export class PostManager {
         `/dislike-comment?postId=${postId}&commentId=${commentId}`,
         {
           method: "POST",
-          headers: {
+          headers: csrfHeaders({
             "Content-Type": "application/json",
-          },
+          }),
         },
       );
 
@@ -1,11 +1,15 @@
 export class Router {
   constructor(stateManager) {
     this.state = stateManager;
-    this.publicRoutes = ['/login', '/register']; // Routes that don't require auth
+    this.publicRoutes = ['/login', '/register', '/forgot-password', '/reset-password', '/verify-email', '/unlock-account']; // Routes that don't require auth
     this.routes = {
       '/': this.handleHome.bind(this),
       '/login': this.handleLogin.bind(this),
       '/register': this.handleRegister.bind(this),
+      '/forgot-password': this.handleForgotPassword.bind(this),
+      '/reset-password': this.handleResetPassword.bind(this),
+      '/verify-email': this.handleVerifyEmail.bind(this),
+      '/unlock-account': this.handleUnlockAccount.bind(this),
       '/dashboard': this.handleDashboard.bind(this),
       '/posts': this.handlePosts.bind(this),
       '/my-posts': this.handleMyPosts.bind(this),
@@ -72,6 +76,22 @@ export class Router {
     document.dispatchEvent(new CustomEvent('route:register'));
   }
 
+  handleForgotPassword() {
+    document.dispatchEvent(new CustomEvent('route:forgot-password'));
+  }
+
+  handleResetPassword() {
+    document.dispatchEvent(new CustomEvent('route:reset-password'));
+  }
+
+  handleVerifyEmail() {
+    document.dispatchEvent(new CustomEvent('route:verify-email'));
+  }
+
+  handleUnlockAccount() {
+    document.dispatchEvent(new CustomEvent('route:unlock-account'));
+  }
+
   handleDashboard() {
     const state = this.state.getState();
     if (!state.currentUser) {
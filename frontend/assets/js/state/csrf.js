@@ -0,0 +1,11 @@
+// Holds the CSRF token issued at login and by /auth/status. The server
+// requires it in the X-CSRF-Token header of every non-GET request.
+let csrfToken = "";
+
+export function setCSRFToken(token) {
+  csrfToken = token || "";
+}
+
+export function csrfHeaders(headers = {}) {
+  return { ...headers, "X-CSRF-Token": csrfToken };
+}
@@ -67,6 +67,12 @@ export class UIManager {
     // Route events
     document.addEventListener("route:login", () => this.showLogin());
     document.addEventListener("route:register", () => this.showRegister());
+    document.addEventListener("route:forgot-password", () =>
+      this.showAuthForm("forgotPasswordForm")
+    );
+    document.addEventListener("route:reset-password", () =>
+      this.showAuthForm("resetPasswordForm")
+    );
     document.addEventListener("route:dashboard", () => this.showDashboard());
     document.addEventListener("route:posts", () => this.showAllPosts());
     document.addEventListener("route:my-posts", () => this.showMyPosts());
@@ -83,6 +89,10 @@ export class UIManager {
       ${AuthUI.getAuthContainer()}
       <div class="dashboard-container hidden" id="dashboard">
         ${HeaderUI.getHeader()}
+        <div id="verifyBanner" class="verify-banner hidden">
+          Please confirm your email address to post and send messages.
+          <button id="resendVerificationBtn">Resend email</button>
+        </div>
         <div class="dashboard-content">
           ${SidebarUI.getLeftSidebar()}
           <main class="main-content">
@@ -99,7 +109,11 @@ export class UIManager {
     `;
 
     this.initializeElements();
-    if (!["/login", "/register"].includes(window.location.pathname)) {
+    if (
+      !["/login", "/register", "/forgot-password", "/reset-password", "/unlock-account"].includes(
+        window.location.pathname
+      )
+    ) {
       this.fetchInitialUsers();
     }
   }
@@ -136,6 +150,7 @@ export class UIManager {
       onlineUsersList: document.getElementById("onlineUsersList"),
       notFoundContainer: document.getElementById("notFoundContainer"),
       postsContainer: document.getElementById("postsContainer"),
+      verifyBanner: document.getElementById("verifyBanner"),
     };
   }
 
@@ -162,6 +177,10 @@ export class UIManager {
       this.elements.logoutBtn.classList.remove("hidden");
       this.elements.usernameDisplay.textContent = state.currentUser.nickname;
       this.elements.createPostBtn?.classList.remove("hidden");
+      this.elements.verifyBanner.classList.toggle(
+        "hidden",
+        state.currentUser.verified !== false
+      );
 
       // Update URL if not already on dashboard
       if (window.location.pathname !== "/dashboard") {
@@ -172,6 +191,7 @@ export class UIManager {
       this.elements.signUpBtn.classList.remove("hidden");
       this.elements.logoutBtn.classList.add("hidden");
       this.elements.createPostBtn?.classList.add("hidden");
+      this.elements.verifyBanner.classList.add("hidden");
     }
 
     this.refreshPosts();
@@ -201,6 +221,9 @@ export class UIManager {
       loginForm.classList.remove("hidden");
       registerForm.classList.add("hidden");
     }
+    document.getElementById("forgotPasswordForm")?.classList.add("hidden");
+    document.getElementById("resetPasswordForm")?.classList.add("hidden");
+    document.getElementById("twoFactorForm")?.classList.add("hidden");
 
     // Update URL if not already on login
     if (window.location.pathname !== "/login") {
@@ -231,6 +254,9 @@ export class UIManager {
       registerForm.classList.remove("hidden");
       loginForm.classList.add("hidden");
     }
+    document.getElementById("forgotPasswordForm")?.classList.add("hidden");
+    document.getElementById("resetPasswordForm")?.classList.add("hidden");
+    document.getElementById("twoFactorForm")?.classList.add("hidden");
 
     // Update URL if not already on register
     if (window.location.pathname !== "/register") {
@@ -238,6 +264,25 @@ export class UIManager {
     }
   }
 
+  // showAuthForm shows one of the account recovery forms on its own.
+  showAuthForm(formId) {
+    this.ensureInitialized();
+
+    if (!this.elements) {
+      this.initializeElements();
+    }
+
+    this.elements.authContainer.classList.remove("hidden");
+    this.elements.dashboard.classList.add("hidden");
+    this.elements.notFoundContainer.classList.add("hidden");
+
+    document.getElementById("loginBtn")?.classList.remove("active");
+    document.getElementById("registerBtn")?.classList.remove("active");
+    this.elements.authContainer.querySelectorAll("form").forEach((form) => {
+      form.classList.toggle("hidden", form.id !== formId);
+    });
+  }
+
   show404() {
     this.ensureInitialized();
 
@@ -11,7 +11,54 @@ export class AuthUI {
           <label for="loginPassword">Password</label>
           <input type="password" id="loginPassword" required>
         </div>
+        <label class="remember-me">
+          <input type="checkbox" id="loginRemember"> Remember me
+        </label>
         <button type="submit">Login</button>
+        <a href="/forgot-password" id="forgotPasswordLink" class="auth-link">Forgot password?</a>
+      </form>
+    `;
+  }
+
+  static getTwoFactorForm() {
+    return `
+      <form id="twoFactorForm" class="form hidden">
+        <h2>Two-Factor Authentication</h2>
+        <div class="form-group">
+          <label for="twoFactorCode">Code from your authenticator app, or a recovery code</label>
+          <input type="text" id="twoFactorCode" autocomplete="one-time-code" required>
+        </div>
+        <button type="submit">Verify</button>
+      </form>
+    `;
+  }
+
+  static getForgotPasswordForm() {
+    return `
+      <form id="forgotPasswordForm" class="form hidden">
+        <h2>Forgot Password</h2>
+        <div class="form-group">
+          <label for="forgotIdentity">Username or Email</label>
+          <input type="text" id="forgotIdentity" required>
+        </div>
+        <button type="submit">Send reset link</button>
+      </form>
+    `;
+  }
+
+  static getResetPasswordForm() {
+    return `
+      <form id="resetPasswordForm" class="form hidden">
+        <h2>Choose a New Password</h2>
+        <div class="form-group">
+          <label for="resetPassword">New Password</label>
+          <input type="password" id="resetPassword" required>
+        </div>
+        <div class="form-group">
+          <label for="resetConfirmPassword">Confirm Password</label>
+          <input type="password" id="resetConfirmPassword" required>
+        </div>
+        <button type="submit">Reset password</button>
       </form>
     `;
   }
@@ -73,7 +120,10 @@ export class AuthUI {
         </header>
         <main>
           ${this.getLoginForm()}
+          ${this.getTwoFactorForm()}
           ${this.getRegisterForm()}
+          ${this.getForgotPasswordForm()}
+          ${this.getResetPasswordForm()}
         </main>
       </div>
     `;
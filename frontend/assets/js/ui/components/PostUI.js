@@ -81,6 +81,11 @@ export class PostUI {
     `;
   }
 
+  // Text shown in place of a deleted post or comment
+  static tombstoneText(item) {
+    return item.deletedBy === 'moderator' ? '[removed by a moderator]' : '[deleted]';
+  }
+
   static createPostHTML(post, showComments = false) {
     const state = window.forumApp?.state?.getState() || { currentUser: null };
     const currentUser = state.currentUser;
@@ -98,13 +103,15 @@ export class PostUI {
 
     const comments = post.Comments || [];
 
+    // Comments arrive in thread order; replies are indented by their depth
     const commentsHTML = comments.map(comment => `
-      <div class="comment" data-comment-id="${comment.ID}">
+      <div class="comment ${comment.deleted ? 'comment-deleted' : ''}" data-comment-id="${comment.ID}" style="margin-left: ${(comment.depth || 0) * 1.5}rem">
         <div class="comment-content">
-          <p>${comment.content}</p>
+          <p>${comment.deleted ? PostUI.tombstoneText(comment) : comment.content}</p>
           <div class="comment-meta">
-            <span class="comment-author">Posted by ${comment.username}</span>
-            <span class="comment-time">${new Date(comment.createdAt).toLocaleString()}</span>
+            <span class="comment-author">${comment.deleted ? '' : `Posted by ${comment.username}`}</span>
+            <span class="comment-time">${new Date(comment.createdAt).toLocaleString()}${comment.edited && !comment.deleted ? ` <span class="comment-edited" title="Edited ${new Date(comment.updatedAt).toLocaleString()}">(edited)</span>` : ''}</span>
+            ${comment.deleted ? '' : `<button type="button" class="comment-reply" data-username="${comment.username}">Reply</button>`}
           </div>
         </div>
         <div class="comment-reactions">
@@ -129,12 +136,12 @@ export class PostUI {
     `).join('');
 
     return `
-      <article class="post" data-post-id="${post.id}">
-        <h2>${post.title}</h2>
+      <article class="post ${post.deleted ? 'post-deleted' : ''}" data-post-id="${post.id}">
+        <h2>${post.deleted ? PostUI.tombstoneText(post) : post.title}</h2>
         ${categoriesHTML}
         <p>${post.content}</p>
         ${post.imageURL ? `<div class="post-image"><img src="${post.imageURL.replace('frontend/', '/')}" alt="Post Image" loading="lazy"></div>` : ''}
-        <small class="post-meta">Posted by ${post.username} on ${new Date(post.createdAt).toLocaleDateString()}</small>
+        <small class="post-meta">${post.deleted ? 'Posted' : `Posted by ${post.username}`} on ${new Date(post.createdAt).toLocaleDateString()}${post.edited ? ` <span class="post-edited" title="Edited ${new Date(post.updatedAt).toLocaleString()}">(edited)</span>` : ''}</small>
         <div class="post-actions">
           <button class="like-btn ${userLiked ? 'active' : ''}" title="Like">
             <svg viewBox="0 0 24 24" width="20" height="20">